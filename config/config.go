@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"os"
 	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -20,3 +24,67 @@ func InitialiseConfig() error {
 	}
 	return nil
 }
+
+// PostgresDSN builds a lib/pq connection URL from DatabaseURI, filling in the
+// credentials and database name when they are configured separately.
+// DatabaseReadTimeout becomes the connect timeout (seconds) and
+// DatabaseWriteTimeout the server-side statement timeout (seconds).
+func (c Config) PostgresDSN() (string, error) {
+	u, err := url.Parse(c.DatabaseURI)
+	if err != nil {
+		return "", fmt.Errorf("PostgresDSN: %v", err)
+	}
+	if u.Scheme == "" {
+		return "", fmt.Errorf("PostgresDSN: DatabaseURI must be a postgres:// URL")
+	}
+	if c.DatabaseUserName != "" {
+		u.User = url.UserPassword(c.DatabaseUserName, c.DatabasePassword)
+	}
+	if c.DatabaseName != "" {
+		u.Path = "/" + c.DatabaseName
+	}
+	query := u.Query()
+	if c.DatabaseReadTimeout > 0 {
+		query.Set("connect_timeout", strconv.FormatUint(uint64(c.DatabaseReadTimeout), 10))
+	}
+	if c.DatabaseWriteTimeout > 0 {
+		query.Set("statement_timeout", strconv.FormatUint(uint64(c.DatabaseWriteTimeout)*1000, 10))
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// LocalAccounts reports whether users may register and sign in with an email
+// and password.
+func (c Config) LocalAccounts() bool {
+	for _, name := range c.AuthProviders {
+		if strings.EqualFold(strings.TrimSpace(name), "local") {
+			return true
+		}
+	}
+	return false
+}
+
+// OIDCProviders returns the enabled providers other than Google and local
+// accounts. Each is
+// configured by SERVER_OIDC_<NAME>_ISSUER and SERVER_OIDC_<NAME>_CLIENTID.
+func (c Config) OIDCProviders() ([]OIDCProvider, error) {
+	var providers []OIDCProvider
+	for _, name := range c.AuthProviders {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == "google" || name == "local" {
+			continue
+		}
+		prefix := "SERVER_OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProvider{
+			Name:     name,
+			Issuer:   os.Getenv(prefix + "ISSUER"),
+			ClientID: os.Getenv(prefix + "CLIENTID"),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("OIDCProviders: %sISSUER and %sCLIENTID must be set for provider %s", prefix, prefix, name)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
@@ -2,6 +2,7 @@ package config
 
 type Config struct {
 	Debug                bool
+	DatabaseDriver       string `default:"sqlite"`
 	DatabaseURI          string
 	DatabaseName         string
 	DatabaseReadTimeout  uint
@@ -13,4 +14,16 @@ type Config struct {
 	DBPath               string
 	GoogleToken          string
 	SigningKey           string
+	// AuthProviders lists the identity providers users may sign in with:
+	// "google", "local" for email and password accounts, and the names of
+	// generic OpenID Connect providers.
+	AuthProviders []string `default:"google"`
+}
+
+// OIDCProvider is a generic OpenID Connect provider, such as a self-hosted
+// Keycloak or Dex, that users may sign in with.
+type OIDCProvider struct {
+	Name     string
+	Issuer   string
+	ClientID string
 }
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// API token scopes. Read tokens may only make requests that change nothing.
+const (
+	ApiTokenScopeRead  = "read"
+	ApiTokenScopeWrite = "write"
+)
+
+// ApiTokenScopes lists the valid API token scopes.
+var ApiTokenScopes = []string{ApiTokenScopeRead, ApiTokenScopeWrite}
+
+// apiTokenTouchEvery is how stale LastUsedAt may get, so that a busy script
+// does not write on every request.
+const apiTokenTouchEvery = time.Minute
+
+// apiTokenColumns are the ApiToken columns read by apiTokenFields, in order.
+const apiTokenColumns = "tokenID, userID, name, scope, createdAt, COALESCE(lastUsedAt, '')"
+
+// apiTokenFields returns the scan destinations for apiTokenColumns.
+func apiTokenFields(token *ApiTokenRow) []any {
+	return []any{&token.Id, &token.UserID, &token.Name, &token.Scope, &token.CreatedAt, &token.LastUsedAt}
+}
+
+// CreateApiToken saves a token for token.UserID under the hash of its value.
+// It is created now and has not been used.
+func (d *DBConn) CreateApiToken(token ApiToken, tokenHash string) (int64, error) {
+	var tokenID int64
+	query := "INSERT INTO ApiToken (userID, name, scope, tokenHash, createdAt) VALUES (?, ?, ?, ?, ?) RETURNING tokenID"
+	if err := d.db.QueryRow(d.rebind(query), token.UserID, token.Name, token.Scope, tokenHash, NowDateTime()).Scan(&tokenID); err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("Token already exists: token with name %s already exists for user %d", token.Name, token.UserID)
+		}
+		return 0, fmt.Errorf("CreateApiToken: %w", err)
+	}
+	return tokenID, nil
+}
+
+// GetApiTokensByUserId lists the user's tokens, newest first.
+func (d *DBConn) GetApiTokensByUserId(userID int) ([]ApiTokenRow, error) {
+	query := "SELECT " + apiTokenColumns + " FROM ApiToken WHERE userID = ? ORDER BY tokenID DESC"
+	rows, err := d.db.Query(d.rebind(query), userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetApiTokensByUserId: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []ApiTokenRow{}
+	for rows.Next() {
+		var token ApiTokenRow
+		if err := rows.Scan(apiTokenFields(&token)...); err != nil {
+			return nil, fmt.Errorf("GetApiTokensByUserId: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetApiTokensByUserId: %w", err)
+	}
+	return tokens, nil
+}
+
+func (d *DBConn) GetApiTokenById(tokenID int) (ApiTokenRow, error) {
+	return d.queryApiToken("GetApiTokenById", "tokenID = ?", tokenID)
+}
+
+func (d *DBConn) GetApiTokenByHash(tokenHash string) (ApiTokenRow, error) {
+	return d.queryApiToken("GetApiTokenByHash", "tokenHash = ?", tokenHash)
+}
+
+// queryApiToken reads the one token matching where, reporting errors as fn.
+func (d *DBConn) queryApiToken(fn string, where string, arg any) (ApiTokenRow, error) {
+	var token ApiTokenRow
+	query := "SELECT " + apiTokenColumns + " FROM ApiToken WHERE " + where
+	if err := d.db.QueryRow(d.rebind(query), arg).Scan(apiTokenFields(&token)...); err != nil {
+		if err == sql.ErrNoRows {
+			return token, fmt.Errorf("%s: %w", fn, ErrNotFound)
+		}
+		return token, fmt.Errorf("%s: %w", fn, err)
+	}
+	return token, nil
+}
+
+// TouchApiToken records that the token was just used.
+func (d *DBConn) TouchApiToken(tokenID int) error {
+	now := time.Now().UTC()
+	stale := now.Add(-apiTokenTouchEvery).Format(DateTimeLayout)
+	query := "UPDATE ApiToken SET lastUsedAt = ? WHERE tokenID = ? AND (lastUsedAt IS NULL OR lastUsedAt < ?)"
+	if _, err := d.db.Exec(d.rebind(query), now.Format(DateTimeLayout), tokenID, stale); err != nil {
+		return fmt.Errorf("TouchApiToken: %w", err)
+	}
+	return nil
+}
+
+func (d *DBConn) DeleteApiToken(tokenID int) error {
+	if _, err := d.db.Exec(d.rebind("DELETE FROM ApiToken WHERE tokenID = ?"), tokenID); err != nil {
+		return fmt.Errorf("DeleteApiToken: %w", err)
+	}
+	return nil
+}
+
+func (d *DBConn) ApiTokenBelongsToUser(tokenID int, userID int) (bool, error) {
+	var exists int
+	query := "SELECT 1 FROM ApiToken WHERE tokenID = ? AND userID = ?"
+	if err := d.db.QueryRow(d.rebind(query), tokenID, userID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("ApiTokenBelongsToUser: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// nullIfEmpty stores empty strings as NULL, for optional text columns.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// ExportAccount returns everything the user owns as a Backup, with rows in ID
+// order so a restored account lists the same way. It reads in one transaction
+// so that the backup is a consistent snapshot while the user keeps logging.
+func (d *DBConn) ExportAccount(userID int) (Backup, error) {
+	backup := Backup{ExportedAt: time.Now().UTC().Format(time.RFC3339)}
+	version, err := d.schemaVersion()
+	if err != nil {
+		return backup, fmt.Errorf("ExportAccount: %w", err)
+	}
+	backup.Version = version
+
+	// PostgreSQL's default READ COMMITTED gives each statement its own
+	// snapshot. SQLite transactions are serializable already.
+	var opts *sql.TxOptions
+	if d.dialect == dialectPostgres {
+		opts = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	tx, err := d.db.BeginTx(context.Background(), opts)
+	if err != nil {
+		return backup, fmt.Errorf("ExportAccount: %w", err)
+	}
+	defer tx.Rollback()
+
+	var user UserRow
+	query := `SELECT ` + userColumns + ` FROM "User" WHERE userId = ?`
+	if err := tx.QueryRow(d.rebind(query), userID).Scan(userFields(&user)...); err != nil {
+		if err == sql.ErrNoRows {
+			return backup, fmt.Errorf("ExportAccount: %w", ErrNotFound)
+		}
+		return backup, fmt.Errorf("ExportAccount: %w", err)
+	}
+	backup.User = user.User
+
+	exercises, err := listExercises(d, tx, userID)
+	if err != nil {
+		return backup, fmt.Errorf("ExportAccount: exercises: %w", err)
+	}
+	backup.Exercises = []ExerciseRow{}
+	for _, exercise := range exercises {
+		if exercise.UserID != nil {
+			backup.Exercises = append(backup.Exercises, exercise)
+		}
+	}
+
+	routines, err := listRoutines(d, tx, userID)
+	if err != nil {
+		return backup, fmt.Errorf("ExportAccount: routines: %w", err)
+	}
+	backup.Routines = append([]RoutineRow{}, routines...)
+
+	// Sessions, workouts and sets are read in three passes and stitched
+	// together by ID.
+	backup.Sessions = []BackupSession{}
+	sessionIndex := map[int]int{}
+	rows, err := tx.Query(d.rebind("SELECT "+sessionColumns+", importKey FROM Session WHERE userID = ? ORDER BY sessionID"), userID)
+	if err != nil {
+		return backup, fmt.Errorf("ExportAccount: sessions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row SessionRow
+		var importKey sql.NullString
+		if err := rows.Scan(append(sessionFields(&row), &importKey)...); err != nil {
+			return backup, fmt.Errorf("ExportAccount: sessions: %w", err)
+		}
+		session := BackupSession{
+			Id:          row.Id,
+			DateTime:    row.DateTime,
+			EndDateTime: row.EndDateTime,
+			TimeZone:    row.TimeZone,
+			Title:       row.Title,
+			Notes:       row.Notes,
+			Bodyweight:  row.Bodyweight,
+			ImportKey:   importKey.String,
+			Workouts:    []BackupWorkout{},
+		}
+		sessionIndex[session.Id] = len(backup.Sessions)
+		backup.Sessions = append(backup.Sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return backup, fmt.Errorf("ExportAccount: sessions: %w", err)
+	}
+
+	type workoutPos struct{ session, workout int }
+	workoutIndex := map[int]workoutPos{}
+	rows, err = tx.Query(d.rebind(`
+        SELECT w.workoutID, w.sessionID, w.exerciseID, e.name, w.workoutname, w.position, w.groupNumber
+        FROM Workouts w
+        JOIN Session s ON s.sessionID = w.sessionID
+        JOIN Exercise e ON e.exerciseID = w.exerciseID
+        WHERE s.userID = ?
+        ORDER BY w.workoutID
+    `), userID)
+	if err != nil {
+		return backup, fmt.Errorf("ExportAccount: workouts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var workout BackupWorkout
+		var sessionID int
+		if err := rows.Scan(&workout.Id, &sessionID, &workout.ExerciseID, &workout.ExerciseName, &workout.WorkoutName, &workout.Position, &workout.Group); err != nil {
+			return backup, fmt.Errorf("ExportAccount: workouts: %w", err)
+		}
+		workout.Sets = []BackupSet{}
+		i, ok := sessionIndex[sessionID]
+		if !ok {
+			return backup, fmt.Errorf("ExportAccount: workout %d: unknown session %d", workout.Id, sessionID)
+		}
+		workoutIndex[workout.Id] = workoutPos{i, len(backup.Sessions[i].Workouts)}
+		backup.Sessions[i].Workouts = append(backup.Sessions[i].Workouts, workout)
+	}
+	if err := rows.Err(); err != nil {
+		return backup, fmt.Errorf("ExportAccount: workouts: %w", err)
+	}
+
+	rows, err = tx.Query(d.rebind(`
+        SELECT `+setColumns+`, st.importKey
+        FROM Sets st
+        JOIN Workouts w ON w.workoutID = st.workoutID
+        JOIN Session s ON s.sessionID = w.sessionID
+        WHERE s.userID = ?
+        ORDER BY st.setID
+    `), userID)
+	if err != nil {
+		return backup, fmt.Errorf("ExportAccount: sets: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var set SetRow
+		var importKey sql.NullString
+		if err := rows.Scan(append(setFields(&set), &importKey)...); err != nil {
+			return backup, fmt.Errorf("ExportAccount: sets: %w", err)
+		}
+		pos, ok := workoutIndex[set.WorkoutID]
+		if !ok {
+			return backup, fmt.Errorf("ExportAccount: set %d: unknown workout %d", set.Id, set.WorkoutID)
+		}
+		workout := &backup.Sessions[pos.session].Workouts[pos.workout]
+		workout.Sets = append(workout.Sets, BackupSet{Id: set.Id, ImportKey: importKey.String, Set: set.Set})
+	}
+	if err := rows.Err(); err != nil {
+		return backup, fmt.Errorf("ExportAccount: sets: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return backup, fmt.Errorf("ExportAccount: %w", err)
+	}
+	return backup, nil
+}
+
+// RestoreAccount replaces all of the user's data with the contents of backup
+// in a single transaction. Rows get new IDs; references inside the backup are
+// remapped. The user's preferences are restored too, but the account's email,
+// sign-in identities and provider profile are left as they are.
+func (d *DBConn) RestoreAccount(userID int, backup Backup) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("RestoreAccount: %w", err)
+	}
+	defer tx.Rollback()
+
+	var user UserRow
+	query := `SELECT ` + userColumns + ` FROM "User" WHERE userId = ?`
+	if err := tx.QueryRow(d.rebind(query), userID).Scan(userFields(&user)...); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("RestoreAccount: %w", ErrNotFound)
+		}
+		return fmt.Errorf("RestoreAccount: %w", err)
+	}
+	restored, err := restoredUser(user.User, backup.User, backup.Version)
+	if err != nil {
+		return fmt.Errorf("RestoreAccount: user: %w", err)
+	}
+	query = `UPDATE "User" SET name = ?, nameEdited = ?, unit = ?, timeZone = ?, bodyweight = ? WHERE userId = ?`
+	if _, err := tx.Exec(d.rebind(query), restored.Name, restored.NameEdited, restored.Unit, restored.TimeZone, restored.Bodyweight, userID); err != nil {
+		return fmt.Errorf("RestoreAccount: user: %w", err)
+	}
+
+	deletes := []string{
+		"DELETE FROM Sets WHERE workoutID IN (SELECT w.workoutID FROM Workouts w JOIN Session s ON s.sessionID = w.sessionID WHERE s.userID = ?)",
+		"DELETE FROM Workouts WHERE sessionID IN (SELECT sessionID FROM Session WHERE userID = ?)",
+		"DELETE FROM Session WHERE userID = ?",
+		"DELETE FROM RoutineExercise WHERE routineID IN (SELECT routineID FROM Routine WHERE userID = ?)",
+		"DELETE FROM Routine WHERE userID = ?",
+		"DELETE FROM ExerciseAlias WHERE exerciseID IN (SELECT exerciseID FROM Exercise WHERE userID = ?)",
+		"DELETE FROM Exercise WHERE userID = ?",
+	}
+	for _, query := range deletes {
+		if _, err := tx.Exec(d.rebind(query), userID); err != nil {
+			return fmt.Errorf("RestoreAccount: clearing account: %w", err)
+		}
+	}
+
+	exerciseIDs := map[int]int{}
+	for _, exercise := range backup.Exercises {
+		exercise.UserID = &userID
+		id, err := insertExercise(d, tx, exercise.Exercise)
+		if err != nil {
+			return fmt.Errorf("RestoreAccount: exercise %s: %w", exercise.Name, err)
+		}
+		exerciseIDs[exercise.Id] = int(id)
+	}
+	// mapExercise resolves a reference to a custom exercise of the backup or,
+	// failing that, to a built-in exercise by name.
+	mapExercise := func(exerciseID int, name string) (int, error) {
+		if id, ok := exerciseIDs[exerciseID]; ok {
+			return id, nil
+		}
+		var id int
+		query := "SELECT exerciseID FROM Exercise WHERE userID IS NULL AND name = ?"
+		if err := tx.QueryRow(d.rebind(query), NormalizeExerciseName(name)).Scan(&id); err != nil {
+			if err == sql.ErrNoRows {
+				return 0, fmt.Errorf("unknown exercise %q: %w", name, ErrInvalidBackup)
+			}
+			return 0, err
+		}
+		return id, nil
+	}
+
+	for _, session := range backup.Sessions {
+		restored, err := restoredSession(session, backup.Version)
+		if err != nil {
+			return fmt.Errorf("RestoreAccount: session %d: %w", session.Id, err)
+		}
+		var sessionID int64
+		query := "INSERT INTO Session (userID, importKey, " + sessionWriteColumns + ") VALUES (?, ?, " + sessionWritePlaceholders + ") RETURNING sessionID"
+		if err := tx.QueryRow(d.rebind(query), append([]any{userID, nullIfEmpty(session.ImportKey)}, sessionValues(restored)...)...).Scan(&sessionID); err != nil {
+			return fmt.Errorf("RestoreAccount: session %d: %w", session.Id, err)
+		}
+		for i, workout := range session.Workouts {
+			exerciseID, err := mapExercise(workout.ExerciseID, workout.ExerciseName)
+			if err != nil {
+				return fmt.Errorf("RestoreAccount: workout %d: %w", workout.Id, err)
+			}
+			position, group, err := restoredLayout(workout, i, len(session.Workouts), backup.Version)
+			if err != nil {
+				return fmt.Errorf("RestoreAccount: workout %d: %w", workout.Id, err)
+			}
+			var workoutID int64
+			query := "INSERT INTO Workouts (sessionID, exerciseID, workoutname, userID, position, groupNumber) VALUES (?, ?, ?, ?, ?, ?) RETURNING workoutID"
+			if err := tx.QueryRow(d.rebind(query), sessionID, exerciseID, workout.WorkoutName, userID, position, group).Scan(&workoutID); err != nil {
+				if isUniqueViolation(err) {
+					return fmt.Errorf("RestoreAccount: workout %d: duplicate workout %s: %w", workout.Id, workout.WorkoutName, ErrInvalidBackup)
+				}
+				return fmt.Errorf("RestoreAccount: workout %d: %w", workout.Id, err)
+			}
+			query = "INSERT INTO Sets (workoutID, importKey, " + setWriteColumns + ") VALUES (?, ?, " + setWritePlaceholders + ")"
+			for _, backupSet := range workout.Sets {
+				set, err := restoredSet(backupSet, backup.Version)
+				if err != nil {
+					return fmt.Errorf("RestoreAccount: set %d: %w", backupSet.Id, err)
+				}
+				if _, err := tx.Exec(d.rebind(query), append([]any{workoutID, nullIfEmpty(backupSet.ImportKey)}, setValues(set)...)...); err != nil {
+					return fmt.Errorf("RestoreAccount: set %d: %w", backupSet.Id, err)
+				}
+			}
+		}
+	}
+
+	for _, routine := range backup.Routines {
+		var routineID int64
+		query := "INSERT INTO Routine (userID, name) VALUES (?, ?) RETURNING routineID"
+		if err := tx.QueryRow(d.rebind(query), userID, routine.Name).Scan(&routineID); err != nil {
+			if isUniqueViolation(err) {
+				return fmt.Errorf("RestoreAccount: duplicate routine %s: %w", routine.Name, ErrInvalidBackup)
+			}
+			return fmt.Errorf("RestoreAccount: routine %d: %w", routine.Id, err)
+		}
+		exercises := make([]RoutineExercise, len(routine.Exercises))
+		for i, exercise := range routine.Exercises {
+			exercise.ExerciseID, err = mapExercise(exercise.ExerciseID, exercise.ExerciseName)
+			if err != nil {
+				return fmt.Errorf("RestoreAccount: routine %d: %w", routine.Id, err)
+			}
+			exercises[i] = exercise
+		}
+		if err := insertRoutineExercises(d, tx, routineID, exercises); err != nil {
+			return fmt.Errorf("RestoreAccount: routine %d: %w", routine.Id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("RestoreAccount: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package database
+
+import "strings"
+
+// Exercise types decide which set fields are meaningful.
+const (
+	ExerciseTypeStrength   = "strength"
+	ExerciseTypeBodyweight = "bodyweight"
+	ExerciseTypeCardio     = "cardio"
+	ExerciseTypeTimed      = "timed"
+	ExerciseTypeCarry      = "carry"
+)
+
+// ExerciseTypes lists the valid values of Exercise.Type.
+var ExerciseTypes = []string{
+	ExerciseTypeStrength,
+	ExerciseTypeBodyweight,
+	ExerciseTypeCardio,
+	ExerciseTypeTimed,
+	ExerciseTypeCarry,
+}
+
+// NormalizeExerciseName lower-cases a name and collapses whitespace so that
+// "Bench  Press" and "bench press" resolve to the same exercise.
+func NormalizeExerciseName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// builtinExercises is the catalog seeded into every store. Names and aliases
+// must be normalized and unique across the whole catalog. Adding entries
+// requires a migration that calls seedExerciseCatalog again.
+var builtinExercises = []Exercise{
+	{Name: "bench press", Aliases: []string{"bench", "bb bench", "barbell bench press", "flat bench"}, PrimaryMuscles: []string{"chest"}, SecondaryMuscles: []string{"triceps", "shoulders"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "incline bench press", Aliases: []string{"incline bench", "incline bb bench"}, PrimaryMuscles: []string{"chest"}, SecondaryMuscles: []string{"shoulders", "triceps"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "dumbbell bench press", Aliases: []string{"db bench", "db bench press", "dumbbell bench"}, PrimaryMuscles: []string{"chest"}, SecondaryMuscles: []string{"triceps", "shoulders"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "incline dumbbell press", Aliases: []string{"incline db press", "incline db bench"}, PrimaryMuscles: []string{"chest"}, SecondaryMuscles: []string{"shoulders", "triceps"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "chest fly", Aliases: []string{"fly", "flyes", "dumbbell fly", "pec fly"}, PrimaryMuscles: []string{"chest"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "push up", Aliases: []string{"push-up", "pushup", "push ups"}, PrimaryMuscles: []string{"chest"}, SecondaryMuscles: []string{"triceps", "shoulders"}, Equipment: "bodyweight", Type: ExerciseTypeBodyweight},
+	{Name: "dip", Aliases: []string{"dips", "chest dip", "tricep dip"}, PrimaryMuscles: []string{"triceps", "chest"}, SecondaryMuscles: []string{"shoulders"}, Equipment: "bodyweight", Type: ExerciseTypeBodyweight},
+	{Name: "overhead press", Aliases: []string{"ohp", "military press", "shoulder press", "press", "standing press"}, PrimaryMuscles: []string{"shoulders"}, SecondaryMuscles: []string{"triceps"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "dumbbell shoulder press", Aliases: []string{"db shoulder press", "seated dumbbell press"}, PrimaryMuscles: []string{"shoulders"}, SecondaryMuscles: []string{"triceps"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "lateral raise", Aliases: []string{"lateral raises", "side raise", "side lateral raise"}, PrimaryMuscles: []string{"shoulders"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "face pull", Aliases: []string{"face pulls"}, PrimaryMuscles: []string{"shoulders"}, SecondaryMuscles: []string{"upper back"}, Equipment: "cable", Type: ExerciseTypeStrength},
+	{Name: "tricep pushdown", Aliases: []string{"pushdown", "triceps pushdown", "cable pushdown"}, PrimaryMuscles: []string{"triceps"}, Equipment: "cable", Type: ExerciseTypeStrength},
+	{Name: "skull crusher", Aliases: []string{"skullcrusher", "skull crushers", "lying tricep extension"}, PrimaryMuscles: []string{"triceps"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "deadlift", Aliases: []string{"dl", "conventional deadlift", "barbell deadlift"}, PrimaryMuscles: []string{"hamstrings", "glutes", "lower back"}, SecondaryMuscles: []string{"quads", "forearms", "upper back"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "romanian deadlift", Aliases: []string{"rdl", "romanian dl"}, PrimaryMuscles: []string{"hamstrings"}, SecondaryMuscles: []string{"glutes", "lower back"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "pull up", Aliases: []string{"pull-up", "pullup", "pull ups"}, PrimaryMuscles: []string{"lats"}, SecondaryMuscles: []string{"biceps", "upper back"}, Equipment: "bodyweight", Type: ExerciseTypeBodyweight},
+	{Name: "chin up", Aliases: []string{"chin-up", "chinup", "chin ups"}, PrimaryMuscles: []string{"lats", "biceps"}, SecondaryMuscles: []string{"upper back"}, Equipment: "bodyweight", Type: ExerciseTypeBodyweight},
+	{Name: "lat pulldown", Aliases: []string{"pulldown", "lat pull down"}, PrimaryMuscles: []string{"lats"}, SecondaryMuscles: []string{"biceps"}, Equipment: "cable", Type: ExerciseTypeStrength},
+	{Name: "barbell row", Aliases: []string{"bent over row", "bb row", "pendlay row"}, PrimaryMuscles: []string{"upper back", "lats"}, SecondaryMuscles: []string{"biceps", "lower back"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "dumbbell row", Aliases: []string{"db row", "one arm row", "single arm dumbbell row"}, PrimaryMuscles: []string{"upper back", "lats"}, SecondaryMuscles: []string{"biceps"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "seated cable row", Aliases: []string{"cable row", "seated row"}, PrimaryMuscles: []string{"upper back", "lats"}, SecondaryMuscles: []string{"biceps"}, Equipment: "cable", Type: ExerciseTypeStrength},
+	{Name: "bicep curl", Aliases: []string{"curl", "curls", "barbell curl", "biceps curl"}, PrimaryMuscles: []string{"biceps"}, SecondaryMuscles: []string{"forearms"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "dumbbell curl", Aliases: []string{"db curl", "dumbbell curls"}, PrimaryMuscles: []string{"biceps"}, SecondaryMuscles: []string{"forearms"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "hammer curl", Aliases: []string{"hammer curls"}, PrimaryMuscles: []string{"biceps", "forearms"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "squat", Aliases: []string{"back squat", "bb squat", "barbell squat", "squats"}, PrimaryMuscles: []string{"quads", "glutes"}, SecondaryMuscles: []string{"hamstrings", "lower back"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "front squat", Aliases: []string{"front squats"}, PrimaryMuscles: []string{"quads"}, SecondaryMuscles: []string{"glutes", "upper back"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "leg press", Aliases: []string{"leg presses"}, PrimaryMuscles: []string{"quads", "glutes"}, Equipment: "machine", Type: ExerciseTypeStrength},
+	{Name: "lunge", Aliases: []string{"lunges", "walking lunge", "dumbbell lunge"}, PrimaryMuscles: []string{"quads", "glutes"}, SecondaryMuscles: []string{"hamstrings"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "bulgarian split squat", Aliases: []string{"split squat", "bss"}, PrimaryMuscles: []string{"quads", "glutes"}, Equipment: "dumbbell", Type: ExerciseTypeStrength},
+	{Name: "leg extension", Aliases: []string{"leg extensions"}, PrimaryMuscles: []string{"quads"}, Equipment: "machine", Type: ExerciseTypeStrength},
+	{Name: "leg curl", Aliases: []string{"leg curls", "hamstring curl", "lying leg curl", "seated leg curl"}, PrimaryMuscles: []string{"hamstrings"}, Equipment: "machine", Type: ExerciseTypeStrength},
+	{Name: "hip thrust", Aliases: []string{"hip thrusts", "barbell hip thrust"}, PrimaryMuscles: []string{"glutes"}, SecondaryMuscles: []string{"hamstrings"}, Equipment: "barbell", Type: ExerciseTypeStrength},
+	{Name: "calf raise", Aliases: []string{"calf raises", "standing calf raise"}, PrimaryMuscles: []string{"calves"}, Equipment: "machine", Type: ExerciseTypeStrength},
+	{Name: "plank", Aliases: []string{"planks", "front plank"}, PrimaryMuscles: []string{"abs"}, Equipment: "bodyweight", Type: ExerciseTypeTimed},
+	{Name: "hanging leg raise", Aliases: []string{"leg raise", "leg raises"}, PrimaryMuscles: []string{"abs"}, SecondaryMuscles: []string{"hip flexors"}, Equipment: "bodyweight", Type: ExerciseTypeBodyweight},
+	{Name: "crunch", Aliases: []string{"crunches", "sit up", "sit-up", "situp"}, PrimaryMuscles: []string{"abs"}, Equipment: "bodyweight", Type: ExerciseTypeBodyweight},
+	{Name: "farmer's carry", Aliases: []string{"farmers carry", "farmer carry", "farmers walk", "farmer's walk"}, PrimaryMuscles: []string{"forearms"}, SecondaryMuscles: []string{"upper back", "abs"}, Equipment: "dumbbell", Type: ExerciseTypeCarry},
+	{Name: "running", Aliases: []string{"run", "treadmill", "jog", "jogging"}, PrimaryMuscles: []string{"cardio"}, Equipment: "none", Type: ExerciseTypeCardio},
+	{Name: "rowing", Aliases: []string{"rower", "rowing machine", "erg"}, PrimaryMuscles: []string{"cardio"}, SecondaryMuscles: []string{"upper back"}, Equipment: "machine", Type: ExerciseTypeCardio},
+	{Name: "cycling", Aliases: []string{"bike", "stationary bike", "spin"}, PrimaryMuscles: []string{"cardio"}, Equipment: "machine", Type: ExerciseTypeCardio},
+	{Name: "jump rope", Aliases: []string{"skipping", "skipping rope"}, PrimaryMuscles: []string{"cardio"}, Equipment: "other", Type: ExerciseTypeCardio},
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// dialect identifies the SQL engine behind a DBConn. Queries are written once
+// with ? placeholders and portable SQL; the dialect only decides placeholder
+// syntax, which migrations to run and how driver errors are classified.
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+// migrationsDir is the embedded directory holding the dialect's migrations.
+func (dl dialect) migrationsDir() string {
+	if dl == dialectPostgres {
+		return "migrations/postgres"
+	}
+	return "migrations/sqlite"
+}
+
+// rebind rewrites ? placeholders into the dialect's native form. None of our
+// queries contain a literal question mark, so no quoting rules are needed.
+func (dl dialect) rebind(query string) string {
+	if dl != dialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isUniqueViolation reports whether err is a unique-constraint failure from
+// either driver.
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
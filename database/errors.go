@@ -0,0 +1,16 @@
+package database
+
+import "errors"
+
+// ErrNotFound is returned when a row does not exist or is not visible to the
+// requesting user.
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidBackup is returned when an account backup cannot be restored
+// because it refers to data that does not exist.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// ErrTokenReused is returned when a refresh token that was already traded in
+// is presented again. Its whole login is revoked, since the token has most
+// likely been stolen.
+var ErrTokenReused = errors.New("refresh token reused")
@@ -0,0 +1,336 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// normalizeExercise canonicalises the name and aliases of an exercise and
+// replaces nil lists with empty ones so every store returns the same shape.
+func normalizeExercise(e Exercise) Exercise {
+	e.Name = NormalizeExerciseName(e.Name)
+	aliases := []string{}
+	seen := map[string]bool{e.Name: true}
+	for _, alias := range e.Aliases {
+		alias = NormalizeExerciseName(alias)
+		if alias == "" || seen[alias] {
+			continue
+		}
+		seen[alias] = true
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	e.Aliases = aliases
+	if e.PrimaryMuscles == nil {
+		e.PrimaryMuscles = []string{}
+	}
+	if e.SecondaryMuscles == nil {
+		e.SecondaryMuscles = []string{}
+	}
+	if e.Type == "" {
+		e.Type = ExerciseTypeStrength
+	}
+	return e
+}
+
+func joinList(values []string) string {
+	return strings.Join(values, ",")
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+func insertExercise(d *DBConn, q queryer, exercise Exercise) (int64, error) {
+	exercise = normalizeExercise(exercise)
+	var userID any
+	if exercise.UserID != nil {
+		userID = *exercise.UserID
+	}
+	var exerciseID int64
+	query := `
+        INSERT INTO Exercise (userID, name, primaryMuscles, secondaryMuscles, equipment, type)
+        VALUES (?, ?, ?, ?, ?, ?)
+        RETURNING exerciseID
+    `
+	err := q.QueryRow(d.rebind(query), userID, exercise.Name, joinList(exercise.PrimaryMuscles),
+		joinList(exercise.SecondaryMuscles), exercise.Equipment, exercise.Type).Scan(&exerciseID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("Exercise already exists: exercise with name %s already exists", exercise.Name)
+		}
+		return 0, fmt.Errorf("insertExercise: %w", err)
+	}
+	for _, alias := range exercise.Aliases {
+		if _, err := q.Exec(d.rebind("INSERT INTO ExerciseAlias (exerciseID, alias) VALUES (?, ?)"), exerciseID, alias); err != nil {
+			return 0, fmt.Errorf("insertExercise: alias %s: %w", alias, err)
+		}
+	}
+	return exerciseID, nil
+}
+
+// findExerciseID resolves a name or alias to an exercise visible to userID,
+// preferring an exact name match over an alias.
+func findExerciseID(d *DBConn, q queryer, name string, userID int) (int, error) {
+	name = NormalizeExerciseName(name)
+	query := `
+        SELECT e.exerciseID
+        FROM Exercise e
+        WHERE (e.userID IS NULL OR e.userID = ?)
+          AND (e.name = ? OR e.exerciseID IN (SELECT a.exerciseID FROM ExerciseAlias a WHERE a.alias = ?))
+        ORDER BY CASE WHEN e.name = ? THEN 0 ELSE 1 END, e.exerciseID
+        LIMIT 1
+    `
+	var exerciseID int
+	if err := q.QueryRow(d.rebind(query), userID, name, name, name).Scan(&exerciseID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	return exerciseID, nil
+}
+
+// seedExerciseCatalog inserts any missing built-in exercises and then points
+// workouts that have no exercise yet at one, creating a custom exercise for
+// names that match nothing in the catalog. Workout names are rewritten to the
+// canonical exercise name unless that would collide within the session.
+func seedExerciseCatalog(d *DBConn, tx *sql.Tx) error {
+	for _, exercise := range builtinExercises {
+		var exerciseID int
+		err := tx.QueryRow(d.rebind("SELECT exerciseID FROM Exercise WHERE userID IS NULL AND name = ?"), exercise.Name).Scan(&exerciseID)
+		if err == nil {
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("seedExerciseCatalog: %w", err)
+		}
+		if _, err := insertExercise(d, tx, exercise); err != nil {
+			return fmt.Errorf("seedExerciseCatalog: %w", err)
+		}
+	}
+
+	type workoutName struct {
+		userID int
+		name   string
+	}
+	rows, err := tx.Query("SELECT DISTINCT userID, workoutname FROM Workouts WHERE exerciseID IS NULL")
+	if err != nil {
+		return fmt.Errorf("seedExerciseCatalog: %w", err)
+	}
+	var unmapped []workoutName
+	for rows.Next() {
+		var wn workoutName
+		if err := rows.Scan(&wn.userID, &wn.name); err != nil {
+			rows.Close()
+			return fmt.Errorf("seedExerciseCatalog: %w", err)
+		}
+		unmapped = append(unmapped, wn)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("seedExerciseCatalog: %w", err)
+	}
+
+	for _, wn := range unmapped {
+		exerciseID, err := findExerciseID(d, tx, wn.name, wn.userID)
+		if errors.Is(err, ErrNotFound) {
+			userID := wn.userID
+			var id int64
+			id, err = insertExercise(d, tx, Exercise{UserID: &userID, Name: wn.name})
+			exerciseID = int(id)
+		}
+		if err != nil {
+			return fmt.Errorf("seedExerciseCatalog: mapping %q: %w", wn.name, err)
+		}
+		query := "UPDATE Workouts SET exerciseID = ? WHERE userID = ? AND workoutname = ? AND exerciseID IS NULL"
+		if _, err := tx.Exec(d.rebind(query), exerciseID, wn.userID, wn.name); err != nil {
+			return fmt.Errorf("seedExerciseCatalog: mapping %q: %w", wn.name, err)
+		}
+	}
+
+	type rename struct {
+		workoutID int
+		sessionID int
+		userID    int
+		name      string
+	}
+	rows, err = tx.Query(`
+        SELECT w.workoutID, w.sessionID, w.userID, e.name
+        FROM Workouts w
+        JOIN Exercise e ON e.exerciseID = w.exerciseID
+        WHERE w.workoutname <> e.name
+        ORDER BY w.workoutID
+    `)
+	if err != nil {
+		return fmt.Errorf("seedExerciseCatalog: %w", err)
+	}
+	var renames []rename
+	for rows.Next() {
+		var rn rename
+		if err := rows.Scan(&rn.workoutID, &rn.sessionID, &rn.userID, &rn.name); err != nil {
+			rows.Close()
+			return fmt.Errorf("seedExerciseCatalog: %w", err)
+		}
+		renames = append(renames, rn)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("seedExerciseCatalog: %w", err)
+	}
+
+	for _, rn := range renames {
+		var taken int
+		query := "SELECT COUNT(*) FROM Workouts WHERE sessionID = ? AND userID = ? AND workoutname = ?"
+		if err := tx.QueryRow(d.rebind(query), rn.sessionID, rn.userID, rn.name).Scan(&taken); err != nil {
+			return fmt.Errorf("seedExerciseCatalog: %w", err)
+		}
+		if taken > 0 {
+			continue
+		}
+		if _, err := tx.Exec(d.rebind("UPDATE Workouts SET workoutname = ? WHERE workoutID = ?"), rn.name, rn.workoutID); err != nil {
+			return fmt.Errorf("seedExerciseCatalog: %w", err)
+		}
+	}
+	return nil
+}
+
+// scanExercises reads exercise rows and attaches their aliases.
+func (d *DBConn) scanExercises(rows *sql.Rows, aliases map[int][]string) ([]ExerciseRow, error) {
+	defer rows.Close()
+	var exercises []ExerciseRow
+	for rows.Next() {
+		var exercise ExerciseRow
+		var userID sql.NullInt64
+		var primary, secondary string
+		if err := rows.Scan(&exercise.Id, &userID, &exercise.Name, &primary, &secondary, &exercise.Equipment, &exercise.Type); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			exercise.UserID = &id
+		}
+		exercise.PrimaryMuscles = splitList(primary)
+		exercise.SecondaryMuscles = splitList(secondary)
+		exercise.Aliases = aliases[exercise.Id]
+		if exercise.Aliases == nil {
+			exercise.Aliases = []string{}
+		}
+		exercises = append(exercises, exercise)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return exercises, nil
+}
+
+func (d *DBConn) queryAliases(q queryer, query string, args ...any) (map[int][]string, error) {
+	rows, err := q.Query(d.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	aliases := map[int][]string{}
+	for rows.Next() {
+		var exerciseID int
+		var alias string
+		if err := rows.Scan(&exerciseID, &alias); err != nil {
+			return nil, err
+		}
+		aliases[exerciseID] = append(aliases[exerciseID], alias)
+	}
+	return aliases, rows.Err()
+}
+
+// GetExercises returns the built-in catalog plus the user's custom exercises,
+// ordered by name.
+func (d *DBConn) GetExercises(userID int) ([]ExerciseRow, error) {
+	exercises, err := listExercises(d, d.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetExercises: %w", err)
+	}
+	return exercises, nil
+}
+
+// listExercises reads the exercises GetExercises returns through q.
+func listExercises(d *DBConn, q queryer, userID int) ([]ExerciseRow, error) {
+	aliases, err := d.queryAliases(q, `
+        SELECT a.exerciseID, a.alias
+        FROM ExerciseAlias a
+        JOIN Exercise e ON e.exerciseID = a.exerciseID
+        WHERE e.userID IS NULL OR e.userID = ?
+        ORDER BY a.alias
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        SELECT exerciseID, userID, name, primaryMuscles, secondaryMuscles, equipment, type
+        FROM Exercise
+        WHERE userID IS NULL OR userID = ?
+        ORDER BY name, exerciseID
+    `
+	rows, err := q.Query(d.rebind(query), userID)
+	if err != nil {
+		return nil, err
+	}
+	return d.scanExercises(rows, aliases)
+}
+
+func (d *DBConn) GetExerciseById(exerciseID int) (ExerciseRow, error) {
+	aliases, err := d.queryAliases(d.db, "SELECT exerciseID, alias FROM ExerciseAlias WHERE exerciseID = ? ORDER BY alias", exerciseID)
+	if err != nil {
+		return ExerciseRow{}, fmt.Errorf("GetExerciseById: %w", err)
+	}
+	query := `
+        SELECT exerciseID, userID, name, primaryMuscles, secondaryMuscles, equipment, type
+        FROM Exercise
+        WHERE exerciseID = ?
+    `
+	rows, err := d.db.Query(d.rebind(query), exerciseID)
+	if err != nil {
+		return ExerciseRow{}, fmt.Errorf("GetExerciseById: %w", err)
+	}
+	exercises, err := d.scanExercises(rows, aliases)
+	if err != nil {
+		return ExerciseRow{}, fmt.Errorf("GetExerciseById: %w", err)
+	}
+	if len(exercises) == 0 {
+		return ExerciseRow{}, fmt.Errorf("GetExerciseById: %w", ErrNotFound)
+	}
+	return exercises[0], nil
+}
+
+// FindExercise resolves a name or alias to a built-in exercise or one of the
+// user's custom exercises. It returns ErrNotFound when nothing matches.
+func (d *DBConn) FindExercise(name string, userID int) (ExerciseRow, error) {
+	exerciseID, err := findExerciseID(d, d.db, name, userID)
+	if err != nil {
+		return ExerciseRow{}, fmt.Errorf("FindExercise: %w", err)
+	}
+	return d.GetExerciseById(exerciseID)
+}
+
+// CreateExercise adds a custom exercise and its aliases in one transaction.
+func (d *DBConn) CreateExercise(exercise Exercise) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("CreateExercise: %w", err)
+	}
+	defer tx.Rollback()
+
+	exerciseID, err := insertExercise(d, tx, exercise)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("CreateExercise: %w", err)
+	}
+	return exerciseID, nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// insertUserQuery adds a user filled in by newUser, with the values of
+// userValues, and returns its ID.
+const insertUserQuery = `INSERT INTO "User" (email, name, unit, timeZone, picture, locale, bodyweight) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING userId`
+
+// userColumns are the User columns read by userFields, in order.
+const userColumns = "userId, email, name, unit, timeZone, COALESCE(picture, ''), COALESCE(locale, ''), bodyweight, nameEdited"
+
+// userFields returns the scan destinations for userColumns.
+func userFields(user *UserRow) []any {
+	return []any{&user.Id, &user.Email, &user.Name, &user.Unit, &user.TimeZone, &user.Picture, &user.Locale, &user.Bodyweight, &user.NameEdited}
+}
+
+// userValues returns the values for insertUserQuery.
+func userValues(user User) []any {
+	return []any{user.Email, user.Name, user.Unit, user.TimeZone, nullIfEmpty(user.Picture), nullIfEmpty(user.Locale), user.Bodyweight}
+}
+
+// newUser fills in the preferences a new user leaves out.
+func newUser(user User) User {
+	if user.Unit == "" {
+		user.Unit = UnitKg
+	}
+	if user.TimeZone == "" {
+		user.TimeZone = DefaultTimeZone
+	}
+	return user
+}
+
+// userProfileVersion is the schema version that added the user's bodyweight
+// and nameEdited, for reading older backups.
+const userProfileVersion = 15
+
+// restoredUser returns user with the preferences saved in a backup of the
+// given schema version: name, unit, time zone and bodyweight. Preferences the
+// backup predates keep their current values.
+func restoredUser(user User, backup User, version int) (User, error) {
+	user.Name = backup.Name
+	if version >= unitsVersion {
+		user.Unit = backup.Unit
+	}
+	if version >= sessionDetailsVersion {
+		user.TimeZone = backup.TimeZone
+	}
+	if version >= userProfileVersion {
+		user.Bodyweight = backup.Bodyweight
+		user.NameEdited = backup.NameEdited
+	}
+	if !validUnit(user.Unit) {
+		return user, fmt.Errorf("unknown unit %q: %w", user.Unit, ErrInvalidBackup)
+	}
+	if !ValidTimeZone(user.TimeZone) {
+		return user, fmt.Errorf("unknown time zone %q: %w", user.TimeZone, ErrInvalidBackup)
+	}
+	if user.Bodyweight != nil && *user.Bodyweight <= 0 {
+		return user, fmt.Errorf("invalid bodyweight: %w", ErrInvalidBackup)
+	}
+	return user, nil
+}
+
+// GetUserByIdentity returns the user linked to the provider's subject.
+func (d *DBConn) GetUserByIdentity(provider string, subject string) (UserRow, error) {
+	query := `
+        SELECT ` + userColumns + `
+        FROM "User"
+        WHERE userId IN (SELECT userID FROM UserIdentity WHERE provider = ? AND subject = ?)
+    `
+	var user UserRow
+	if err := d.db.QueryRow(d.rebind(query), provider, subject).Scan(userFields(&user)...); err != nil {
+		if err == sql.ErrNoRows {
+			return user, fmt.Errorf("GetUserByIdentity: %w", ErrNotFound)
+		}
+		return user, fmt.Errorf("GetUserByIdentity: %w", err)
+	}
+	return user, nil
+}
+
+// CreateUserWithIdentity adds a user and links identity, whose UserID is
+// ignored, to it in one transaction.
+func (d *DBConn) CreateUserWithIdentity(user User, identity UserIdentity) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("CreateUserWithIdentity: %w", err)
+	}
+	defer tx.Rollback()
+
+	user = newUser(user)
+	var userID int64
+	if err := tx.QueryRow(d.rebind(insertUserQuery), userValues(user)...).Scan(&userID); err != nil {
+		return 0, fmt.Errorf("CreateUserWithIdentity: %w", err)
+	}
+	query := "INSERT INTO UserIdentity (userID, provider, subject) VALUES (?, ?, ?)"
+	if _, err := tx.Exec(d.rebind(query), userID, identity.Provider, identity.Subject); err != nil {
+		return 0, fmt.Errorf("CreateUserWithIdentity: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("CreateUserWithIdentity: %w", err)
+	}
+	return userID, nil
+}
+
+// LinkIdentity links an existing user to the provider's subject.
+func (d *DBConn) LinkIdentity(identity UserIdentity) error {
+	query := "INSERT INTO UserIdentity (userID, provider, subject) VALUES (?, ?, ?)"
+	if _, err := d.db.Exec(d.rebind(query), identity.UserID, identity.Provider, identity.Subject); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("Identity already exists: %s subject %s is linked to another user", identity.Provider, identity.Subject)
+		}
+		return fmt.Errorf("LinkIdentity: %w", err)
+	}
+	return nil
+}
+
+// UserHasIdentity reports whether any provider identity is linked to the user.
+func (d *DBConn) UserHasIdentity(userID int) (bool, error) {
+	var exists int
+	query := "SELECT 1 FROM UserIdentity WHERE userID = ? LIMIT 1"
+	if err := d.db.QueryRow(d.rebind(query), userID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("UserHasIdentity: %w", err)
+	}
+	return true, nil
+}
+
+// ClaimAccount links identity to a password account whose email it has
+// verified, in one transaction. Until then nobody had proven the email was
+// theirs, so the password, logins and API tokens of the account are removed.
+func (d *DBConn) ClaimAccount(identity UserIdentity) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ClaimAccount: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []struct {
+		query string
+		args  []any
+	}{
+		{"DELETE FROM PasswordCredential WHERE userID = ?", []any{identity.UserID}},
+		{"UPDATE RefreshToken SET revokedAt = ? WHERE userID = ? AND revokedAt IS NULL", []any{NowDateTime(), identity.UserID}},
+		{"DELETE FROM ApiToken WHERE userID = ?", []any{identity.UserID}},
+		{"INSERT INTO UserIdentity (userID, provider, subject) VALUES (?, ?, ?)", []any{identity.UserID, identity.Provider, identity.Subject}},
+	}
+	for _, q := range queries {
+		if _, err := tx.Exec(d.rebind(q.query), q.args...); err != nil {
+			if isUniqueViolation(err) {
+				return fmt.Errorf("Identity already exists: %s subject %s is linked to another user", identity.Provider, identity.Subject)
+			}
+			return fmt.Errorf("ClaimAccount: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ClaimAccount: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ImportSession writes an imported session, its workouts and sets in one
+// transaction. The session is matched on its ImportKey, workouts on their
+// exercise, which is unique in a session, and sets on their ImportKey, so only
+// what is missing is added.
+func (d *DBConn) ImportSession(userID int, session ImportedSession) (ImportResult, error) {
+	var result ImportResult
+	tx, err := d.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("ImportSession: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := "SELECT sessionID FROM Session WHERE userID = ? AND importKey = ?"
+	err = tx.QueryRow(d.rebind(query), userID, session.ImportKey).Scan(&result.SessionID)
+	if err == sql.ErrNoRows {
+		imported := Session{DateTime: session.DateTime, EndDateTime: session.EndDateTime, TimeZone: session.TimeZone, Title: session.Title}
+		query = "INSERT INTO Session (userID, importKey, " + sessionWriteColumns + ") VALUES (?, ?, " + sessionWritePlaceholders + ") RETURNING sessionID"
+		err = tx.QueryRow(d.rebind(query), append([]any{userID, session.ImportKey}, sessionValues(imported)...)...).Scan(&result.SessionID)
+		result.SessionCreated = true
+	}
+	if err != nil {
+		return result, fmt.Errorf("ImportSession: session: %w", err)
+	}
+
+	// New workouts go after those already in the session, in file order, and
+	// their groups are numbered after the session's own.
+	var position, groups int
+	query = "SELECT COALESCE(MAX(position) + 1, 0), COALESCE(MAX(groupNumber), 0) FROM Workouts WHERE sessionID = ?"
+	if err := tx.QueryRow(d.rebind(query), result.SessionID).Scan(&position, &groups); err != nil {
+		return result, fmt.Errorf("ImportSession: %w", err)
+	}
+	for _, workout := range session.Workouts {
+		var workoutID int64
+		query := "SELECT workoutID FROM Workouts WHERE sessionID = ? AND exerciseID = ?"
+		err := tx.QueryRow(d.rebind(query), result.SessionID, workout.ExerciseID).Scan(&workoutID)
+		if err == sql.ErrNoRows {
+			query = "INSERT INTO Workouts (sessionID, exerciseID, workoutname, userID, position, groupNumber) VALUES (?, ?, ?, ?, ?, ?) RETURNING workoutID"
+			err = tx.QueryRow(d.rebind(query), result.SessionID, workout.ExerciseID, workout.WorkoutName, userID, position, importedGroup(workout.Group, groups)).Scan(&workoutID)
+			position++
+		}
+		if err != nil {
+			return result, fmt.Errorf("ImportSession: workout %s: %w", workout.WorkoutName, err)
+		}
+
+		query = `
+            INSERT INTO Sets (workoutID, importKey, ` + setWriteColumns + `) VALUES (?, ?, ` + setWritePlaceholders + `)
+            ON CONFLICT (workoutID, importKey) DO NOTHING
+        `
+		for _, set := range workout.Sets {
+			res, err := tx.Exec(d.rebind(query), append([]any{workoutID, set.ImportKey}, setValues(set.Set)...)...)
+			if err != nil {
+				return result, fmt.Errorf("ImportSession: set %s: %w", set.ImportKey, err)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return result, fmt.Errorf("ImportSession: %w", err)
+			}
+			if n == 0 {
+				result.SetsSkipped++
+			} else {
+				result.SetsCreated++
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("ImportSession: %w", err)
+	}
+	return result, nil
+}
+
+// importedGroup numbers an imported workout's group after the groups the
+// session already has.
+func importedGroup(group int, existing int) int {
+	if group == 0 {
+		return 0
+	}
+	return existing + group
+}
@@ -0,0 +1,1387 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store. It mirrors the behaviour of DBConn,
+// including ordering and the one-workout-per-exercise constraint, so handlers
+// can be exercised without a database file.
+type MemoryStore struct {
+	mu        sync.Mutex
+	lastIDs   map[string]int
+	users     map[int]UserRow
+	sessions  map[int]SessionRow
+	workouts  map[int]WorkoutRow
+	sets      map[int]SetRow
+	exercises map[int]ExerciseRow
+	routines  map[int]RoutineRow
+	tokens    map[int]RefreshTokenRow
+	apiTokens map[int]ApiTokenRow
+	passwords map[int]PasswordCredential
+	// identities are the rows of UserIdentity.
+	identities []UserIdentity
+	// sessionImportKeys and setImportKeys stand in for the importKey
+	// columns, keyed by sessionID and setID.
+	sessionImportKeys map[int]string
+	setImportKeys     map[int]string
+	// apiTokenHashes stands in for the tokenHash column, keyed by tokenID.
+	apiTokenHashes map[int]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	m := &MemoryStore{
+		lastIDs:   map[string]int{},
+		users:     map[int]UserRow{},
+		sessions:  map[int]SessionRow{},
+		workouts:  map[int]WorkoutRow{},
+		sets:      map[int]SetRow{},
+		exercises: map[int]ExerciseRow{},
+		routines:  map[int]RoutineRow{},
+		tokens:    map[int]RefreshTokenRow{},
+		apiTokens: map[int]ApiTokenRow{},
+		passwords: map[int]PasswordCredential{},
+
+		sessionImportKeys: map[int]string{},
+		apiTokenHashes:    map[int]string{},
+		setImportKeys:     map[int]string{},
+	}
+	for _, exercise := range builtinExercises {
+		id := m.nextID("Exercise")
+		m.exercises[id] = ExerciseRow{Id: id, Exercise: normalizeExercise(exercise)}
+	}
+	return m
+}
+
+// nextID hands out increasing per-table IDs, like AUTOINCREMENT.
+func (m *MemoryStore) nextID(table string) int {
+	m.lastIDs[table]++
+	return m.lastIDs[table]
+}
+
+func (m *MemoryStore) CloseConn() error {
+	return nil
+}
+
+func (m *MemoryStore) CreateUser(user User) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.createUserLocked(user)
+}
+
+// createUserLocked adds a user with a unique email. The caller must hold m.mu.
+func (m *MemoryStore) createUserLocked(user User) (int64, error) {
+	for _, u := range m.users {
+		if u.Email == user.Email {
+			return 0, fmt.Errorf("CreateUser: user with email %s already exists", user.Email)
+		}
+	}
+	id := m.nextID("User")
+	m.users[id] = UserRow{Id: id, User: newUser(user)}
+	return int64(id), nil
+}
+
+func (m *MemoryStore) GetUserByIdentity(provider string, subject string) (UserRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, identity := range m.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return m.users[identity.UserID], nil
+		}
+	}
+	return UserRow{}, fmt.Errorf("GetUserByIdentity: %w", ErrNotFound)
+}
+
+func (m *MemoryStore) CreateUserWithIdentity(user User, identity UserIdentity) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, i := range m.identities {
+		if i.Provider == identity.Provider && i.Subject == identity.Subject {
+			return 0, fmt.Errorf("CreateUserWithIdentity: %s subject %s is linked to another user", identity.Provider, identity.Subject)
+		}
+	}
+	id, err := m.createUserLocked(user)
+	if err != nil {
+		return 0, fmt.Errorf("CreateUserWithIdentity: %w", err)
+	}
+	identity.UserID = int(id)
+	m.identities = append(m.identities, identity)
+	return id, nil
+}
+
+func (m *MemoryStore) LinkIdentity(identity UserIdentity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, i := range m.identities {
+		if i.Provider == identity.Provider && i.Subject == identity.Subject {
+			return fmt.Errorf("Identity already exists: %s subject %s is linked to another user", identity.Provider, identity.Subject)
+		}
+	}
+	m.identities = append(m.identities, identity)
+	return nil
+}
+
+func (m *MemoryStore) UserHasIdentity(userID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, i := range m.identities {
+		if i.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) ClaimAccount(identity UserIdentity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, i := range m.identities {
+		if i.Provider == identity.Provider && i.Subject == identity.Subject {
+			return fmt.Errorf("Identity already exists: %s subject %s is linked to another user", identity.Provider, identity.Subject)
+		}
+	}
+	delete(m.passwords, identity.UserID)
+	m.revokeTokensLocked(func(t RefreshTokenRow) bool { return t.UserID == identity.UserID })
+	for id, t := range m.apiTokens {
+		if t.UserID == identity.UserID {
+			delete(m.apiTokens, id)
+			delete(m.apiTokenHashes, id)
+		}
+	}
+	m.identities = append(m.identities, identity)
+	return nil
+}
+
+func (m *MemoryStore) CreateUserWithPassword(user User, passwordHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Email == user.Email {
+			return 0, fmt.Errorf("User already exists: user with email %s already exists", user.Email)
+		}
+	}
+	id, err := m.createUserLocked(user)
+	if err != nil {
+		return 0, fmt.Errorf("CreateUserWithPassword: %w", err)
+	}
+	m.passwords[int(id)] = PasswordCredential{UserID: int(id), PasswordHash: passwordHash}
+	return id, nil
+}
+
+func (m *MemoryStore) GetPasswordCredential(userID int) (PasswordCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	credential, ok := m.passwords[userID]
+	if !ok {
+		return credential, fmt.Errorf("GetPasswordCredential: %w", ErrNotFound)
+	}
+	return credential, nil
+}
+
+func (m *MemoryStore) SetPassword(userID int, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.passwords[userID] = PasswordCredential{UserID: userID, PasswordHash: passwordHash}
+	return nil
+}
+
+func (m *MemoryStore) RecordFailedLogin(userID int, maxAttempts int, lockUntil string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	credential, ok := m.passwords[userID]
+	if !ok {
+		return false, fmt.Errorf("RecordFailedLogin: %w", ErrNotFound)
+	}
+	credential.FailedAttempts++
+	locked := credential.FailedAttempts >= maxAttempts
+	if locked {
+		credential.FailedAttempts = 0
+		credential.LockedUntil = lockUntil
+	}
+	m.passwords[userID] = credential
+	return locked, nil
+}
+
+func (m *MemoryStore) ResetFailedLogins(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if credential, ok := m.passwords[userID]; ok {
+		credential.FailedAttempts = 0
+		credential.LockedUntil = ""
+		m.passwords[userID] = credential
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetUserByEmail(email string) (UserRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return UserRow{}, fmt.Errorf("no user found: %w", ErrNotFound)
+}
+
+func (m *MemoryStore) GetUserById(userID int) (UserRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return UserRow{}, fmt.Errorf("GetUserById: %w", ErrNotFound)
+	}
+	return u, nil
+}
+
+func (m *MemoryStore) UpdateUser(userID int, user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[userID]; ok {
+		u.Name = user.Name
+		u.NameEdited = user.NameEdited
+		u.Unit = user.Unit
+		u.TimeZone = user.TimeZone
+		u.Bodyweight = user.Bodyweight
+		m.users[userID] = u
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserProfile(userID int, user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[userID]; ok {
+		if !u.NameEdited {
+			u.Name = user.Name
+		}
+		u.Picture = user.Picture
+		u.Locale = user.Locale
+		m.users[userID] = u
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateRefreshToken(token RefreshToken) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := NowDateTime()
+	for id, t := range m.tokens {
+		if t.UserID == token.UserID && t.ExpiresAt < now {
+			delete(m.tokens, id)
+		}
+	}
+	return int64(m.insertRefreshTokenLocked(token)), nil
+}
+
+// insertRefreshTokenLocked stores a new, unused token. The caller must hold
+// m.mu.
+func (m *MemoryStore) insertRefreshTokenLocked(token RefreshToken) int {
+	id := m.nextID("RefreshToken")
+	token.UsedAt, token.RevokedAt = "", ""
+	m.tokens[id] = RefreshTokenRow{Id: id, RefreshToken: token}
+	return id
+}
+
+func (m *MemoryStore) GetRefreshToken(tokenHash string) (RefreshTokenRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+	return RefreshTokenRow{}, fmt.Errorf("GetRefreshToken: %w", ErrNotFound)
+}
+
+func (m *MemoryStore) RotateRefreshToken(tokenHash string, next RefreshToken) (RefreshTokenRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var old RefreshTokenRow
+	found := false
+	for _, t := range m.tokens {
+		if t.TokenHash == tokenHash {
+			old, found = t, true
+			break
+		}
+	}
+	now := NowDateTime()
+	if !found || old.RevokedAt != "" || old.ExpiresAt <= now {
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", ErrNotFound)
+	}
+	if old.UsedAt != "" {
+		m.revokeTokensLocked(func(t RefreshTokenRow) bool { return t.FamilyID == old.FamilyID })
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", ErrTokenReused)
+	}
+	old.UsedAt = now
+	m.tokens[old.Id] = old
+	next.UserID, next.FamilyID = old.UserID, old.FamilyID
+	id := m.insertRefreshTokenLocked(next)
+	return m.tokens[id], nil
+}
+
+func (m *MemoryStore) RefreshTokenFamilyActive(familyID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == "" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) RevokeRefreshTokenFamily(familyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokeTokensLocked(func(t RefreshTokenRow) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (m *MemoryStore) RevokeUserRefreshTokens(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokeTokensLocked(func(t RefreshTokenRow) bool { return t.UserID == userID })
+	return nil
+}
+
+// revokeTokensLocked revokes the tokens match selects that are still live. The
+// caller must hold m.mu.
+func (m *MemoryStore) revokeTokensLocked(match func(RefreshTokenRow) bool) {
+	now := NowDateTime()
+	for id, t := range m.tokens {
+		if match(t) && t.RevokedAt == "" {
+			t.RevokedAt = now
+			m.tokens[id] = t
+		}
+	}
+}
+
+func (m *MemoryStore) CreateApiToken(token ApiToken, tokenHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.apiTokens {
+		if t.UserID == token.UserID && t.Name == token.Name {
+			return 0, fmt.Errorf("Token already exists: token with name %s already exists for user %d", token.Name, token.UserID)
+		}
+	}
+	id := m.nextID("ApiToken")
+	token.CreatedAt, token.LastUsedAt = NowDateTime(), ""
+	m.apiTokens[id] = ApiTokenRow{Id: id, ApiToken: token}
+	m.apiTokenHashes[id] = tokenHash
+	return int64(id), nil
+}
+
+func (m *MemoryStore) GetApiTokensByUserId(userID int) ([]ApiTokenRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tokens := []ApiTokenRow{}
+	for _, t := range m.apiTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Id > tokens[j].Id })
+	return tokens, nil
+}
+
+func (m *MemoryStore) GetApiTokenById(tokenID int) (ApiTokenRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.apiTokens[tokenID]
+	if !ok {
+		return ApiTokenRow{}, fmt.Errorf("GetApiTokenById: %w", ErrNotFound)
+	}
+	return t, nil
+}
+
+func (m *MemoryStore) GetApiTokenByHash(tokenHash string) (ApiTokenRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, hash := range m.apiTokenHashes {
+		if hash == tokenHash {
+			return m.apiTokens[id], nil
+		}
+	}
+	return ApiTokenRow{}, fmt.Errorf("GetApiTokenByHash: %w", ErrNotFound)
+}
+
+func (m *MemoryStore) TouchApiToken(tokenID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.apiTokens[tokenID]
+	if !ok {
+		return nil
+	}
+	now := time.Now().UTC()
+	if t.LastUsedAt == "" || t.LastUsedAt < now.Add(-apiTokenTouchEvery).Format(DateTimeLayout) {
+		t.LastUsedAt = now.Format(DateTimeLayout)
+		m.apiTokens[tokenID] = t
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteApiToken(tokenID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.apiTokens, tokenID)
+	delete(m.apiTokenHashes, tokenID)
+	return nil
+}
+
+func (m *MemoryStore) ApiTokenBelongsToUser(tokenID int, userID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.apiTokens[tokenID]
+	return ok && t.UserID == userID, nil
+}
+
+func (m *MemoryStore) CreateSessionForUser(session Session) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.nextID("Session")
+	m.sessions[id] = SessionRow{Id: id, Session: newSession(session)}
+	return int64(id), nil
+}
+
+func (m *MemoryStore) GetSessionsByUserId(userId int, filter SessionFilter) ([]SessionRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sessions []SessionRow
+	for _, s := range m.sessions {
+		if m.sessionMatchesLocked(s, userId, filter, true) {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].DateTime != sessions[j].DateTime {
+			return sessions[i].DateTime > sessions[j].DateTime
+		}
+		return sessions[i].Id > sessions[j].Id
+	})
+	if filter.Limit > 0 && len(sessions) > filter.Limit {
+		sessions = sessions[:filter.Limit]
+	}
+	return sessions, nil
+}
+
+func (m *MemoryStore) CountSessions(userID int, filter SessionFilter) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, s := range m.sessions {
+		if m.sessionMatchesLocked(s, userID, filter, false) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// sessionMatchesLocked mirrors sessionConditions. The caller must hold m.mu.
+func (m *MemoryStore) sessionMatchesLocked(s SessionRow, userID int, filter SessionFilter, paged bool) bool {
+	switch {
+	case s.UserID != userID:
+		return false
+	case filter.From != "" && s.DateTime < filter.From:
+		return false
+	case filter.To != "" && s.DateTime >= filter.To:
+		return false
+	case paged && filter.After != nil && (s.DateTime > filter.After.DateTime || s.DateTime == filter.After.DateTime && s.Id >= filter.After.Id):
+		return false
+	}
+	if filter.ExerciseID == 0 {
+		return true
+	}
+	for _, w := range m.workouts {
+		if w.SessionID == s.Id && w.UserID == userID && w.ExerciseID == filter.ExerciseID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) GetSessionVolumes(sessionIDs []int) (map[int]float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	volumes := map[int]float64{}
+	for _, st := range m.sets {
+		w := m.workouts[st.WorkoutID]
+		if slices.Contains(sessionIDs, w.SessionID) && st.CountsTowardsRecords() {
+			volumes[w.SessionID] += st.Weight * float64(st.NumberOfReps)
+		}
+	}
+	return volumes, nil
+}
+
+func (m *MemoryStore) GetSessionById(sessionID int) (SessionRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok {
+		return SessionRow{}, fmt.Errorf("GetSessionById: %w", ErrNotFound)
+	}
+	return s, nil
+}
+
+func (m *MemoryStore) UpdateSession(sessionID int, session Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[sessionID]; ok {
+		session.UserID = s.UserID
+		s.Session = newSession(session)
+		m.sessions[sessionID] = s
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteSession(sessionID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, w := range m.workouts {
+		if w.SessionID == sessionID {
+			m.deleteWorkoutLocked(id)
+		}
+	}
+	delete(m.sessions, sessionID)
+	delete(m.sessionImportKeys, sessionID)
+	return nil
+}
+
+func (m *MemoryStore) SessionBelongsToUser(sessionID int, userID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	return ok && s.UserID == userID, nil
+}
+
+// workoutExerciseTakenLocked mirrors the unique index on Workouts
+// (sessionID, exerciseID). ignoreID excludes the workout being changed.
+func (m *MemoryStore) workoutExerciseTakenLocked(sessionID int, exerciseID int, ignoreID int) bool {
+	for id, w := range m.workouts {
+		if id != ignoreID && w.SessionID == sessionID && w.ExerciseID == exerciseID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) CreateWorkoutForSession(sessionId int, exerciseID int, workoutname string, userId int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.workoutExerciseTakenLocked(sessionId, exerciseID, 0) {
+		return 0, fmt.Errorf("Workout already exists: session %d already has a workout of exercise %d", sessionId, exerciseID)
+	}
+	for id, w := range m.workouts {
+		if w.SessionID == sessionId {
+			w.Position++
+			m.workouts[id] = w
+		}
+	}
+	id := m.nextID("Workouts")
+	m.workouts[id] = WorkoutRow{Id: id, Workout: Workout{SessionID: sessionId, WorkoutName: workoutname, UserID: userId, ExerciseID: exerciseID}}
+	return int64(id), nil
+}
+
+func (m *MemoryStore) GetWorkoutsBySessionId(sessionId int) ([]WorkoutRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var workouts []WorkoutRow
+	for _, w := range m.workouts {
+		if w.SessionID == sessionId {
+			workouts = append(workouts, w)
+		}
+	}
+	sortWorkouts(workouts)
+	return workouts, nil
+}
+
+// sortWorkouts orders a session's workouts like workoutOrder.
+func sortWorkouts(workouts []WorkoutRow) {
+	sort.Slice(workouts, func(i, j int) bool { return workoutBefore(workouts[i], workouts[j]) })
+}
+
+// workoutBefore reports whether a is listed before b in their session.
+func workoutBefore(a WorkoutRow, b WorkoutRow) bool {
+	if a.Position != b.Position {
+		return a.Position < b.Position
+	}
+	return a.Id > b.Id
+}
+
+func (m *MemoryStore) ArrangeWorkouts(sessionID int, groups [][]int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, layout := range arrangeLayout(groups) {
+		w, ok := m.workouts[id]
+		if !ok || w.SessionID != sessionID {
+			continue
+		}
+		w.Position = layout.position
+		w.Group = layout.group
+		m.workouts[id] = w
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetWorkoutById(workoutID int) (WorkoutRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.workouts[workoutID]
+	if !ok {
+		return WorkoutRow{}, fmt.Errorf("GetWorkoutById: %w", ErrNotFound)
+	}
+	return w, nil
+}
+
+func (m *MemoryStore) GetLastWorkoutID(exerciseID int, userID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []int
+	for id, w := range m.workouts {
+		if w.ExerciseID == exerciseID && w.UserID == userID {
+			ids = append(ids, id)
+		}
+	}
+	// Same as the SQL query: skip the most recent occurrence, which is the
+	// workout currently being logged.
+	if len(ids) < 2 {
+		return 0, nil
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+	return ids[1], nil
+}
+
+func (m *MemoryStore) UpdateWorkout(workoutID int, exerciseID int, workoutname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.workouts[workoutID]
+	if !ok {
+		return nil
+	}
+	if m.workoutExerciseTakenLocked(w.SessionID, exerciseID, workoutID) {
+		return fmt.Errorf("Workout already exists: the session of workout %d already has a workout of exercise %d", workoutID, exerciseID)
+	}
+	w.ExerciseID = exerciseID
+	w.WorkoutName = workoutname
+	m.workouts[workoutID] = w
+	return nil
+}
+
+func (m *MemoryStore) DeleteWorkout(workoutID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleteWorkoutLocked(workoutID)
+	return nil
+}
+
+func (m *MemoryStore) deleteWorkoutLocked(workoutID int) {
+	for id, s := range m.sets {
+		if s.WorkoutID == workoutID {
+			delete(m.sets, id)
+			delete(m.setImportKeys, id)
+		}
+	}
+	delete(m.workouts, workoutID)
+}
+
+func (m *MemoryStore) WorkoutBelongsToUser(workoutID int, userID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.workoutOwnedLocked(workoutID, userID), nil
+}
+
+func (m *MemoryStore) workoutOwnedLocked(workoutID int, userID int) bool {
+	w, ok := m.workouts[workoutID]
+	if !ok {
+		return false
+	}
+	s, ok := m.sessions[w.SessionID]
+	return ok && s.UserID == userID
+}
+
+func (m *MemoryStore) CreateSetForWorkout(set Set) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.nextID("Sets")
+	m.sets[id] = SetRow{Id: id, Set: set}
+	return int64(id), nil
+}
+
+func (m *MemoryStore) GetSetsByWorkoutId(workoutID int) ([]SetRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sets []SetRow
+	for _, s := range m.sets {
+		if s.WorkoutID == workoutID {
+			sets = append(sets, s)
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Id > sets[j].Id })
+	return sets, nil
+}
+
+func (m *MemoryStore) GetSetsBySessionId(sessionID int) ([]SetRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sets []SetRow
+	for _, s := range m.sets {
+		if m.workouts[s.WorkoutID].SessionID == sessionID {
+			sets = append(sets, s)
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		wi, wj := m.workouts[sets[i].WorkoutID], m.workouts[sets[j].WorkoutID]
+		if wi.Id != wj.Id {
+			return workoutBefore(wi, wj)
+		}
+		return sets[i].Id > sets[j].Id
+	})
+	return sets, nil
+}
+
+func (m *MemoryStore) GetSetById(setID int) (SetRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sets[setID]
+	if !ok {
+		return SetRow{}, fmt.Errorf("GetSetById: %w", ErrNotFound)
+	}
+	return s, nil
+}
+
+func (m *MemoryStore) UpdateSet(setID int, set Set) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sets[setID]; ok {
+		set.WorkoutID = s.WorkoutID
+		m.sets[setID] = SetRow{Id: setID, Set: set}
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteSet(setID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sets, setID)
+	delete(m.setImportKeys, setID)
+	return nil
+}
+
+func (m *MemoryStore) SetBelongsToUser(setID int, userID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sets[setID]
+	return ok && m.workoutOwnedLocked(s.WorkoutID, userID), nil
+}
+
+func (m *MemoryStore) GetSetHistoryForExercise(exerciseID int, userID int) ([]ExerciseSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setHistoryLocked(userID, func(w WorkoutRow) bool { return w.ExerciseID == exerciseID }), nil
+}
+
+func (m *MemoryStore) GetSetHistoryForUser(userID int) ([]ExerciseSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setHistoryLocked(userID, func(WorkoutRow) bool { return true }), nil
+}
+
+func (m *MemoryStore) ExportSetHistory(userID int, from string, to string, fn func(ExerciseSet) error) error {
+	m.mu.Lock()
+	history := m.setHistoryLocked(userID, func(w WorkoutRow) bool {
+		dateTime := m.sessions[w.SessionID].DateTime
+		return (from == "" || dateTime >= from) && (to == "" || dateTime < to)
+	})
+	// The export groups sets by workout within a session, in the session's
+	// order, like the SQL query.
+	sort.Slice(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		if a.DateTime != b.DateTime {
+			return a.DateTime < b.DateTime
+		}
+		if a.SessionID != b.SessionID {
+			return a.SessionID < b.SessionID
+		}
+		if a.WorkoutID != b.WorkoutID {
+			wa, wb := m.workouts[a.WorkoutID], m.workouts[b.WorkoutID]
+			if wa.Position != wb.Position {
+				return wa.Position < wb.Position
+			}
+			return a.WorkoutID > b.WorkoutID
+		}
+		return a.Id < b.Id
+	})
+	m.mu.Unlock()
+	for _, set := range history {
+		if err := fn(set); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setHistoryLocked joins sets to their workout and session, keeping the
+// user's sets whose workout passes match, ordered like the SQL history queries.
+func (m *MemoryStore) setHistoryLocked(userID int, match func(WorkoutRow) bool) []ExerciseSet {
+	var history []ExerciseSet
+	for _, set := range m.sets {
+		w, ok := m.workouts[set.WorkoutID]
+		if !ok || !match(w) {
+			continue
+		}
+		s, ok := m.sessions[w.SessionID]
+		if !ok || s.UserID != userID {
+			continue
+		}
+		history = append(history, ExerciseSet{
+			SessionID:    s.Id,
+			DateTime:     s.DateTime,
+			ExerciseID:   w.ExerciseID,
+			ExerciseName: m.exercises[w.ExerciseID].Name,
+			Group:        w.Group,
+			SetRow:       set,
+		})
+	}
+	sort.Slice(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		if a.DateTime != b.DateTime {
+			return a.DateTime < b.DateTime
+		}
+		if a.SessionID != b.SessionID {
+			return a.SessionID < b.SessionID
+		}
+		return a.Id < b.Id
+	})
+	return history
+}
+
+// copyExercise returns e with its slices copied so callers cannot mutate the
+// stored row.
+func copyExercise(e ExerciseRow) ExerciseRow {
+	e.Aliases = append([]string{}, e.Aliases...)
+	e.PrimaryMuscles = append([]string{}, e.PrimaryMuscles...)
+	e.SecondaryMuscles = append([]string{}, e.SecondaryMuscles...)
+	return e
+}
+
+func exerciseVisible(e ExerciseRow, userID int) bool {
+	return e.UserID == nil || *e.UserID == userID
+}
+
+func (m *MemoryStore) GetExercises(userID int) ([]ExerciseRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listExercisesLocked(userID), nil
+}
+
+func (m *MemoryStore) listExercisesLocked(userID int) []ExerciseRow {
+	var exercises []ExerciseRow
+	for _, e := range m.exercises {
+		if exerciseVisible(e, userID) {
+			exercises = append(exercises, copyExercise(e))
+		}
+	}
+	sort.Slice(exercises, func(i, j int) bool {
+		if exercises[i].Name != exercises[j].Name {
+			return exercises[i].Name < exercises[j].Name
+		}
+		return exercises[i].Id < exercises[j].Id
+	})
+	return exercises
+}
+
+func (m *MemoryStore) GetExerciseById(exerciseID int) (ExerciseRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.exercises[exerciseID]
+	if !ok {
+		return ExerciseRow{}, fmt.Errorf("GetExerciseById: %w", ErrNotFound)
+	}
+	return copyExercise(e), nil
+}
+
+func (m *MemoryStore) FindExercise(name string, userID int) (ExerciseRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.findExerciseLocked(name, userID)
+	if !ok {
+		return ExerciseRow{}, fmt.Errorf("FindExercise: %w", ErrNotFound)
+	}
+	return copyExercise(e), nil
+}
+
+// findExerciseLocked mirrors findExerciseID: exact names win over aliases,
+// then the lowest ID.
+func (m *MemoryStore) findExerciseLocked(name string, userID int) (ExerciseRow, bool) {
+	name = NormalizeExerciseName(name)
+	var best ExerciseRow
+	bestRank := 2
+	for _, e := range m.exercises {
+		if !exerciseVisible(e, userID) {
+			continue
+		}
+		rank := 2
+		if e.Name == name {
+			rank = 0
+		} else {
+			for _, alias := range e.Aliases {
+				if alias == name {
+					rank = 1
+					break
+				}
+			}
+		}
+		if rank < bestRank || (rank == bestRank && rank < 2 && e.Id < best.Id) {
+			best, bestRank = e, rank
+		}
+	}
+	return best, bestRank < 2
+}
+
+func (m *MemoryStore) CreateExercise(exercise Exercise) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	exercise = normalizeExercise(exercise)
+	for _, e := range m.exercises {
+		sameOwner := (e.UserID == nil && exercise.UserID == nil) ||
+			(e.UserID != nil && exercise.UserID != nil && *e.UserID == *exercise.UserID)
+		if sameOwner && e.Name == exercise.Name {
+			return 0, fmt.Errorf("Exercise already exists: exercise with name %s already exists", exercise.Name)
+		}
+	}
+	id := m.nextID("Exercise")
+	m.exercises[id] = ExerciseRow{Id: id, Exercise: exercise}
+	return int64(id), nil
+}
+
+// copyRoutineLocked returns r with its exercise list copied and the current
+// exercise names filled in, as the SQL join does.
+func (m *MemoryStore) copyRoutineLocked(r RoutineRow) RoutineRow {
+	exercises := []RoutineExercise{}
+	for _, e := range r.Exercises {
+		e.ExerciseName = m.exercises[e.ExerciseID].Name
+		exercises = append(exercises, e)
+	}
+	r.Exercises = exercises
+	return r
+}
+
+func (m *MemoryStore) routineNameTakenLocked(userID int, name string, ignoreID int) bool {
+	for id, r := range m.routines {
+		if id != ignoreID && r.UserID == userID && r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) CreateRoutine(routine Routine) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.routineNameTakenLocked(routine.UserID, routine.Name, 0) {
+		return 0, fmt.Errorf("Routine already exists: routine with name %s already exists for user %d", routine.Name, routine.UserID)
+	}
+	id := m.nextID("Routine")
+	routine.Exercises = append([]RoutineExercise{}, routine.Exercises...)
+	m.routines[id] = RoutineRow{Id: id, Routine: routine}
+	return int64(id), nil
+}
+
+func (m *MemoryStore) GetRoutinesByUserId(userID int) ([]RoutineRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listRoutinesLocked(userID), nil
+}
+
+func (m *MemoryStore) listRoutinesLocked(userID int) []RoutineRow {
+	var routines []RoutineRow
+	for _, r := range m.routines {
+		if r.UserID == userID {
+			routines = append(routines, m.copyRoutineLocked(r))
+		}
+	}
+	sort.Slice(routines, func(i, j int) bool { return routines[i].Name < routines[j].Name })
+	return routines
+}
+
+func (m *MemoryStore) GetRoutineById(routineID int) (RoutineRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.routines[routineID]
+	if !ok {
+		return RoutineRow{}, fmt.Errorf("GetRoutineById: %w", ErrNotFound)
+	}
+	return m.copyRoutineLocked(r), nil
+}
+
+func (m *MemoryStore) UpdateRoutine(routineID int, routine Routine) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.routines[routineID]
+	if !ok {
+		return nil
+	}
+	if m.routineNameTakenLocked(r.UserID, routine.Name, routineID) {
+		return fmt.Errorf("Routine already exists: routine with name %s already exists for user %d", routine.Name, routine.UserID)
+	}
+	r.Name = routine.Name
+	r.Exercises = append([]RoutineExercise{}, routine.Exercises...)
+	m.routines[routineID] = r
+	return nil
+}
+
+func (m *MemoryStore) DeleteRoutine(routineID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.routines, routineID)
+	return nil
+}
+
+func (m *MemoryStore) RoutineBelongsToUser(routineID int, userID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.routines[routineID]
+	return ok && r.UserID == userID, nil
+}
+
+func (m *MemoryStore) StartRoutine(routineID int, session Session) (int64, error) {
+	userID := session.UserID
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.copyRoutineLocked(m.routines[routineID])
+	seen := map[int]bool{}
+	for _, e := range r.Exercises {
+		if seen[e.ExerciseID] {
+			return 0, fmt.Errorf("Workout already exists: routine %d lists %s more than once", routineID, e.ExerciseName)
+		}
+		seen[e.ExerciseID] = true
+	}
+	sessionID := m.nextID("Session")
+	m.sessions[sessionID] = SessionRow{Id: sessionID, Session: newSession(session)}
+	for i, e := range r.Exercises {
+		id := m.nextID("Workouts")
+		m.workouts[id] = WorkoutRow{Id: id, Workout: Workout{SessionID: sessionID, WorkoutName: e.ExerciseName, UserID: userID, ExerciseID: e.ExerciseID, Position: i}}
+	}
+	return int64(sessionID), nil
+}
+
+func (m *MemoryStore) ImportSession(userID int, session ImportedSession) (ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result ImportResult
+	for id, key := range m.sessionImportKeys {
+		if key == session.ImportKey && m.sessions[id].UserID == userID {
+			result.SessionID = id
+		}
+	}
+	if result.SessionID == 0 {
+		result.SessionID = m.nextID("Session")
+		result.SessionCreated = true
+		m.sessions[result.SessionID] = SessionRow{Id: result.SessionID, Session: newSession(Session{
+			UserID:      userID,
+			DateTime:    session.DateTime,
+			EndDateTime: session.EndDateTime,
+			TimeZone:    session.TimeZone,
+			Title:       session.Title,
+		})}
+		m.sessionImportKeys[result.SessionID] = session.ImportKey
+	}
+
+	position, groups := 0, 0
+	for _, w := range m.workouts {
+		if w.SessionID == result.SessionID {
+			position = max(position, w.Position+1)
+			groups = max(groups, w.Group)
+		}
+	}
+	for _, workout := range session.Workouts {
+		workoutID := 0
+		for id, w := range m.workouts {
+			if w.SessionID == result.SessionID && w.ExerciseID == workout.ExerciseID {
+				workoutID = id
+			}
+		}
+		if workoutID == 0 {
+			workoutID = m.nextID("Workouts")
+			m.workouts[workoutID] = WorkoutRow{Id: workoutID, Workout: Workout{
+				SessionID:   result.SessionID,
+				WorkoutName: workout.WorkoutName,
+				UserID:      userID,
+				ExerciseID:  workout.ExerciseID,
+				Position:    position,
+				Group:       importedGroup(workout.Group, groups),
+			}}
+			position++
+		}
+		existing := map[string]bool{}
+		for id, key := range m.setImportKeys {
+			if m.sets[id].WorkoutID == workoutID {
+				existing[key] = true
+			}
+		}
+		for _, set := range workout.Sets {
+			if existing[set.ImportKey] {
+				result.SetsSkipped++
+				continue
+			}
+			id := m.nextID("Sets")
+			set.WorkoutID = workoutID
+			m.sets[id] = SetRow{Id: id, Set: set.Set}
+			m.setImportKeys[id] = set.ImportKey
+			existing[set.ImportKey] = true
+			result.SetsCreated++
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) ExportAccount(userID int) (Backup, error) {
+	version, err := SchemaVersion()
+	if err != nil {
+		return Backup{}, fmt.Errorf("ExportAccount: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[userID]
+	if !ok {
+		return Backup{}, fmt.Errorf("ExportAccount: %w", ErrNotFound)
+	}
+	backup := Backup{
+		Version:    version,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		User:       user.User,
+		Exercises:  []ExerciseRow{},
+		Sessions:   []BackupSession{},
+		Routines:   append([]RoutineRow{}, m.listRoutinesLocked(userID)...),
+	}
+	for _, e := range m.listExercisesLocked(userID) {
+		if e.UserID != nil {
+			backup.Exercises = append(backup.Exercises, e)
+		}
+	}
+
+	var sessionIDs, workoutIDs, setIDs []int
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			sessionIDs = append(sessionIDs, id)
+		}
+	}
+	for id, w := range m.workouts {
+		if m.sessions[w.SessionID].UserID == userID {
+			workoutIDs = append(workoutIDs, id)
+		}
+	}
+	for id, set := range m.sets {
+		if m.workoutOwnedLocked(set.WorkoutID, userID) {
+			setIDs = append(setIDs, id)
+		}
+	}
+	sort.Ints(sessionIDs)
+	sort.Ints(workoutIDs)
+	sort.Ints(setIDs)
+
+	sessionIndex := map[int]int{}
+	for _, id := range sessionIDs {
+		sessionIndex[id] = len(backup.Sessions)
+		s := m.sessions[id]
+		backup.Sessions = append(backup.Sessions, BackupSession{
+			Id:          id,
+			DateTime:    s.DateTime,
+			EndDateTime: s.EndDateTime,
+			TimeZone:    s.TimeZone,
+			Title:       s.Title,
+			Notes:       s.Notes,
+			Bodyweight:  s.Bodyweight,
+			ImportKey:   m.sessionImportKeys[id],
+			Workouts:    []BackupWorkout{},
+		})
+	}
+	type workoutPos struct{ session, workout int }
+	workoutIndex := map[int]workoutPos{}
+	for _, id := range workoutIDs {
+		w := m.workouts[id]
+		i, ok := sessionIndex[w.SessionID]
+		if !ok {
+			return Backup{}, fmt.Errorf("ExportAccount: workout %d: unknown session %d", id, w.SessionID)
+		}
+		workoutIndex[id] = workoutPos{i, len(backup.Sessions[i].Workouts)}
+		backup.Sessions[i].Workouts = append(backup.Sessions[i].Workouts, BackupWorkout{
+			Id:           id,
+			ExerciseID:   w.ExerciseID,
+			ExerciseName: m.exercises[w.ExerciseID].Name,
+			WorkoutName:  w.WorkoutName,
+			Position:     w.Position,
+			Group:        w.Group,
+			Sets:         []BackupSet{},
+		})
+	}
+	for _, id := range setIDs {
+		set := m.sets[id]
+		pos, ok := workoutIndex[set.WorkoutID]
+		if !ok {
+			return Backup{}, fmt.Errorf("ExportAccount: set %d: unknown workout %d", id, set.WorkoutID)
+		}
+		workout := &backup.Sessions[pos.session].Workouts[pos.workout]
+		workout.Sets = append(workout.Sets, BackupSet{Id: id, ImportKey: m.setImportKeys[id], Set: set.Set})
+	}
+	return backup, nil
+}
+
+// RestoreAccount checks every reference in the backup before touching the
+// account, so a failed restore leaves it unchanged like the rolled-back
+// transaction in DBConn.
+func (m *MemoryStore) RestoreAccount(userID int, backup Backup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return fmt.Errorf("RestoreAccount: %w", ErrNotFound)
+	}
+	restoredProfile, err := restoredUser(user.User, backup.User, backup.Version)
+	if err != nil {
+		return fmt.Errorf("RestoreAccount: user: %w", err)
+	}
+
+	custom := map[int]bool{}
+	for _, e := range backup.Exercises {
+		custom[e.Id] = true
+	}
+	builtins := map[string]int{}
+	for id, e := range m.exercises {
+		if e.UserID == nil {
+			builtins[e.Name] = id
+		}
+	}
+	// checkExercise returns a key that is the same for two entries exactly
+	// when they restore to the same exercise.
+	checkExercise := func(exerciseID int, name string) (string, error) {
+		if custom[exerciseID] {
+			return fmt.Sprintf("custom %d", exerciseID), nil
+		}
+		if _, ok := builtins[NormalizeExerciseName(name)]; !ok {
+			return "", fmt.Errorf("unknown exercise %q: %w", name, ErrInvalidBackup)
+		}
+		return "built-in " + NormalizeExerciseName(name), nil
+	}
+	for _, session := range backup.Sessions {
+		if _, err := restoredSession(session, backup.Version); err != nil {
+			return fmt.Errorf("RestoreAccount: session %d: %w", session.Id, err)
+		}
+		exercises := map[string]bool{}
+		for i, workout := range session.Workouts {
+			exercise, err := checkExercise(workout.ExerciseID, workout.ExerciseName)
+			if err != nil {
+				return fmt.Errorf("RestoreAccount: workout %d: %w", workout.Id, err)
+			}
+			if _, _, err := restoredLayout(workout, i, len(session.Workouts), backup.Version); err != nil {
+				return fmt.Errorf("RestoreAccount: workout %d: %w", workout.Id, err)
+			}
+			if exercises[exercise] {
+				return fmt.Errorf("RestoreAccount: workout %d: duplicate workout %s: %w", workout.Id, workout.WorkoutName, ErrInvalidBackup)
+			}
+			exercises[exercise] = true
+			for _, set := range workout.Sets {
+				if _, err := restoredSet(set, backup.Version); err != nil {
+					return fmt.Errorf("RestoreAccount: set %d: %w", set.Id, err)
+				}
+			}
+		}
+	}
+	routineNames := map[string]bool{}
+	for _, routine := range backup.Routines {
+		if routineNames[routine.Name] {
+			return fmt.Errorf("RestoreAccount: duplicate routine %s: %w", routine.Name, ErrInvalidBackup)
+		}
+		routineNames[routine.Name] = true
+		for _, exercise := range routine.Exercises {
+			if _, err := checkExercise(exercise.ExerciseID, exercise.ExerciseName); err != nil {
+				return fmt.Errorf("RestoreAccount: routine %d: %w", routine.Id, err)
+			}
+		}
+	}
+
+	user.User = restoredProfile
+	m.users[userID] = user
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			for workoutID, w := range m.workouts {
+				if w.SessionID == id {
+					m.deleteWorkoutLocked(workoutID)
+				}
+			}
+			delete(m.sessions, id)
+			delete(m.sessionImportKeys, id)
+		}
+	}
+	for id, r := range m.routines {
+		if r.UserID == userID {
+			delete(m.routines, id)
+		}
+	}
+	for id, e := range m.exercises {
+		if e.UserID != nil && *e.UserID == userID {
+			delete(m.exercises, id)
+		}
+	}
+
+	exerciseIDs := map[int]int{}
+	for _, e := range backup.Exercises {
+		id := m.nextID("Exercise")
+		exercise := normalizeExercise(e.Exercise)
+		exercise.UserID = &userID
+		m.exercises[id] = ExerciseRow{Id: id, Exercise: exercise}
+		exerciseIDs[e.Id] = id
+	}
+	mapExercise := func(exerciseID int, name string) int {
+		if id, ok := exerciseIDs[exerciseID]; ok {
+			return id
+		}
+		return builtins[NormalizeExerciseName(name)]
+	}
+	for _, session := range backup.Sessions {
+		restored, _ := restoredSession(session, backup.Version)
+		restored.UserID = userID
+		sessionID := m.nextID("Session")
+		m.sessions[sessionID] = SessionRow{Id: sessionID, Session: newSession(restored)}
+		if session.ImportKey != "" {
+			m.sessionImportKeys[sessionID] = session.ImportKey
+		}
+		for i, workout := range session.Workouts {
+			position, group, _ := restoredLayout(workout, i, len(session.Workouts), backup.Version)
+			workoutID := m.nextID("Workouts")
+			m.workouts[workoutID] = WorkoutRow{Id: workoutID, Workout: Workout{
+				SessionID:   sessionID,
+				WorkoutName: workout.WorkoutName,
+				UserID:      userID,
+				ExerciseID:  mapExercise(workout.ExerciseID, workout.ExerciseName),
+				Position:    position,
+				Group:       group,
+			}}
+			for _, backupSet := range workout.Sets {
+				set, _ := restoredSet(backupSet, backup.Version)
+				set.WorkoutID = workoutID
+				setID := m.nextID("Sets")
+				m.sets[setID] = SetRow{Id: setID, Set: set}
+				if backupSet.ImportKey != "" {
+					m.setImportKeys[setID] = backupSet.ImportKey
+				}
+			}
+		}
+	}
+	for _, routine := range backup.Routines {
+		routineID := m.nextID("Routine")
+		exercises := []RoutineExercise{}
+		for _, exercise := range routine.Exercises {
+			exercise.ExerciseID = mapExercise(exercise.ExerciseID, exercise.ExerciseName)
+			exercises = append(exercises, exercise)
+		}
+		m.routines[routineID] = RoutineRow{Id: routineID, Routine: Routine{UserID: userID, Name: routine.Name, Exercises: exercises}}
+	}
+	return nil
+}
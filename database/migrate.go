@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// goMigrations run inside the same transaction right after the SQL migration
+// with the matching version, for data changes that are awkward in plain SQL.
+var goMigrations = map[int]func(d *DBConn, tx *sql.Tx) error{
+	2: seedExerciseCatalog,
+	9: sessionTimesToUTC,
+}
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations reads the embedded migration files from dir. Files are named
+// NNNN_description.sql and are returned sorted by their numeric prefix.
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("loadMigrations: %w", err)
+	}
+	var migrations []migration
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("loadMigrations: migration %s has no version prefix", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("loadMigrations: migration %s has invalid version: %w", name, err)
+		}
+		contents, err := migrationFiles.ReadFile(dir + "/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("loadMigrations: %w", err)
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(contents)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("loadMigrations: duplicate migration version %d", migrations[i].version)
+		}
+	}
+	return migrations, nil
+}
+
+// migrate brings the schema up to the latest embedded version. It refuses to
+// run against a database whose version is newer than this binary knows about.
+func (d *DBConn) migrate() error {
+	if _, err := d.db.Exec("CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)"); err != nil {
+		return fmt.Errorf("migrate: creating schema_version: %w", err)
+	}
+	migrations, err := loadMigrations(d.dialect.migrationsDir())
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	current, err := d.schemaVersion()
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].version
+	}
+	if current > latest {
+		return fmt.Errorf("migrate: database schema version %d is newer than the latest version %d known to this binary", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := d.applyMigration(m); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the latest schema version embedded in this binary.
+// Account backups are stamped with it.
+func SchemaVersion() (int, error) {
+	migrations, err := loadMigrations(dialectSQLite.migrationsDir())
+	if err != nil {
+		return 0, fmt.Errorf("SchemaVersion: %w", err)
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].version, nil
+}
+
+func (d *DBConn) schemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := d.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("schemaVersion: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+func (d *DBConn) applyMigration(m migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("applyMigration %s: %w", m.name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return fmt.Errorf("applyMigration %s: %w", m.name, err)
+	}
+	if step, ok := goMigrations[m.version]; ok {
+		if err := step(d, tx); err != nil {
+			return fmt.Errorf("applyMigration %s: %w", m.name, err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM schema_version"); err != nil {
+		return fmt.Errorf("applyMigration %s: %w", m.name, err)
+	}
+	if _, err := tx.Exec(d.rebind("INSERT INTO schema_version (version) VALUES (?)"), m.version); err != nil {
+		return fmt.Errorf("applyMigration %s: %w", m.name, err)
+	}
+	return tx.Commit()
+}
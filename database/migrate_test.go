@@ -0,0 +1,205 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openUnmigrated opens the SQLite database at path without migrating it.
+func openUnmigrated(t *testing.T, path string) *DBConn {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &DBConn{db: db, dialect: dialectSQLite}
+}
+
+func TestMigrateEmptyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrate.db")
+	d, err := CreateDBConnection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := must(SchemaVersion())
+	if version := must(d.schemaVersion()); version != latest {
+		t.Errorf("schema version %d, want %d", version, latest)
+	}
+	// The catalog is seeded by the Go step of migration 2.
+	exercises := len(must(d.GetExercises(0)))
+	if exercises == 0 {
+		t.Error("no exercises after migrating")
+	}
+	d.CloseConn()
+
+	// Opening it again has nothing left to apply.
+	d, err = CreateDBConnection(path)
+	if err != nil {
+		t.Fatalf("migrating again: %v", err)
+	}
+	defer d.CloseConn()
+	if version := must(d.schemaVersion()); version != latest {
+		t.Errorf("schema version %d after migrating again, want %d", version, latest)
+	}
+	if again := len(must(d.GetExercises(0))); again != exercises {
+		t.Errorf("%d exercises after migrating again, want %d", again, exercises)
+	}
+}
+
+// A failing Go step rolls back the SQL of its migration too.
+func TestMigrateGoStepSharesTransaction(t *testing.T) {
+	d := openUnmigrated(t, filepath.Join(t.TempDir(), "migrate.db"))
+	step := goMigrations[2]
+	goMigrations[2] = func(d *DBConn, tx *sql.Tx) error { return errors.New("step failed") }
+	defer func() { goMigrations[2] = step }()
+
+	if err := d.migrate(); err == nil || !strings.Contains(err.Error(), "step failed") {
+		t.Fatalf("migrate = %v, want the step's error", err)
+	}
+	if version := must(d.schemaVersion()); version != 1 {
+		t.Errorf("schema version %d, want 1", version)
+	}
+	var tables int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'Exercise'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("migration 2 created its tables although its Go step failed")
+	}
+
+	goMigrations[2] = step
+	if err := d.migrate(); err != nil {
+		t.Fatalf("migrating after the failure: %v", err)
+	}
+	if version, latest := must(d.schemaVersion()), must(SchemaVersion()); version != latest {
+		t.Errorf("schema version %d, want %d", version, latest)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrate.db")
+	d, err := CreateDBConnection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := must(SchemaVersion())
+	if _, err := d.db.Exec("UPDATE schema_version SET version = ?", latest+1); err != nil {
+		t.Fatal(err)
+	}
+	d.CloseConn()
+
+	if _, err := CreateDBConnection(path); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("opening a newer schema = %v, want a refusal", err)
+	}
+}
+
+// Before migration 16 a session could hold one exercise twice under different
+// names; migrating merges them into the oldest workout.
+func TestMigrateMergesWorkoutsOfOneExercise(t *testing.T) {
+	d := openUnmigrated(t, filepath.Join(t.TempDir(), "migrate.db"))
+	if _, err := d.db.Exec("CREATE TABLE schema_version (version INTEGER NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range must(loadMigrations(d.dialect.migrationsDir())) {
+		if m.version < 16 {
+			if err := d.applyMigration(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var bench, squat int
+	if err := d.db.QueryRow("SELECT exerciseID FROM Exercise WHERE name = 'bench press'").Scan(&bench); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.db.QueryRow("SELECT exerciseID FROM Exercise WHERE name = 'squat'").Scan(&squat); err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"INSERT INTO User (userId, email, name) VALUES (1, 'a@example.com', 'a')",
+		"INSERT INTO Session (sessionID, userID, dateTime) VALUES (1, 1, '2024-01-02 10:00:00')",
+		fmt.Sprintf("INSERT INTO Workouts (workoutID, sessionID, workoutname, userID, exerciseID) VALUES (1, 1, 'bench press', 1, %d), (2, 1, 'bench', 1, %d), (3, 1, 'squat', 1, %d)", bench, bench, squat),
+		"INSERT INTO Sets (setID, numberofReps, weight, workoutID, importKey) VALUES (1, 5, 100, 1, 'row 1'), (2, 5, 100, 2, 'row 1'), (3, 3, 110, 2, 'row 2'), (4, 8, 80, 2, NULL)",
+		// The newest workout was deleted; its ID must not come back.
+		"INSERT INTO Workouts (workoutID, sessionID, workoutname, userID) VALUES (9, 1, 'deleted', 1)",
+		"DELETE FROM Workouts WHERE workoutID = 9",
+	} {
+		if _, err := d.db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	if err := d.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	workouts := must(d.GetWorkoutsBySessionId(1))
+	var ids []int
+	for _, w := range workouts {
+		ids = append(ids, w.Id)
+	}
+	if len(ids) != 2 || !slices.Contains(ids, 1) || !slices.Contains(ids, 3) {
+		t.Errorf("workouts %v after migrating, want 1 and 3", ids)
+	}
+	var sets []int
+	for _, set := range must(d.GetSetsByWorkoutId(1)) {
+		sets = append(sets, set.Id)
+	}
+	slices.Sort(sets)
+	if !slices.Equal(sets, []int{1, 3, 4}) {
+		t.Errorf("merged workout has sets %v, want 1, 3 and 4", sets)
+	}
+	if id := must(d.CreateWorkoutForSession(1, must(d.FindExercise("deadlift", 1)).Id, "deadlift", 1)); id <= 9 {
+		t.Errorf("new workout got ID %d, want one after the deleted 9", id)
+	}
+}
+
+// Migration 9 reads the start times the server wrote before it stored UTC in
+// time.Local, the zone the server runs in.
+func TestMigrateSessionTimesToUTC(t *testing.T) {
+	zone, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := time.Local
+	time.Local = zone
+	t.Cleanup(func() { time.Local = local })
+
+	d := openUnmigrated(t, filepath.Join(t.TempDir(), "migrate.db"))
+	if _, err := d.db.Exec("CREATE TABLE schema_version (version INTEGER NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range must(loadMigrations(d.dialect.migrationsDir())) {
+		if m.version < 9 {
+			if err := d.applyMigration(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, statement := range []string{
+		"INSERT INTO User (userId, email, name) VALUES (1, 'a@example.com', 'a')",
+		"INSERT INTO Session (sessionID, userID, dateTime) VALUES (1, 1, '2024-01-15 09:30:00')",
+		// Daylight saving time.
+		"INSERT INTO Session (sessionID, userID, dateTime) VALUES (2, 1, '2024-07-15 09:30:00')",
+		// Imported sessions keep the other app's time.
+		"INSERT INTO Session (sessionID, userID, dateTime, importKey) VALUES (3, 1, '2024-01-15 09:30:00', 'strong-1')",
+	} {
+		if _, err := d.db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	if err := d.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[int]string{1: "2024-01-15 14:30:00", 2: "2024-07-15 13:30:00", 3: "2024-01-15 09:30:00"} {
+		if session := must(d.GetSessionById(id)); session.DateTime != want {
+			t.Errorf("session %d starts at %s after migrating, want %s", id, session.DateTime, want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+-- Baseline schema, kept equivalent to migrations/sqlite/0001_initial.sql.
+-- "User" is quoted because USER is reserved in PostgreSQL; every other
+-- identifier is left unquoted and folds to lower case.
+CREATE TABLE IF NOT EXISTS "User" (
+    userId SERIAL PRIMARY KEY,
+    email  TEXT NOT NULL UNIQUE,
+    name   TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Session (
+    sessionID SERIAL PRIMARY KEY,
+    userID    INTEGER NOT NULL REFERENCES "User" (userId),
+    dateTime  TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Workouts (
+    workoutID   SERIAL PRIMARY KEY,
+    sessionID   INTEGER NOT NULL REFERENCES Session (sessionID),
+    workoutname TEXT NOT NULL,
+    userID      INTEGER NOT NULL REFERENCES "User" (userId),
+    UNIQUE (sessionID, workoutname, userID)
+);
+
+CREATE TABLE IF NOT EXISTS Sets (
+    setID        SERIAL PRIMARY KEY,
+    numberofReps INTEGER NOT NULL,
+    weight       DOUBLE PRECISION NOT NULL,
+    workoutID    INTEGER NOT NULL REFERENCES Workouts (workoutID)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_user ON Session (userID);
+CREATE INDEX IF NOT EXISTS idx_workouts_session ON Workouts (sessionID);
+CREATE INDEX IF NOT EXISTS idx_workouts_user_name ON Workouts (userID, workoutname);
+CREATE INDEX IF NOT EXISTS idx_sets_workout ON Sets (workoutID);
@@ -0,0 +1,7 @@
+-- Rows created by a CSV import remember where they came from so importing the
+-- same file again skips them. NULL for everything logged through the API.
+ALTER TABLE Session ADD COLUMN importKey TEXT;
+CREATE UNIQUE INDEX idx_session_import ON Session (userID, importKey);
+
+ALTER TABLE Sets ADD COLUMN importKey TEXT;
+CREATE UNIQUE INDEX idx_sets_import ON Sets (workoutID, importKey);
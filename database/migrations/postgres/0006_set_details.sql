@@ -0,0 +1,7 @@
+-- Optional effort and tempo details for a set. Sets logged before these
+-- existed are completed working sets.
+ALTER TABLE Sets ADD COLUMN rpe DOUBLE PRECISION;
+ALTER TABLE Sets ADD COLUMN rir INTEGER;
+ALTER TABLE Sets ADD COLUMN setType TEXT NOT NULL DEFAULT 'working';
+ALTER TABLE Sets ADD COLUMN tempo TEXT NOT NULL DEFAULT '';
+ALTER TABLE Sets ADD COLUMN completed BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,5 @@
+-- Duration (seconds), distance (metres) and calories for cardio, timed and
+-- carry exercises. Zero means not recorded.
+ALTER TABLE Sets ADD COLUMN duration INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Sets ADD COLUMN distance DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE Sets ADD COLUMN calories INTEGER NOT NULL DEFAULT 0;
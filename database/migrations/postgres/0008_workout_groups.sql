@@ -0,0 +1,9 @@
+-- Explicit workout order within a session, and superset/circuit grouping.
+-- Workouts sharing a non-zero groupNumber in a session are performed together;
+-- zero means not grouped. Existing sessions keep their newest-first order.
+ALTER TABLE Workouts ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Workouts ADD COLUMN groupNumber INTEGER NOT NULL DEFAULT 0;
+UPDATE Workouts SET position = (
+    SELECT COUNT(*) FROM Workouts w
+    WHERE w.sessionID = Workouts.sessionID AND w.workoutID > Workouts.workoutID
+);
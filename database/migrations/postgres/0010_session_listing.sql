@@ -0,0 +1,4 @@
+-- Session listings run newest first and may be bounded by date, so index
+-- dateTime per user; it also serves lookups by userID alone.
+CREATE INDEX idx_session_user_datetime ON Session (userID, dateTime, sessionID);
+DROP INDEX idx_session_user;
@@ -0,0 +1,12 @@
+-- Personal API tokens for scripts, stored as SHA-256 hashes. scope is 'read'
+-- or 'write'; deleting a row revokes the token.
+CREATE TABLE ApiToken (
+    tokenID    SERIAL PRIMARY KEY,
+    userID     INTEGER NOT NULL REFERENCES "User" (userId),
+    name       TEXT NOT NULL,
+    scope      TEXT NOT NULL,
+    tokenHash  TEXT NOT NULL UNIQUE,
+    createdAt  TEXT NOT NULL,
+    lastUsedAt TEXT,
+    UNIQUE (userID, name)
+);
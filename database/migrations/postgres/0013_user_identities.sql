@@ -0,0 +1,10 @@
+-- Sign-in identities, linking a user to the subject an identity provider knows
+-- them by. Existing users are linked on their next sign-in, by email.
+CREATE TABLE UserIdentity (
+    identityID SERIAL PRIMARY KEY,
+    userID     INTEGER NOT NULL REFERENCES "User" (userId),
+    provider   TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    UNIQUE (provider, subject)
+);
+CREATE INDEX idx_user_identity_user ON UserIdentity (userID);
@@ -0,0 +1,9 @@
+-- Passwords for local sign-in, as argon2id hashes in PHC string format.
+-- failedAttempts counts wrong passwords since the last success or lockout;
+-- sign-in is refused until lockedUntil.
+CREATE TABLE PasswordCredential (
+    userID         INTEGER PRIMARY KEY REFERENCES "User" (userId),
+    passwordHash   TEXT NOT NULL,
+    failedAttempts INTEGER NOT NULL DEFAULT 0,
+    lockedUntil    TEXT
+);
@@ -0,0 +1,7 @@
+-- Profile details from the user's identity provider, refreshed on each
+-- sign-in, and the user's current bodyweight in kilograms. nameEdited is set
+-- once the user renames themselves, so sign-ins stop replacing the name.
+ALTER TABLE "User" ADD COLUMN picture TEXT;
+ALTER TABLE "User" ADD COLUMN locale TEXT;
+ALTER TABLE "User" ADD COLUMN bodyweight DOUBLE PRECISION;
+ALTER TABLE "User" ADD COLUMN nameEdited BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,28 @@
+-- A session holds one workout per exercise. Workouts used to be unique by
+-- name instead, so a session could hold one exercise twice under different
+-- names, such as an alias and the catalog name. Those are merged into the
+-- oldest workout first; a set whose import key is already there is the same
+-- imported row and is dropped.
+DELETE FROM Sets
+WHERE importKey IS NOT NULL AND EXISTS (
+    SELECT 1 FROM Workouts w
+    JOIN Workouts keep ON keep.sessionID = w.sessionID AND keep.exerciseID = w.exerciseID AND keep.workoutID < w.workoutID
+    JOIN Sets kept ON kept.workoutID = keep.workoutID AND kept.importKey = Sets.importKey
+    WHERE w.workoutID = Sets.workoutID
+);
+UPDATE Sets SET workoutID = (
+    SELECT MIN(keep.workoutID) FROM Workouts w
+    JOIN Workouts keep ON keep.sessionID = w.sessionID AND keep.exerciseID = w.exerciseID
+    WHERE w.workoutID = Sets.workoutID
+)
+WHERE workoutID IN (
+    SELECT w.workoutID FROM Workouts w
+    JOIN Workouts keep ON keep.sessionID = w.sessionID AND keep.exerciseID = w.exerciseID AND keep.workoutID < w.workoutID
+);
+DELETE FROM Workouts WHERE EXISTS (
+    SELECT 1 FROM Workouts keep
+    WHERE keep.sessionID = Workouts.sessionID AND keep.exerciseID = Workouts.exerciseID AND keep.workoutID < Workouts.workoutID
+);
+
+ALTER TABLE Workouts DROP CONSTRAINT IF EXISTS workouts_sessionid_workoutname_userid_key;
+CREATE UNIQUE INDEX idx_workouts_session_exercise ON Workouts (sessionID, exerciseID);
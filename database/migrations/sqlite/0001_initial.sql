@@ -0,0 +1,33 @@
+-- Baseline schema. Uses IF NOT EXISTS so databases created before the
+-- migration runner existed are adopted at version 1 without changes.
+CREATE TABLE IF NOT EXISTS User (
+    userId INTEGER PRIMARY KEY AUTOINCREMENT,
+    email  TEXT NOT NULL UNIQUE,
+    name   TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Session (
+    sessionID INTEGER PRIMARY KEY AUTOINCREMENT,
+    userID    INTEGER NOT NULL REFERENCES User (userId),
+    dateTime  TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Workouts (
+    workoutID   INTEGER PRIMARY KEY AUTOINCREMENT,
+    sessionID   INTEGER NOT NULL REFERENCES Session (sessionID),
+    workoutname TEXT NOT NULL,
+    userID      INTEGER NOT NULL REFERENCES User (userId),
+    UNIQUE (sessionID, workoutname, userID)
+);
+
+CREATE TABLE IF NOT EXISTS Sets (
+    setID        INTEGER PRIMARY KEY AUTOINCREMENT,
+    numberofReps INTEGER NOT NULL,
+    weight       REAL NOT NULL,
+    workoutID    INTEGER NOT NULL REFERENCES Workouts (workoutID)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_user ON Session (userID);
+CREATE INDEX IF NOT EXISTS idx_workouts_session ON Workouts (sessionID);
+CREATE INDEX IF NOT EXISTS idx_workouts_user_name ON Workouts (userID, workoutname);
+CREATE INDEX IF NOT EXISTS idx_sets_workout ON Sets (workoutID);
@@ -0,0 +1,26 @@
+-- Canonical exercise catalog. The built-in entries are seeded and existing
+-- workouts are mapped onto exercises by seedExerciseCatalog in migrate.go.
+CREATE TABLE Exercise (
+    exerciseID       INTEGER PRIMARY KEY AUTOINCREMENT,
+    userID           INTEGER REFERENCES User (userId),
+    name             TEXT NOT NULL,
+    primaryMuscles   TEXT NOT NULL DEFAULT '',
+    secondaryMuscles TEXT NOT NULL DEFAULT '',
+    equipment        TEXT NOT NULL DEFAULT '',
+    type             TEXT NOT NULL DEFAULT 'strength'
+);
+
+-- Built-in exercises have a NULL userID; COALESCE makes their names unique too.
+CREATE UNIQUE INDEX idx_exercise_owner_name ON Exercise (COALESCE(userID, 0), name);
+
+CREATE TABLE ExerciseAlias (
+    exerciseID INTEGER NOT NULL REFERENCES Exercise (exerciseID),
+    alias      TEXT NOT NULL,
+    PRIMARY KEY (exerciseID, alias)
+);
+
+CREATE INDEX idx_exercise_alias ON ExerciseAlias (alias);
+
+ALTER TABLE Workouts ADD COLUMN exerciseID INTEGER REFERENCES Exercise (exerciseID);
+
+CREATE INDEX idx_workouts_user_exercise ON Workouts (userID, exerciseID);
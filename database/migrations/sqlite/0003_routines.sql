@@ -0,0 +1,19 @@
+-- Named routines (templates) made of an ordered list of exercises with
+-- optional targets. Starting a routine copies its exercises into a session.
+CREATE TABLE Routine (
+    routineID INTEGER PRIMARY KEY AUTOINCREMENT,
+    userID    INTEGER NOT NULL REFERENCES User (userId),
+    name      TEXT NOT NULL,
+    UNIQUE (userID, name)
+);
+
+CREATE TABLE RoutineExercise (
+    routineExerciseID INTEGER PRIMARY KEY AUTOINCREMENT,
+    routineID         INTEGER NOT NULL REFERENCES Routine (routineID),
+    exerciseID        INTEGER NOT NULL REFERENCES Exercise (exerciseID),
+    position          INTEGER NOT NULL,
+    targetSets        INTEGER NOT NULL DEFAULT 0,
+    targetReps        INTEGER NOT NULL DEFAULT 0,
+    targetWeight      REAL NOT NULL DEFAULT 0,
+    UNIQUE (routineID, position)
+);
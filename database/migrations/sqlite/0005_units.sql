@@ -0,0 +1,5 @@
+-- Weights are stored in kilograms. Each user picks the unit weights are shown
+-- in, and each set remembers the unit it was entered in. Weights logged
+-- before units existed are assumed to be kilograms.
+ALTER TABLE User ADD COLUMN unit TEXT NOT NULL DEFAULT 'kg';
+ALTER TABLE Sets ADD COLUMN unit TEXT NOT NULL DEFAULT 'kg';
@@ -0,0 +1,12 @@
+-- Session lifecycle. dateTime is when the session started and endDateTime when
+-- it was finished, both in UTC; endDateTime is NULL while it is in progress.
+-- Start times the server wrote in its local time are moved to UTC by a Go
+-- step. timeZone is the IANA zone the session was logged in, title and notes
+-- are free text, and bodyweight is in kilograms, NULL when not recorded.
+ALTER TABLE Session ADD COLUMN endDateTime TEXT;
+ALTER TABLE Session ADD COLUMN timeZone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE Session ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE Session ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+ALTER TABLE Session ADD COLUMN bodyweight REAL;
+-- The zone new sessions are logged in, and dates are read in, for the user.
+ALTER TABLE "User" ADD COLUMN timeZone TEXT NOT NULL DEFAULT 'UTC';
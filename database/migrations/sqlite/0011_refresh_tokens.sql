@@ -0,0 +1,14 @@
+-- Refresh tokens, stored as SHA-256 hashes. The tokens of one login share a
+-- familyID: each refresh marks the old token used and adds a new one, and
+-- signing out revokes the whole family.
+CREATE TABLE RefreshToken (
+    tokenID   INTEGER PRIMARY KEY AUTOINCREMENT,
+    userID    INTEGER NOT NULL REFERENCES User (userId),
+    familyID  TEXT NOT NULL,
+    tokenHash TEXT NOT NULL UNIQUE,
+    expiresAt TEXT NOT NULL,
+    usedAt    TEXT,
+    revokedAt TEXT
+);
+CREATE INDEX idx_refresh_token_family ON RefreshToken (familyID);
+CREATE INDEX idx_refresh_token_user ON RefreshToken (userID, expiresAt);
@@ -0,0 +1,48 @@
+-- A session holds one workout per exercise. Workouts used to be unique by
+-- name instead, so a session could hold one exercise twice under different
+-- names, such as an alias and the catalog name. Those are merged into the
+-- oldest workout first; a set whose import key is already there is the same
+-- imported row and is dropped.
+DELETE FROM Sets
+WHERE importKey IS NOT NULL AND EXISTS (
+    SELECT 1 FROM Workouts w
+    JOIN Workouts keep ON keep.sessionID = w.sessionID AND keep.exerciseID = w.exerciseID AND keep.workoutID < w.workoutID
+    JOIN Sets kept ON kept.workoutID = keep.workoutID AND kept.importKey = Sets.importKey
+    WHERE w.workoutID = Sets.workoutID
+);
+UPDATE Sets SET workoutID = (
+    SELECT MIN(keep.workoutID) FROM Workouts w
+    JOIN Workouts keep ON keep.sessionID = w.sessionID AND keep.exerciseID = w.exerciseID
+    WHERE w.workoutID = Sets.workoutID
+)
+WHERE workoutID IN (
+    SELECT w.workoutID FROM Workouts w
+    JOIN Workouts keep ON keep.sessionID = w.sessionID AND keep.exerciseID = w.exerciseID AND keep.workoutID < w.workoutID
+);
+DELETE FROM Workouts WHERE EXISTS (
+    SELECT 1 FROM Workouts keep
+    WHERE keep.sessionID = Workouts.sessionID AND keep.exerciseID = Workouts.exerciseID AND keep.workoutID < Workouts.workoutID
+);
+
+-- SQLite cannot drop a table constraint, so the table is rebuilt without
+-- UNIQUE (sessionID, workoutname, userID). The AUTOINCREMENT counter carries
+-- over so deleted workout IDs are not reused.
+CREATE TABLE Workouts_new (
+    workoutID   INTEGER PRIMARY KEY AUTOINCREMENT,
+    sessionID   INTEGER NOT NULL REFERENCES Session (sessionID),
+    workoutname TEXT NOT NULL,
+    userID      INTEGER NOT NULL REFERENCES User (userId),
+    exerciseID  INTEGER REFERENCES Exercise (exerciseID),
+    position    INTEGER NOT NULL DEFAULT 0,
+    groupNumber INTEGER NOT NULL DEFAULT 0
+);
+INSERT INTO Workouts_new (workoutID, sessionID, workoutname, userID, exerciseID, position, groupNumber)
+SELECT workoutID, sessionID, workoutname, userID, exerciseID, position, groupNumber FROM Workouts;
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'Workouts') WHERE name = 'Workouts_new';
+DROP TABLE Workouts;
+ALTER TABLE Workouts_new RENAME TO Workouts;
+
+CREATE INDEX idx_workouts_session ON Workouts (sessionID);
+CREATE INDEX idx_workouts_user_name ON Workouts (userID, workoutname);
+CREATE INDEX idx_workouts_user_exercise ON Workouts (userID, exerciseID);
+CREATE UNIQUE INDEX idx_workouts_session_exercise ON Workouts (sessionID, exerciseID);
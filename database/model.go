@@ -1,8 +1,26 @@
 package database
 
+// DateTimeLayout is the format Session.DateTime and Session.EndDateTime are
+// stored in, always in UTC.
+const DateTimeLayout = "2006-01-02 15:04:05"
+
 type User struct {
 	Email string
 	Name  string
+	// Unit is the weight unit the user reads weights in.
+	Unit string
+	// TimeZone is the IANA zone the user's new sessions are logged in.
+	TimeZone string
+	// Picture and Locale come from the user's identity provider, empty when
+	// it does not send them.
+	Picture string
+	Locale  string
+	// Bodyweight is the user's current bodyweight in kilograms, nil when not
+	// recorded.
+	Bodyweight *float64
+	// NameEdited is set once the user changes their name, after which
+	// sign-ins no longer replace it with the identity provider's.
+	NameEdited bool
 }
 
 type UserRow struct {
@@ -10,9 +28,18 @@ type UserRow struct {
 	User
 }
 
+// Session is one visit to the gym. DateTime is when it started and
+// EndDateTime when it was finished, empty while it is in progress. TimeZone is
+// the IANA zone it was logged in. Bodyweight is in kilograms and nil when not
+// recorded.
 type Session struct {
-	UserID   int
-	DateTime string
+	UserID      int
+	DateTime    string
+	EndDateTime string
+	TimeZone    string
+	Title       string
+	Notes       string
+	Bodyweight  *float64
 }
 
 type SessionRow struct {
@@ -20,10 +47,35 @@ type SessionRow struct {
 	Session
 }
 
+// SessionFilter narrows a listing of a user's sessions, which runs newest
+// first. Zero fields do not filter. From (inclusive) and To (exclusive) bound
+// DateTime, and ExerciseID keeps sessions with a workout of that exercise.
+// After continues a listing past the session it names, the last one of the
+// previous page, and Limit caps how many sessions are returned.
+type SessionFilter struct {
+	From       string
+	To         string
+	ExerciseID int
+	After      *SessionCursor
+	Limit      int
+}
+
+// SessionCursor is a position in a session listing.
+type SessionCursor struct {
+	DateTime string
+	Id       int
+}
+
+// Workout is one exercise performed in a session. Workouts are listed by
+// Position; those sharing a non-zero Group form a superset or circuit, and
+// Group 0 means the workout stands alone.
 type Workout struct {
 	SessionID   int
 	WorkoutName string
 	UserID      int
+	ExerciseID  int
+	Position    int
+	Group       int
 }
 
 type WorkoutRow struct {
@@ -31,13 +83,206 @@ type WorkoutRow struct {
 	Workout
 }
 
+// Set is one set of a workout. Weight is in kilograms; Unit is the unit the
+// set was entered in. RPE and RIR are nil when not recorded, and Tempo is
+// written as four phases such as "3-1-X-0". Duration is in seconds and
+// Distance in metres; like Calories they are zero when not recorded.
 type Set struct {
 	WorkoutID    int
-	Weight       float32
+	Weight       float64
 	NumberOfReps int
+	Unit         string
+	RPE          *float64
+	RIR          *int
+	Type         string
+	Tempo        string
+	Completed    bool
+	Duration     int
+	Distance     float64
+	Calories     int
 }
 
 type SetRow struct {
 	Id int
 	Set
 }
+
+// ExerciseSet is a set joined with the session it was performed in, used for
+// per-exercise history and statistics. Group is its workout's superset or
+// circuit within the session, 0 when it had none.
+type ExerciseSet struct {
+	SessionID    int
+	DateTime     string
+	ExerciseID   int
+	ExerciseName string
+	Group        int
+	SetRow
+}
+
+// Exercise is an entry in the exercise catalog. Built-in exercises have a nil
+// UserID; custom exercises belong to the user who created them.
+type Exercise struct {
+	UserID           *int
+	Name             string
+	Aliases          []string
+	PrimaryMuscles   []string
+	SecondaryMuscles []string
+	Equipment        string
+	Type             string
+}
+
+type ExerciseRow struct {
+	Id int
+	Exercise
+}
+
+// Routine is a named template of exercises a session can be started from.
+type Routine struct {
+	UserID    int
+	Name      string
+	Exercises []RoutineExercise
+}
+
+type RoutineRow struct {
+	Id int
+	Routine
+}
+
+// RoutineExercise is one entry of a routine, in order. ExerciseName is filled
+// in on read; zero targets mean "not set". TargetWeight is in kilograms.
+type RoutineExercise struct {
+	ExerciseID   int
+	ExerciseName string
+	TargetSets   int
+	TargetReps   int
+	TargetWeight float64
+}
+
+// ImportedSession is one session read from another app's export. ImportKey
+// identifies it within the user's imports so re-importing is a no-op.
+type ImportedSession struct {
+	ImportKey   string
+	DateTime    string
+	EndDateTime string
+	TimeZone    string
+	Title       string
+	Workouts    []ImportedWorkout
+}
+
+// ImportedWorkout is one exercise of an imported session. Workouts with the
+// same non-zero Group were performed as a superset.
+type ImportedWorkout struct {
+	ExerciseID  int
+	WorkoutName string
+	Group       int
+	Sets        []ImportedSet
+}
+
+// ImportedSet is a set from an import; ImportKey is unique within its workout.
+type ImportedSet struct {
+	ImportKey string
+	Set
+}
+
+// ImportResult reports what ImportSession wrote. Sets already present from an
+// earlier import are counted in SetsSkipped.
+type ImportResult struct {
+	SessionID      int
+	SessionCreated bool
+	SetsCreated    int
+	SetsSkipped    int
+}
+
+// Backup is a complete, portable copy of one user's data. IDs are only
+// meaningful within the document: restoring assigns new ones. Version is the
+// schema version the backup was written with.
+type Backup struct {
+	Version    int
+	ExportedAt string
+	User       User
+	Exercises  []ExerciseRow
+	Sessions   []BackupSession
+	Routines   []RoutineRow
+}
+
+type BackupSession struct {
+	Id          int
+	DateTime    string
+	EndDateTime string
+	TimeZone    string
+	Title       string
+	Notes       string
+	Bodyweight  *float64
+	ImportKey   string
+	Workouts    []BackupWorkout
+}
+
+// BackupWorkout refers to its exercise by ExerciseID when that is one of the
+// backup's custom exercises, and otherwise by the built-in ExerciseName.
+type BackupWorkout struct {
+	Id           int
+	ExerciseID   int
+	ExerciseName string
+	WorkoutName  string
+	Position     int
+	Group        int
+	Sets         []BackupSet
+}
+
+type BackupSet struct {
+	Id        int
+	ImportKey string
+	Set
+}
+
+// RefreshToken is a long-lived credential a client trades for a new access
+// token. Only a hash of the token is stored. The tokens of one login share a
+// FamilyID. UsedAt is set once a token has been traded in, and RevokedAt once
+// its login has been signed out.
+type RefreshToken struct {
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt string
+	UsedAt    string
+	RevokedAt string
+}
+
+type RefreshTokenRow struct {
+	Id int
+	RefreshToken
+}
+
+// ApiToken is a personal token a user's scripts authenticate with. Scope is
+// ApiTokenScopeRead or ApiTokenScopeWrite. LastUsedAt is empty until the
+// token is first used.
+type ApiToken struct {
+	UserID     int
+	Name       string
+	Scope      string
+	CreatedAt  string
+	LastUsedAt string
+}
+
+type ApiTokenRow struct {
+	Id int
+	ApiToken
+}
+
+// UserIdentity links a user to Subject, the ID an identity provider such as
+// "google" knows them by.
+type UserIdentity struct {
+	UserID   int
+	Provider string
+	Subject  string
+}
+
+// PasswordCredential is a user's password for local sign-in. FailedAttempts
+// counts wrong passwords since the last success or lockout, and sign-in is
+// refused until LockedUntil.
+type PasswordCredential struct {
+	UserID         int
+	PasswordHash   string
+	FailedAttempts int
+	LockedUntil    string
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// CreateUserWithPassword adds a user who signs in locally with the password
+// hashed as passwordHash, in one transaction.
+func (d *DBConn) CreateUserWithPassword(user User, passwordHash string) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("CreateUserWithPassword: %w", err)
+	}
+	defer tx.Rollback()
+
+	user = newUser(user)
+	var userID int64
+	if err := tx.QueryRow(d.rebind(insertUserQuery), userValues(user)...).Scan(&userID); err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("User already exists: user with email %s already exists", user.Email)
+		}
+		return 0, fmt.Errorf("CreateUserWithPassword: %w", err)
+	}
+	query := "INSERT INTO PasswordCredential (userID, passwordHash) VALUES (?, ?)"
+	if _, err := tx.Exec(d.rebind(query), userID, passwordHash); err != nil {
+		return 0, fmt.Errorf("CreateUserWithPassword: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("CreateUserWithPassword: %w", err)
+	}
+	return userID, nil
+}
+
+func (d *DBConn) GetPasswordCredential(userID int) (PasswordCredential, error) {
+	var credential PasswordCredential
+	query := "SELECT userID, passwordHash, failedAttempts, COALESCE(lockedUntil, '') FROM PasswordCredential WHERE userID = ?"
+	if err := d.db.QueryRow(d.rebind(query), userID).Scan(&credential.UserID, &credential.PasswordHash, &credential.FailedAttempts, &credential.LockedUntil); err != nil {
+		if err == sql.ErrNoRows {
+			return credential, fmt.Errorf("GetPasswordCredential: %w", ErrNotFound)
+		}
+		return credential, fmt.Errorf("GetPasswordCredential: %w", err)
+	}
+	return credential, nil
+}
+
+// SetPassword sets or replaces the user's password and clears any lockout.
+func (d *DBConn) SetPassword(userID int, passwordHash string) error {
+	query := `
+        INSERT INTO PasswordCredential (userID, passwordHash) VALUES (?, ?)
+        ON CONFLICT (userID) DO UPDATE SET passwordHash = excluded.passwordHash, failedAttempts = 0, lockedUntil = NULL
+    `
+	if _, err := d.db.Exec(d.rebind(query), userID, passwordHash); err != nil {
+		return fmt.Errorf("SetPassword: %w", err)
+	}
+	return nil
+}
+
+// RecordFailedLogin counts a wrong password for the user. The maxAttempts-th
+// one locks sign-in until lockUntil and starts the count again; locked
+// reports whether that happened.
+func (d *DBConn) RecordFailedLogin(userID int, maxAttempts int, lockUntil string) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("RecordFailedLogin: %w", err)
+	}
+	defer tx.Rollback()
+
+	var attempts int
+	query := "UPDATE PasswordCredential SET failedAttempts = failedAttempts + 1 WHERE userID = ? RETURNING failedAttempts"
+	if err := tx.QueryRow(d.rebind(query), userID).Scan(&attempts); err != nil {
+		if err == sql.ErrNoRows {
+			return false, fmt.Errorf("RecordFailedLogin: %w", ErrNotFound)
+		}
+		return false, fmt.Errorf("RecordFailedLogin: %w", err)
+	}
+	locked := attempts >= maxAttempts
+	if locked {
+		query := "UPDATE PasswordCredential SET failedAttempts = 0, lockedUntil = ? WHERE userID = ?"
+		if _, err := tx.Exec(d.rebind(query), lockUntil, userID); err != nil {
+			return false, fmt.Errorf("RecordFailedLogin: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("RecordFailedLogin: %w", err)
+	}
+	return locked, nil
+}
+
+// ResetFailedLogins forgets the user's wrong passwords after a successful
+// sign-in.
+func (d *DBConn) ResetFailedLogins(userID int) error {
+	query := "UPDATE PasswordCredential SET failedAttempts = 0, lockedUntil = NULL WHERE userID = ?"
+	if _, err := d.db.Exec(d.rebind(query), userID); err != nil {
+		return fmt.Errorf("ResetFailedLogins: %w", err)
+	}
+	return nil
+}
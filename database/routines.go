@@ -0,0 +1,250 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func insertRoutineExercises(d *DBConn, tx *sql.Tx, routineID int64, exercises []RoutineExercise) error {
+	query := `
+        INSERT INTO RoutineExercise (routineID, exerciseID, position, targetSets, targetReps, targetWeight)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `
+	for i, exercise := range exercises {
+		if _, err := tx.Exec(d.rebind(query), routineID, exercise.ExerciseID, i,
+			exercise.TargetSets, exercise.TargetReps, exercise.TargetWeight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryRoutineExercises returns the exercises of the matching routines keyed
+// by routineID, each list in routine order.
+func (d *DBConn) queryRoutineExercises(q queryer, query string, args ...any) (map[int][]RoutineExercise, error) {
+	rows, err := q.Query(d.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	exercises := map[int][]RoutineExercise{}
+	for rows.Next() {
+		var routineID int
+		var exercise RoutineExercise
+		if err := rows.Scan(&routineID, &exercise.ExerciseID, &exercise.ExerciseName,
+			&exercise.TargetSets, &exercise.TargetReps, &exercise.TargetWeight); err != nil {
+			return nil, err
+		}
+		exercises[routineID] = append(exercises[routineID], exercise)
+	}
+	return exercises, rows.Err()
+}
+
+// CreateRoutine adds a routine and its exercises in one transaction.
+func (d *DBConn) CreateRoutine(routine Routine) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("CreateRoutine: %w", err)
+	}
+	defer tx.Rollback()
+
+	var routineID int64
+	query := "INSERT INTO Routine (userID, name) VALUES (?, ?) RETURNING routineID"
+	if err := tx.QueryRow(d.rebind(query), routine.UserID, routine.Name).Scan(&routineID); err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("Routine already exists: routine with name %s already exists for user %d", routine.Name, routine.UserID)
+		}
+		return 0, fmt.Errorf("CreateRoutine: %w", err)
+	}
+	if err := insertRoutineExercises(d, tx, routineID, routine.Exercises); err != nil {
+		return 0, fmt.Errorf("CreateRoutine: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("CreateRoutine: %w", err)
+	}
+	return routineID, nil
+}
+
+// GetRoutinesByUserId returns the user's routines ordered by name.
+func (d *DBConn) GetRoutinesByUserId(userID int) ([]RoutineRow, error) {
+	routines, err := listRoutines(d, d.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetRoutinesByUserId: %w", err)
+	}
+	return routines, nil
+}
+
+// listRoutines reads the routines GetRoutinesByUserId returns through q.
+func listRoutines(d *DBConn, q queryer, userID int) ([]RoutineRow, error) {
+	exercises, err := d.queryRoutineExercises(q, `
+        SELECT re.routineID, re.exerciseID, e.name, re.targetSets, re.targetReps, re.targetWeight
+        FROM RoutineExercise re
+        JOIN Routine r ON r.routineID = re.routineID
+        JOIN Exercise e ON e.exerciseID = re.exerciseID
+        WHERE r.userID = ?
+        ORDER BY re.routineID, re.position
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(d.rebind("SELECT routineID, userID, name FROM Routine WHERE userID = ? ORDER BY name"), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var routines []RoutineRow
+	for rows.Next() {
+		var routine RoutineRow
+		if err := rows.Scan(&routine.Id, &routine.UserID, &routine.Name); err != nil {
+			return nil, err
+		}
+		routine.Exercises = exercises[routine.Id]
+		if routine.Exercises == nil {
+			routine.Exercises = []RoutineExercise{}
+		}
+		routines = append(routines, routine)
+	}
+	return routines, rows.Err()
+}
+
+func (d *DBConn) GetRoutineById(routineID int) (RoutineRow, error) {
+	var routine RoutineRow
+	query := "SELECT routineID, userID, name FROM Routine WHERE routineID = ?"
+	if err := d.db.QueryRow(d.rebind(query), routineID).Scan(&routine.Id, &routine.UserID, &routine.Name); err != nil {
+		if err == sql.ErrNoRows {
+			return routine, fmt.Errorf("GetRoutineById: %w", ErrNotFound)
+		}
+		return routine, fmt.Errorf("GetRoutineById: %w", err)
+	}
+	exercises, err := d.queryRoutineExercises(d.db, `
+        SELECT re.routineID, re.exerciseID, e.name, re.targetSets, re.targetReps, re.targetWeight
+        FROM RoutineExercise re
+        JOIN Exercise e ON e.exerciseID = re.exerciseID
+        WHERE re.routineID = ?
+        ORDER BY re.position
+    `, routineID)
+	if err != nil {
+		return routine, fmt.Errorf("GetRoutineById: %w", err)
+	}
+	routine.Exercises = exercises[routineID]
+	if routine.Exercises == nil {
+		routine.Exercises = []RoutineExercise{}
+	}
+	return routine, nil
+}
+
+// UpdateRoutine renames a routine and replaces its exercise list in one
+// transaction.
+func (d *DBConn) UpdateRoutine(routineID int, routine Routine) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("UpdateRoutine: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(d.rebind("UPDATE Routine SET name = ? WHERE routineID = ?"), routine.Name, routineID); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("Routine already exists: routine with name %s already exists for user %d", routine.Name, routine.UserID)
+		}
+		return fmt.Errorf("UpdateRoutine: %w", err)
+	}
+	if _, err := tx.Exec(d.rebind("DELETE FROM RoutineExercise WHERE routineID = ?"), routineID); err != nil {
+		return fmt.Errorf("UpdateRoutine: %w", err)
+	}
+	if err := insertRoutineExercises(d, tx, int64(routineID), routine.Exercises); err != nil {
+		return fmt.Errorf("UpdateRoutine: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("UpdateRoutine: %w", err)
+	}
+	return nil
+}
+
+// DeleteRoutine removes a routine and its exercise list. Sessions started from
+// it are left alone.
+func (d *DBConn) DeleteRoutine(routineID int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("DeleteRoutine: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(d.rebind("DELETE FROM RoutineExercise WHERE routineID = ?"), routineID); err != nil {
+		return fmt.Errorf("DeleteRoutine: deleting exercises: %w", err)
+	}
+	if _, err := tx.Exec(d.rebind("DELETE FROM Routine WHERE routineID = ?"), routineID); err != nil {
+		return fmt.Errorf("DeleteRoutine: deleting routine: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("DeleteRoutine: %w", err)
+	}
+	return nil
+}
+
+// RoutineBelongsToUser reports whether the routine exists and is owned by userID.
+func (d *DBConn) RoutineBelongsToUser(routineID int, userID int) (bool, error) {
+	var exists int
+	query := "SELECT 1 FROM Routine WHERE routineID = ? AND userID = ?"
+	if err := d.db.QueryRow(d.rebind(query), routineID, userID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("RoutineBelongsToUser: %w", err)
+	}
+	return true, nil
+}
+
+// StartRoutine creates session, for session.UserID, with one workout per
+// routine exercise, all in one transaction, and returns the new sessionID.
+func (d *DBConn) StartRoutine(routineID int, session Session) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("StartRoutine: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(d.rebind(`
+        SELECT re.exerciseID, e.name
+        FROM RoutineExercise re
+        JOIN Exercise e ON e.exerciseID = re.exerciseID
+        WHERE re.routineID = ?
+        ORDER BY re.position
+    `), routineID)
+	if err != nil {
+		return 0, fmt.Errorf("StartRoutine: %w", err)
+	}
+	var exercises []RoutineExercise
+	for rows.Next() {
+		var exercise RoutineExercise
+		if err := rows.Scan(&exercise.ExerciseID, &exercise.ExerciseName); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("StartRoutine: %w", err)
+		}
+		exercises = append(exercises, exercise)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("StartRoutine: %w", err)
+	}
+
+	userID := session.UserID
+	var sessionID int64
+	query := "INSERT INTO Session (userID, " + sessionWriteColumns + ") VALUES (?, " + sessionWritePlaceholders + ") RETURNING sessionID"
+	if err := tx.QueryRow(d.rebind(query), append([]any{userID}, sessionValues(session)...)...).Scan(&sessionID); err != nil {
+		return 0, fmt.Errorf("StartRoutine: creating session: %w", err)
+	}
+	query = "INSERT INTO Workouts (sessionID, exerciseID, workoutname, userID, position) VALUES (?, ?, ?, ?, ?)"
+	for i, exercise := range exercises {
+		if _, err := tx.Exec(d.rebind(query), sessionID, exercise.ExerciseID, exercise.ExerciseName, userID, i); err != nil {
+			if isUniqueViolation(err) {
+				return 0, fmt.Errorf("Workout already exists: routine %d lists %s more than once", routineID, exercise.ExerciseName)
+			}
+			return 0, fmt.Errorf("StartRoutine: creating workout: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("StartRoutine: %w", err)
+	}
+	return sessionID, nil
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones must load on hosts without a zoneinfo database
+)
+
+// DefaultTimeZone is the zone for users and sessions that never set one.
+const DefaultTimeZone = "UTC"
+
+// ValidTimeZone reports whether name is an IANA time zone such as
+// "Europe/Berlin". The process-dependent "Local" is not accepted.
+func ValidTimeZone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// NowDateTime is the current time laid out for Session.DateTime.
+func NowDateTime() string {
+	return time.Now().UTC().Format(DateTimeLayout)
+}
+
+// sessionColumns are the Session columns read by sessionFields, in order.
+const sessionColumns = "sessionID, userID, dateTime, COALESCE(endDateTime, ''), timeZone, title, notes, bodyweight"
+
+// sessionFields returns the scan destinations for sessionColumns.
+func sessionFields(session *SessionRow) []any {
+	return []any{&session.Id, &session.UserID, &session.DateTime, &session.EndDateTime, &session.TimeZone, &session.Title, &session.Notes, &session.Bodyweight}
+}
+
+// sessionWriteColumns are the Session columns written from a Session by
+// sessionValues, other than userID.
+const (
+	sessionWriteColumns      = "dateTime, endDateTime, timeZone, title, notes, bodyweight"
+	sessionWritePlaceholders = "?, ?, ?, ?, ?, ?"
+)
+
+// sessionOrder lists sessions newest first; sessionID breaks ties between
+// sessions that started at the same time.
+const sessionOrder = "dateTime DESC, sessionID DESC"
+
+// sessionConditions builds the WHERE clause that selects the user's sessions
+// matching filter, with its arguments. The cursor is only applied when paged,
+// so counts cover the whole listing.
+func sessionConditions(userID int, filter SessionFilter, paged bool) (string, []any) {
+	where := "userID = ?"
+	args := []any{userID}
+	if filter.From != "" {
+		where += " AND dateTime >= ?"
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		where += " AND dateTime < ?"
+		args = append(args, filter.To)
+	}
+	if filter.ExerciseID != 0 {
+		where += " AND sessionID IN (SELECT sessionID FROM Workouts WHERE userID = ? AND exerciseID = ?)"
+		args = append(args, userID, filter.ExerciseID)
+	}
+	if paged && filter.After != nil {
+		where += " AND (dateTime < ? OR (dateTime = ? AND sessionID < ?))"
+		args = append(args, filter.After.DateTime, filter.After.DateTime, filter.After.Id)
+	}
+	return where, args
+}
+
+// CountSessions returns how many of the user's sessions match filter, ignoring
+// its cursor and limit.
+func (d *DBConn) CountSessions(userID int, filter SessionFilter) (int, error) {
+	where, args := sessionConditions(userID, filter, false)
+	var count int
+	if err := d.db.QueryRow(d.rebind("SELECT COUNT(*) FROM Session WHERE "+where), args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("CountSessions: %w", err)
+	}
+	return count, nil
+}
+
+// GetSessionVolumes totals weight times reps, in kilograms, over the sets of
+// each session that count towards records. Sessions without such sets are
+// left out.
+func (d *DBConn) GetSessionVolumes(sessionIDs []int) (map[int]float64, error) {
+	volumes := map[int]float64{}
+	if len(sessionIDs) == 0 {
+		return volumes, nil
+	}
+	// Mirrors Set.CountsTowardsRecords.
+	query := `
+        SELECT w.sessionID, SUM(st.weight * st.numberofReps)
+        FROM Sets st
+        JOIN Workouts w ON w.workoutID = st.workoutID
+        WHERE w.sessionID IN (?` + strings.Repeat(", ?", len(sessionIDs)-1) + `)
+          AND st.completed = ? AND st.setType <> ?
+        GROUP BY w.sessionID
+    `
+	args := make([]any, 0, len(sessionIDs)+2)
+	for _, id := range sessionIDs {
+		args = append(args, id)
+	}
+	rows, err := d.db.Query(d.rebind(query), append(args, true, SetTypeWarmUp)...)
+	if err != nil {
+		return nil, fmt.Errorf("GetSessionVolumes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var volume float64
+		if err := rows.Scan(&id, &volume); err != nil {
+			return nil, fmt.Errorf("GetSessionVolumes: %w", err)
+		}
+		volumes[id] = volume
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetSessionVolumes: %w", err)
+	}
+	return volumes, nil
+}
+
+// newSession fills in what a session being saved leaves out: it starts now
+// and is in the default zone.
+func newSession(session Session) Session {
+	if session.DateTime == "" {
+		session.DateTime = NowDateTime()
+	}
+	if session.TimeZone == "" {
+		session.TimeZone = DefaultTimeZone
+	}
+	return session
+}
+
+// sessionValues returns the values for sessionWriteColumns.
+func sessionValues(session Session) []any {
+	session = newSession(session)
+	return []any{session.DateTime, nullIfEmpty(session.EndDateTime), session.TimeZone, session.Title, session.Notes, session.Bodyweight}
+}
+
+// sessionTimesToUTC moves the start times of sessions the server created,
+// which it wrote in its own local time, to UTC. Imported sessions hold the
+// other app's wall-clock time and are left alone.
+//
+// The old times carry no zone, so this assumes the upgraded server runs in the
+// zone it wrote them in: time.Local, which Go takes from TZ (or the system
+// zone when TZ is unset). The README asks operators to keep TZ for the upgrade.
+func sessionTimesToUTC(d *DBConn, tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT sessionID, dateTime FROM Session WHERE importKey IS NULL")
+	if err != nil {
+		return fmt.Errorf("sessionTimesToUTC: %w", err)
+	}
+	utc := map[int]string{}
+	for rows.Next() {
+		var id int
+		var dateTime string
+		if err := rows.Scan(&id, &dateTime); err != nil {
+			rows.Close()
+			return fmt.Errorf("sessionTimesToUTC: %w", err)
+		}
+		if t, err := time.ParseInLocation(DateTimeLayout, dateTime, time.Local); err == nil {
+			utc[id] = t.UTC().Format(DateTimeLayout)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("sessionTimesToUTC: %w", err)
+	}
+	for id, dateTime := range utc {
+		if _, err := tx.Exec(d.rebind("UPDATE Session SET dateTime = ? WHERE sessionID = ?"), dateTime, id); err != nil {
+			return fmt.Errorf("sessionTimesToUTC: session %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// sessionDetailsVersion is the schema version that added session end times,
+// zones, titles, notes and bodyweight, for reading older backups.
+const sessionDetailsVersion = 9
+
+// restoredSession checks a session read from a backup written at schema
+// version and fills in the fields that version did not have.
+func restoredSession(session BackupSession, version int) (Session, error) {
+	restored := Session{
+		DateTime:    session.DateTime,
+		EndDateTime: session.EndDateTime,
+		TimeZone:    session.TimeZone,
+		Title:       session.Title,
+		Notes:       session.Notes,
+		Bodyweight:  session.Bodyweight,
+	}
+	if version < sessionDetailsVersion {
+		restored.TimeZone = DefaultTimeZone
+	}
+	if !ValidTimeZone(restored.TimeZone) {
+		return restored, fmt.Errorf("unknown time zone %q: %w", restored.TimeZone, ErrInvalidBackup)
+	}
+	if restored.Bodyweight != nil && *restored.Bodyweight <= 0 {
+		return restored, fmt.Errorf("invalid bodyweight: %w", ErrInvalidBackup)
+	}
+	return restored, nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// BenchmarkSessionWorkouts loads a session with many workouts and their sets,
+// one query per workout as WorkoutListHandler used to, against the single
+// GetSetsBySessionId query behind GET /sessions/{id}.
+func BenchmarkSessionWorkouts(b *testing.B) {
+	const workouts, setsPerWorkout = 30, 5
+	d, err := CreateDBConnection(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer d.CloseConn()
+	userID := int(must(d.CreateUser(User{Email: "bench@example.com"})))
+	sessionID := int(must(d.CreateSessionForUser(Session{UserID: userID})))
+	exercises := must(d.GetExercises(userID))
+	if len(exercises) < workouts {
+		b.Fatalf("only %d exercises to seed %d workouts", len(exercises), workouts)
+	}
+	for _, exercise := range exercises[:workouts] {
+		workoutID := int(must(d.CreateWorkoutForSession(sessionID, exercise.Id, exercise.Name, userID)))
+		for i := 0; i < setsPerWorkout; i++ {
+			must(d.CreateSetForWorkout(Set{WorkoutID: workoutID, Weight: 60, NumberOfReps: 8, Unit: UnitKg, Type: SetTypeWorking, Completed: true}))
+		}
+	}
+
+	b.Run("per workout", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, workout := range must(d.GetWorkoutsBySessionId(sessionID)) {
+				must(d.GetSetsByWorkoutId(workout.Id))
+			}
+		}
+	})
+	b.Run("by session", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			must(d.GetWorkoutsBySessionId(sessionID))
+			must(d.GetSetsBySessionId(sessionID))
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Set types.
+const (
+	SetTypeWarmUp  = "warmup"
+	SetTypeWorking = "working"
+	SetTypeDrop    = "drop"
+	SetTypeFailure = "failure"
+	SetTypeAMRAP   = "amrap"
+)
+
+// SetTypes lists the valid set types.
+var SetTypes = []string{SetTypeWarmUp, SetTypeWorking, SetTypeDrop, SetTypeFailure, SetTypeAMRAP}
+
+// CountsTowardsRecords reports whether a set is part of the actual training:
+// warm-ups and sets that were not completed are left out of personal records
+// and volume.
+func (s Set) CountsTowardsRecords() bool {
+	return s.Completed && s.Type != SetTypeWarmUp
+}
+
+// setColumns are the Sets columns read by setFields, in order.
+const setColumns = "st.setID, st.workoutID, st.weight, st.numberofReps, st.unit, st.rpe, st.rir, st.setType, st.tempo, st.completed, st.duration, st.distance, st.calories"
+
+// setFields returns the scan destinations for setColumns.
+func setFields(set *SetRow) []any {
+	return []any{&set.Id, &set.WorkoutID, &set.Weight, &set.NumberOfReps, &set.Unit, &set.RPE, &set.RIR, &set.Type, &set.Tempo, &set.Completed, &set.Duration, &set.Distance, &set.Calories}
+}
+
+// setWriteColumns are the Sets columns written from a Set by setValues, other
+// than workoutID.
+const (
+	setWriteColumns      = "numberofReps, weight, unit, rpe, rir, setType, tempo, completed, duration, distance, calories"
+	setWritePlaceholders = "?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?"
+)
+
+func setValues(set Set) []any {
+	return []any{set.NumberOfReps, set.Weight, set.Unit, set.RPE, set.RIR, set.Type, set.Tempo, set.Completed, set.Duration, set.Distance, set.Calories}
+}
+
+// Schema versions that added set fields, for reading older backups.
+const (
+	unitsVersion      = 5
+	setDetailsVersion = 6
+)
+
+// restoredSet checks a set read from a backup written at schema version and
+// fills in the fields that version did not have.
+func restoredSet(set BackupSet, version int) (Set, error) {
+	if version < unitsVersion {
+		set.Unit = UnitKg
+	}
+	if version < setDetailsVersion {
+		set.Type = SetTypeWorking
+		set.Completed = true
+	}
+	if set.Weight < 0 || set.NumberOfReps < 0 {
+		return set.Set, fmt.Errorf("negative weight or reps: %w", ErrInvalidBackup)
+	}
+	if !validUnit(set.Unit) {
+		return set.Set, fmt.Errorf("unknown unit %q: %w", set.Unit, ErrInvalidBackup)
+	}
+	if !slices.Contains(SetTypes, set.Type) {
+		return set.Set, fmt.Errorf("unknown set type %q: %w", set.Type, ErrInvalidBackup)
+	}
+	return set.Set, nil
+}
@@ -3,14 +3,15 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"time"
 
-	"modernc.org/sqlite"
-	sqlite3 "modernc.org/sqlite/lib"
+	_ "modernc.org/sqlite"
 )
 
+// DBConn is the SQL-backed Store. The same queries serve SQLite and
+// PostgreSQL (see CreatePostgresConnection); dialect covers the differences.
 type DBConn struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect dialect
 }
 
 func CreateDBConnection(dbPath string) (*DBConn, error) {
@@ -18,70 +19,129 @@ func CreateDBConnection(dbPath string) (*DBConn, error) {
 	if err != nil {
 		return nil, fmt.Errorf("CreateDBConnection: %w", err)
 	}
-	return &DBConn{db: db}, err
+	conn := &DBConn{db: db, dialect: dialectSQLite}
+	if err := conn.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("CreateDBConnection: %w", err)
+	}
+	return conn, nil
 }
 
 func (d *DBConn) CloseConn() error {
 	return d.db.Close()
 }
 
+// queryer is the subset of *sql.DB and *sql.Tx shared by helpers that run
+// both inside and outside a transaction.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// rebind adapts a query written with ? placeholders to the connection's dialect.
+func (d *DBConn) rebind(query string) string {
+	return d.dialect.rebind(query)
+}
+
 func (d *DBConn) CreateUser(user User) (int64, error) {
-	stmt, err := d.db.Prepare("INSERT INTO User (email, name) VALUES (?, ?)")
+	stmt, err := d.db.Prepare(d.rebind(insertUserQuery))
 	if err != nil {
 		return 0, fmt.Errorf("CreateUser: error preparing statement: %w", err)
 	}
 	defer stmt.Close()
 
-	result, err := stmt.Exec(user.Email, user.Name)
-	if err != nil {
+	var userID int64
+	user = newUser(user)
+	if err := stmt.QueryRow(userValues(user)...).Scan(&userID); err != nil {
 		return 0, fmt.Errorf("CreateUser: error executing statement: %w", err)
 	}
-	userID, err := result.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("CreateUser: Error getting last insert ID: %w", err)
-	}
 	return userID, nil
 }
 
 func (d *DBConn) GetUserByEmail(email string) (UserRow, error) {
 	// Query to get a user by email
-	query := "SELECT userId, email, name FROM User WHERE email = ?"
+	query := `SELECT ` + userColumns + ` FROM "User" WHERE email = ?`
 	var user UserRow
 
 	// Execute the query with the specified email
-	if err := d.db.QueryRow(query, email).Scan(&user.Id, &user.Email, &user.Name); err != nil {
+	if err := d.db.QueryRow(d.rebind(query), email).Scan(userFields(&user)...); err != nil {
 		if err == sql.ErrNoRows {
-			return user, fmt.Errorf("no user found") // TODO: do a not found error later
+			return user, fmt.Errorf("no user found: %w", ErrNotFound)
 		}
 		return user, fmt.Errorf("GetUserByEmail: %v", err)
 	}
 	return user, nil
 }
 
-func (d *DBConn) CreateSessionForUser(userID int) error {
+func (d *DBConn) GetUserById(userID int) (UserRow, error) {
+	query := `SELECT ` + userColumns + ` FROM "User" WHERE userId = ?`
+	var user UserRow
+	if err := d.db.QueryRow(d.rebind(query), userID).Scan(userFields(&user)...); err != nil {
+		if err == sql.ErrNoRows {
+			return user, fmt.Errorf("GetUserById: %w", ErrNotFound)
+		}
+		return user, fmt.Errorf("GetUserById: %w", err)
+	}
+	return user, nil
+}
+
+// UpdateUser saves the user's editable fields. The email identifies the
+// account and is not changed, nor are the picture and locale, which come from
+// the identity provider.
+func (d *DBConn) UpdateUser(userID int, user User) error {
+	query := `
+        UPDATE "User" SET name = ?, nameEdited = ?, unit = ?, timeZone = ?, bodyweight = ?
+        WHERE userId = ?
+    `
+	if _, err := d.db.Exec(d.rebind(query), user.Name, user.NameEdited, user.Unit, user.TimeZone, user.Bodyweight, userID); err != nil {
+		return fmt.Errorf("UpdateUser: %w", err)
+	}
+	return nil
+}
+
+// UpdateUserProfile saves the name, picture and locale an identity provider
+// sent at sign-in. The name is left alone if the user has changed it since
+// their profile was read.
+func (d *DBConn) UpdateUserProfile(userID int, user User) error {
+	query := `UPDATE "User" SET name = CASE WHEN nameEdited THEN name ELSE ? END, picture = ?, locale = ? WHERE userId = ?`
+	if _, err := d.db.Exec(d.rebind(query), user.Name, nullIfEmpty(user.Picture), nullIfEmpty(user.Locale), userID); err != nil {
+		return fmt.Errorf("UpdateUserProfile: %w", err)
+	}
+	return nil
+}
+
+// CreateSessionForUser saves a new session for session.UserID. It starts now
+// unless session.DateTime says otherwise.
+func (d *DBConn) CreateSessionForUser(session Session) (int64, error) {
 	// Prepare the insert statement
-	stmt, err := d.db.Prepare("INSERT INTO Session (userID, dateTime) VALUES (?, ?)")
+	stmt, err := d.db.Prepare(d.rebind("INSERT INTO Session (userID, " + sessionWriteColumns + ") VALUES (?, " + sessionWritePlaceholders + ") RETURNING sessionID"))
 	if err != nil {
-		return fmt.Errorf("CreateSessionForUser: %v", err)
+		return 0, fmt.Errorf("CreateSessionForUser: %v", err)
 	}
 	defer stmt.Close()
 
-	dateTime := time.Now().Format("2006-01-02 15:04:05")
-
 	// Execute the insert statement
-	_, err = stmt.Exec(userID, dateTime)
-	if err != nil {
-		return fmt.Errorf("CreateSessionForUser: %w", err)
+	var sessionID int64
+	if err := stmt.QueryRow(append([]any{session.UserID}, sessionValues(session)...)...).Scan(&sessionID); err != nil {
+		return 0, fmt.Errorf("CreateSessionForUser: %w", err)
 	}
-	return nil
+	return sessionID, nil
 }
 
-func (d *DBConn) GetSessionsByUserId(userId int) ([]SessionRow, error) {
-	// Query to get all sessions for the specified userID
-	query := "SELECT sessionID, userID, dateTime FROM Session WHERE userID = ? ORDER BY sessionID DESC"
+// GetSessionsByUserId lists the user's sessions that match filter, newest
+// first.
+func (d *DBConn) GetSessionsByUserId(userId int, filter SessionFilter) ([]SessionRow, error) {
+	// Query to get the matching sessions for the specified userID
+	where, args := sessionConditions(userId, filter, true)
+	query := "SELECT " + sessionColumns + " FROM Session WHERE " + where + " ORDER BY " + sessionOrder
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
 
 	// Execute the query
-	rows, err := d.db.Query(query, userId)
+	rows, err := d.db.Query(d.rebind(query), args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetSessionsByUserId: Error executing query: %w", err)
 	}
@@ -91,7 +151,7 @@ func (d *DBConn) GetSessionsByUserId(userId int) ([]SessionRow, error) {
 	var sessions []SessionRow
 	for rows.Next() {
 		var sess SessionRow
-		err := rows.Scan(&sess.Id, &sess.UserID, &sess.DateTime)
+		err := rows.Scan(sessionFields(&sess)...)
 		if err != nil {
 			return nil, fmt.Errorf("GetSessionsByUserId: Error scanning row: %w", err)
 		}
@@ -107,10 +167,10 @@ func (d *DBConn) GetSessionsByUserId(userId int) ([]SessionRow, error) {
 
 func (d *DBConn) GetWorkoutsBySessionId(sessionId int) ([]WorkoutRow, error) {
 	// Query to get workouts for the specified sessionID
-	query := "SELECT workoutID, sessionID, workoutname FROM Workouts WHERE sessionID = ? ORDER BY workoutID DESC"
+	query := "SELECT " + workoutColumns + " FROM Workouts WHERE sessionID = ? ORDER BY " + workoutOrder
 
 	// Execute the query
-	rows, err := d.db.Query(query, sessionId)
+	rows, err := d.db.Query(d.rebind(query), sessionId)
 	if err != nil {
 		return nil, fmt.Errorf("GetWorkoutsBySessionId: Error executing query: %w", err)
 	}
@@ -120,7 +180,7 @@ func (d *DBConn) GetWorkoutsBySessionId(sessionId int) ([]WorkoutRow, error) {
 	var workouts []WorkoutRow
 	for rows.Next() {
 		var workout WorkoutRow
-		err := rows.Scan(&workout.Id, &workout.SessionID, &workout.WorkoutName)
+		err := rows.Scan(workoutFields(&workout)...)
 		if err != nil {
 			return nil, fmt.Errorf("Error scanning row: %w", err)
 		}
@@ -135,65 +195,55 @@ func (d *DBConn) GetWorkoutsBySessionId(sessionId int) ([]WorkoutRow, error) {
 	return workouts, nil
 }
 
-func (d *DBConn) CreateWorkoutForSession(sessionId int, workoutname string, userId int) error {
-	stmt, err := d.db.Prepare("INSERT INTO Workouts (sessionID, workoutname, userID) VALUES (?, ?, ?)")
+// CreateWorkoutForSession adds a workout at the top of the session, on its own.
+func (d *DBConn) CreateWorkoutForSession(sessionId int, exerciseID int, workoutname string, userId int) (int64, error) {
+	tx, err := d.db.Begin()
 	if err != nil {
-		return fmt.Errorf("Error preparing statement: %w", err)
+		return 0, fmt.Errorf("CreateWorkoutForSession: %w", err)
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	// Execute the insert statement
-	_, err = stmt.Exec(sessionId, workoutname, userId)
-	if err != nil {
-		sqliteErr, ok := err.(*sqlite.Error)
-		if ok && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
-			return fmt.Errorf("Workout already exists: workout with name %s for session %d already exists for user %d", workoutname, sessionId, userId)
+	if _, err := tx.Exec(d.rebind("UPDATE Workouts SET position = position + 1 WHERE sessionID = ?"), sessionId); err != nil {
+		return 0, fmt.Errorf("CreateWorkoutForSession: %w", err)
+	}
+	// Execute the insert statement and read back the new workoutID
+	var workoutID int64
+	query := "INSERT INTO Workouts (sessionID, exerciseID, workoutname, userID, position) VALUES (?, ?, ?, ?, 0) RETURNING workoutID"
+	if err := tx.QueryRow(d.rebind(query), sessionId, exerciseID, workoutname, userId).Scan(&workoutID); err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("Workout already exists: session %d already has a workout of exercise %d", sessionId, exerciseID)
 		}
 
-		return fmt.Errorf("Error inserting new workout: %w", err)
+		return 0, fmt.Errorf("Error inserting new workout: %w", err)
 	}
-	return nil
-	// // Get the last inserted ID (workoutID)
-	// workoutID, err := result.LastInsertId()
-	// if err != nil {
-	//     fmt.Println("Error getting last insert ID:", err)
-	//     return
-	// }
-
-	// fmt.Printf("New workout created successfully with workoutID: %d\n", workoutID)
-
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("CreateWorkoutForSession: %w", err)
+	}
+	return workoutID, nil
 }
 
-func (d *DBConn) CreateSetForWorkout(workoutId int, numberofReps int, weight float32) error {
-	stmt, err := d.db.Prepare("INSERT INTO Sets (numberofReps, weight, workoutID) VALUES (?, ?, ?)")
+func (d *DBConn) CreateSetForWorkout(set Set) (int64, error) {
+	query := "INSERT INTO Sets (workoutID, " + setWriteColumns + ") VALUES (?, " + setWritePlaceholders + ") RETURNING setID"
+	stmt, err := d.db.Prepare(d.rebind(query))
 	if err != nil {
-		return fmt.Errorf("Error preparing statement: %w", err)
+		return 0, fmt.Errorf("Error preparing statement: %w", err)
 	}
 	defer stmt.Close()
 
-	// Execute the insert statement
-	_, err = stmt.Exec(numberofReps, weight, workoutId)
-	if err != nil {
-		return fmt.Errorf("Error inserting new set: %w", err)
+	// Execute the insert statement and read back the new setID
+	var setID int64
+	if err := stmt.QueryRow(append([]any{set.WorkoutID}, setValues(set)...)...).Scan(&setID); err != nil {
+		return 0, fmt.Errorf("Error inserting new set: %w", err)
 	}
-
-	// // Get the last inserted ID (setID)
-	// setID, err := result.LastInsertId()
-	// if err != nil {
-	//     fmt.Println("Error getting last insert ID:", err)
-	//     return
-	// }
-
-	// fmt.Printf("New set created successfully with setID: %d\n", setID)
-	return nil
+	return setID, nil
 }
 
 func (d *DBConn) GetSetsByWorkoutId(workoutID int) ([]SetRow, error) {
 	// Query to get sets for the specified workoutID
-	query := "SELECT setID, numberofReps, weight, workoutID FROM Sets WHERE workoutID = ? ORDER BY setID DESC"
+	query := "SELECT " + setColumns + " FROM Sets st WHERE st.workoutID = ? ORDER BY st.setID DESC"
 
 	// Execute the query
-	rows, err := d.db.Query(query, workoutID)
+	rows, err := d.db.Query(d.rebind(query), workoutID)
 	if err != nil {
 		return nil, fmt.Errorf("GetSetsByWorkoutId: %w", err)
 	}
@@ -203,7 +253,7 @@ func (d *DBConn) GetSetsByWorkoutId(workoutID int) ([]SetRow, error) {
 	var sets []SetRow
 	for rows.Next() {
 		var set SetRow
-		err := rows.Scan(&set.Id, &set.NumberOfReps, &set.Weight, &set.WorkoutID)
+		err := rows.Scan(setFields(&set)...)
 		if err != nil {
 			return nil, fmt.Errorf("GetSetsByWorkoutId: %w", err)
 		}
@@ -217,17 +267,312 @@ func (d *DBConn) GetSetsByWorkoutId(workoutID int) ([]SetRow, error) {
 	return sets, nil
 }
 
-func (d *DBConn) GetLastWorkoutID(workoutName string, userID int) (int, error) {
+// GetSetsBySessionId returns the sets of every workout in the session, in
+// the order GetWorkoutsBySessionId lists the workouts and then newest first
+// like GetSetsByWorkoutId, so a whole session loads without a query per
+// workout.
+func (d *DBConn) GetSetsBySessionId(sessionID int) ([]SetRow, error) {
+	query := `
+        SELECT ` + setColumns + `
+        FROM Sets st
+        JOIN Workouts w ON w.workoutID = st.workoutID
+        WHERE w.sessionID = ?
+        ORDER BY w.position, w.workoutID DESC, st.setID DESC
+    `
+	rows, err := d.db.Query(d.rebind(query), sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("GetSetsBySessionId: %w", err)
+	}
+	defer rows.Close()
+
+	var sets []SetRow
+	for rows.Next() {
+		var set SetRow
+		if err := rows.Scan(setFields(&set)...); err != nil {
+			return nil, fmt.Errorf("GetSetsBySessionId: %w", err)
+		}
+		sets = append(sets, set)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetSetsBySessionId: %w", err)
+	}
+	return sets, nil
+}
+
+func (d *DBConn) GetLastWorkoutID(exerciseID int, userID int) (int, error) {
 	var workoutID int
 	query := `
         SELECT workoutID
         FROM Workouts
-        WHERE workoutName = ? AND userID = ?
+        WHERE exerciseID = ? AND userID = ?
         ORDER BY workoutID DESC
         LIMIT 1 OFFSET 1
     `
-	if err := d.db.QueryRow(query, workoutName, userID).Scan(&workoutID); err != nil && err != sql.ErrNoRows {
+	if err := d.db.QueryRow(d.rebind(query), exerciseID, userID).Scan(&workoutID); err != nil && err != sql.ErrNoRows {
 		return workoutID, err
 	}
 	return workoutID, nil
 }
+
+// SessionBelongsToUser reports whether the session exists and is owned by userID.
+func (d *DBConn) SessionBelongsToUser(sessionID int, userID int) (bool, error) {
+	var exists int
+	query := "SELECT 1 FROM Session WHERE sessionID = ? AND userID = ?"
+	if err := d.db.QueryRow(d.rebind(query), sessionID, userID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("SessionBelongsToUser: %w", err)
+	}
+	return true, nil
+}
+
+// WorkoutBelongsToUser reports whether the workout exists and sits in a
+// session owned by userID.
+func (d *DBConn) WorkoutBelongsToUser(workoutID int, userID int) (bool, error) {
+	var exists int
+	query := `
+        SELECT 1
+        FROM Workouts w
+        JOIN Session s ON s.sessionID = w.sessionID
+        WHERE w.workoutID = ? AND s.userID = ?
+    `
+	if err := d.db.QueryRow(d.rebind(query), workoutID, userID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("WorkoutBelongsToUser: %w", err)
+	}
+	return true, nil
+}
+
+// SetBelongsToUser reports whether the set exists and belongs to a workout in
+// a session owned by userID.
+func (d *DBConn) SetBelongsToUser(setID int, userID int) (bool, error) {
+	var exists int
+	query := `
+        SELECT 1
+        FROM Sets st
+        JOIN Workouts w ON w.workoutID = st.workoutID
+        JOIN Session s ON s.sessionID = w.sessionID
+        WHERE st.setID = ? AND s.userID = ?
+    `
+	if err := d.db.QueryRow(d.rebind(query), setID, userID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("SetBelongsToUser: %w", err)
+	}
+	return true, nil
+}
+
+func (d *DBConn) GetSessionById(sessionID int) (SessionRow, error) {
+	query := "SELECT " + sessionColumns + " FROM Session WHERE sessionID = ?"
+	var sess SessionRow
+	if err := d.db.QueryRow(d.rebind(query), sessionID).Scan(sessionFields(&sess)...); err != nil {
+		if err == sql.ErrNoRows {
+			return sess, fmt.Errorf("GetSessionById: %w", ErrNotFound)
+		}
+		return sess, fmt.Errorf("GetSessionById: %w", err)
+	}
+	return sess, nil
+}
+
+func (d *DBConn) GetWorkoutById(workoutID int) (WorkoutRow, error) {
+	query := "SELECT " + workoutColumns + " FROM Workouts WHERE workoutID = ?"
+	var workout WorkoutRow
+	if err := d.db.QueryRow(d.rebind(query), workoutID).Scan(workoutFields(&workout)...); err != nil {
+		if err == sql.ErrNoRows {
+			return workout, fmt.Errorf("GetWorkoutById: %w", ErrNotFound)
+		}
+		return workout, fmt.Errorf("GetWorkoutById: %w", err)
+	}
+	return workout, nil
+}
+
+func (d *DBConn) GetSetById(setID int) (SetRow, error) {
+	query := "SELECT " + setColumns + " FROM Sets st WHERE st.setID = ?"
+	var set SetRow
+	if err := d.db.QueryRow(d.rebind(query), setID).Scan(setFields(&set)...); err != nil {
+		if err == sql.ErrNoRows {
+			return set, fmt.Errorf("GetSetById: %w", ErrNotFound)
+		}
+		return set, fmt.Errorf("GetSetById: %w", err)
+	}
+	return set, nil
+}
+
+func (d *DBConn) UpdateSession(sessionID int, session Session) error {
+	query := "UPDATE Session SET (" + sessionWriteColumns + ") = (" + sessionWritePlaceholders + ") WHERE sessionID = ?"
+	if _, err := d.db.Exec(d.rebind(query), append(sessionValues(session), sessionID)...); err != nil {
+		return fmt.Errorf("UpdateSession: %w", err)
+	}
+	return nil
+}
+
+func (d *DBConn) UpdateWorkout(workoutID int, exerciseID int, workoutname string) error {
+	_, err := d.db.Exec(d.rebind("UPDATE Workouts SET exerciseID = ?, workoutname = ? WHERE workoutID = ?"), exerciseID, workoutname, workoutID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("Workout already exists: the session of workout %d already has a workout of exercise %d", workoutID, exerciseID)
+		}
+		return fmt.Errorf("UpdateWorkout: %w", err)
+	}
+	return nil
+}
+
+func (d *DBConn) UpdateSet(setID int, set Set) error {
+	query := "UPDATE Sets SET (" + setWriteColumns + ") = (" + setWritePlaceholders + ") WHERE setID = ?"
+	if _, err := d.db.Exec(d.rebind(query), append(setValues(set), setID)...); err != nil {
+		return fmt.Errorf("UpdateSet: %w", err)
+	}
+	return nil
+}
+
+// DeleteSession removes a session together with all of its workouts and
+// their sets in a single transaction.
+func (d *DBConn) DeleteSession(sessionID int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("DeleteSession: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(d.rebind("DELETE FROM Sets WHERE workoutID IN (SELECT workoutID FROM Workouts WHERE sessionID = ?)"), sessionID); err != nil {
+		return fmt.Errorf("DeleteSession: deleting sets: %w", err)
+	}
+	if _, err := tx.Exec(d.rebind("DELETE FROM Workouts WHERE sessionID = ?"), sessionID); err != nil {
+		return fmt.Errorf("DeleteSession: deleting workouts: %w", err)
+	}
+	if _, err := tx.Exec(d.rebind("DELETE FROM Session WHERE sessionID = ?"), sessionID); err != nil {
+		return fmt.Errorf("DeleteSession: deleting session: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("DeleteSession: %w", err)
+	}
+	return nil
+}
+
+// DeleteWorkout removes a workout and its sets in a single transaction.
+func (d *DBConn) DeleteWorkout(workoutID int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("DeleteWorkout: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(d.rebind("DELETE FROM Sets WHERE workoutID = ?"), workoutID); err != nil {
+		return fmt.Errorf("DeleteWorkout: deleting sets: %w", err)
+	}
+	if _, err := tx.Exec(d.rebind("DELETE FROM Workouts WHERE workoutID = ?"), workoutID); err != nil {
+		return fmt.Errorf("DeleteWorkout: deleting workout: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("DeleteWorkout: %w", err)
+	}
+	return nil
+}
+
+func (d *DBConn) DeleteSet(setID int) error {
+	if _, err := d.db.Exec(d.rebind("DELETE FROM Sets WHERE setID = ?"), setID); err != nil {
+		return fmt.Errorf("DeleteSet: %w", err)
+	}
+	return nil
+}
+
+// GetSetHistoryForExercise returns every set the user has logged for the
+// exercise, oldest session first.
+func (d *DBConn) GetSetHistoryForExercise(exerciseID int, userID int) ([]ExerciseSet, error) {
+	query := `
+        SELECT s.sessionID, s.dateTime, e.exerciseID, e.name, w.groupNumber, ` + setColumns + `
+        FROM Sets st
+        JOIN Workouts w ON w.workoutID = st.workoutID
+        JOIN Session s ON s.sessionID = w.sessionID
+        JOIN Exercise e ON e.exerciseID = w.exerciseID
+        WHERE w.exerciseID = ? AND s.userID = ?
+        ORDER BY s.dateTime, s.sessionID, st.setID
+    `
+	history, err := d.querySetHistory(query, exerciseID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetSetHistoryForExercise: %w", err)
+	}
+	return history, nil
+}
+
+// GetSetHistoryForUser returns every set the user has logged across all
+// exercises, oldest session first.
+func (d *DBConn) GetSetHistoryForUser(userID int) ([]ExerciseSet, error) {
+	query := `
+        SELECT s.sessionID, s.dateTime, e.exerciseID, e.name, w.groupNumber, ` + setColumns + `
+        FROM Sets st
+        JOIN Workouts w ON w.workoutID = st.workoutID
+        JOIN Session s ON s.sessionID = w.sessionID
+        JOIN Exercise e ON e.exerciseID = w.exerciseID
+        WHERE s.userID = ?
+        ORDER BY s.dateTime, s.sessionID, st.setID
+    `
+	history, err := d.querySetHistory(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetSetHistoryForUser: %w", err)
+	}
+	return history, nil
+}
+
+func (d *DBConn) querySetHistory(query string, args ...any) ([]ExerciseSet, error) {
+	rows, err := d.db.Query(d.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []ExerciseSet
+	for rows.Next() {
+		var set ExerciseSet
+		if err := rows.Scan(append([]any{&set.SessionID, &set.DateTime, &set.ExerciseID, &set.ExerciseName, &set.Group}, setFields(&set.SetRow)...)...); err != nil {
+			return nil, err
+		}
+		history = append(history, set)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// ExportSetHistory calls fn for every set the user logged in sessions between
+// from (inclusive) and to (exclusive), ordered by session, then workouts in
+// the order the session lists them, then set.
+// Empty bounds are open. Rows are streamed, so fn sees each set as it is read
+// and an error from fn stops the export.
+func (d *DBConn) ExportSetHistory(userID int, from string, to string, fn func(ExerciseSet) error) error {
+	query := `
+        SELECT s.sessionID, s.dateTime, e.exerciseID, e.name, w.groupNumber, ` + setColumns + `
+        FROM Sets st
+        JOIN Workouts w ON w.workoutID = st.workoutID
+        JOIN Session s ON s.sessionID = w.sessionID
+        JOIN Exercise e ON e.exerciseID = w.exerciseID
+        WHERE s.userID = ?
+          AND (? = '' OR s.dateTime >= ?)
+          AND (? = '' OR s.dateTime < ?)
+        ORDER BY s.dateTime, s.sessionID, w.position, w.workoutID DESC, st.setID
+    `
+	rows, err := d.db.Query(d.rebind(query), userID, from, from, to, to)
+	if err != nil {
+		return fmt.Errorf("ExportSetHistory: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var set ExerciseSet
+		if err := rows.Scan(append([]any{&set.SessionID, &set.DateTime, &set.ExerciseID, &set.ExerciseName, &set.Group}, setFields(&set.SetRow)...)...); err != nil {
+			return fmt.Errorf("ExportSetHistory: %w", err)
+		}
+		if err := fn(set); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ExportSetHistory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package database
+
+// Store is the persistence API the web handlers depend on. DBConn is backed by
+// SQLite or PostgreSQL; MemoryStore keeps everything in process memory and is
+// meant for tests and throwaway local runs.
+type Store interface {
+	CloseConn() error
+
+	CreateUser(user User) (int64, error)
+	GetUserByEmail(email string) (UserRow, error)
+	GetUserById(userID int) (UserRow, error)
+	UpdateUser(userID int, user User) error
+	UpdateUserProfile(userID int, user User) error
+	GetUserByIdentity(provider string, subject string) (UserRow, error)
+	CreateUserWithIdentity(user User, identity UserIdentity) (int64, error)
+	LinkIdentity(identity UserIdentity) error
+	UserHasIdentity(userID int) (bool, error)
+	ClaimAccount(identity UserIdentity) error
+	CreateUserWithPassword(user User, passwordHash string) (int64, error)
+	GetPasswordCredential(userID int) (PasswordCredential, error)
+	SetPassword(userID int, passwordHash string) error
+	RecordFailedLogin(userID int, maxAttempts int, lockUntil string) (bool, error)
+	ResetFailedLogins(userID int) error
+
+	CreateRefreshToken(token RefreshToken) (int64, error)
+	GetRefreshToken(tokenHash string) (RefreshTokenRow, error)
+	RotateRefreshToken(tokenHash string, next RefreshToken) (RefreshTokenRow, error)
+	RefreshTokenFamilyActive(familyID string) (bool, error)
+	RevokeRefreshTokenFamily(familyID string) error
+	RevokeUserRefreshTokens(userID int) error
+
+	CreateApiToken(token ApiToken, tokenHash string) (int64, error)
+	GetApiTokensByUserId(userID int) ([]ApiTokenRow, error)
+	GetApiTokenById(tokenID int) (ApiTokenRow, error)
+	GetApiTokenByHash(tokenHash string) (ApiTokenRow, error)
+	TouchApiToken(tokenID int) error
+	DeleteApiToken(tokenID int) error
+	ApiTokenBelongsToUser(tokenID int, userID int) (bool, error)
+
+	CreateSessionForUser(session Session) (int64, error)
+	GetSessionsByUserId(userId int, filter SessionFilter) ([]SessionRow, error)
+	CountSessions(userID int, filter SessionFilter) (int, error)
+	GetSessionVolumes(sessionIDs []int) (map[int]float64, error)
+	GetSessionById(sessionID int) (SessionRow, error)
+	UpdateSession(sessionID int, session Session) error
+	DeleteSession(sessionID int) error
+	SessionBelongsToUser(sessionID int, userID int) (bool, error)
+
+	CreateWorkoutForSession(sessionId int, exerciseID int, workoutname string, userId int) (int64, error)
+	GetWorkoutsBySessionId(sessionId int) ([]WorkoutRow, error)
+	GetWorkoutById(workoutID int) (WorkoutRow, error)
+	GetLastWorkoutID(exerciseID int, userID int) (int, error)
+	UpdateWorkout(workoutID int, exerciseID int, workoutname string) error
+	DeleteWorkout(workoutID int) error
+	WorkoutBelongsToUser(workoutID int, userID int) (bool, error)
+	ArrangeWorkouts(sessionID int, groups [][]int) error
+
+	CreateSetForWorkout(set Set) (int64, error)
+	GetSetsByWorkoutId(workoutID int) ([]SetRow, error)
+	GetSetsBySessionId(sessionID int) ([]SetRow, error)
+	GetSetById(setID int) (SetRow, error)
+	UpdateSet(setID int, set Set) error
+	DeleteSet(setID int) error
+	SetBelongsToUser(setID int, userID int) (bool, error)
+
+	GetSetHistoryForExercise(exerciseID int, userID int) ([]ExerciseSet, error)
+	GetSetHistoryForUser(userID int) ([]ExerciseSet, error)
+	ExportSetHistory(userID int, from string, to string, fn func(ExerciseSet) error) error
+	ImportSession(userID int, session ImportedSession) (ImportResult, error)
+
+	GetExercises(userID int) ([]ExerciseRow, error)
+	GetExerciseById(exerciseID int) (ExerciseRow, error)
+	FindExercise(name string, userID int) (ExerciseRow, error)
+	CreateExercise(exercise Exercise) (int64, error)
+
+	CreateRoutine(routine Routine) (int64, error)
+	GetRoutinesByUserId(userID int) ([]RoutineRow, error)
+	GetRoutineById(routineID int) (RoutineRow, error)
+	UpdateRoutine(routineID int, routine Routine) error
+	DeleteRoutine(routineID int) error
+	RoutineBelongsToUser(routineID int, userID int) (bool, error)
+	StartRoutine(routineID int, session Session) (int64, error)
+
+	ExportAccount(userID int) (Backup, error)
+	RestoreAccount(userID int, backup Backup) error
+}
+
+var (
+	_ Store = (*DBConn)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,912 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// stores opens an empty store of each implementation.
+var stores = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{"sqlite", func(t *testing.T) Store {
+		d, err := CreateDBConnection(filepath.Join(t.TempDir(), "store.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { d.CloseConn() })
+		return d
+	}},
+	{"memory", func(t *testing.T) Store {
+		return NewMemoryStore()
+	}},
+	{"postgres", openPostgres},
+}
+
+// postgresSchemas numbers the schemas openPostgres creates.
+var postgresSchemas atomic.Int64
+
+// openPostgres opens a store in a new schema of the PostgreSQL database
+// TEST_POSTGRES_DSN names, dropping the schema when the test ends. Without
+// TEST_POSTGRES_DSN the test is skipped.
+func openPostgres(t *testing.T) Store {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("conformance_%d_%d", os.Getpid(), postgresSchemas.Add(1))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("dropping schema %s: %v", schema, err)
+		}
+	})
+
+	// lib/pq passes parameters it does not know to the server as settings.
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	d, err := CreatePostgresConnection(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.CloseConn() })
+	return d
+}
+
+// storeChecks is the behaviour every Store must share. Each check gets a
+// fresh store.
+var storeChecks = []struct {
+	name string
+	run  func(t *testing.T, s Store)
+}{
+	{"ownership", checkOwnership},
+	{"missing rows", checkMissingRows},
+	{"delete session cascades", checkDeleteSessionCascades},
+	{"delete workout cascades", checkDeleteWorkoutCascades},
+	{"unique violations", checkUniqueViolations},
+	{"refresh token rotation", checkRefreshTokenRotation},
+	{"refresh token expiry", checkRefreshTokenExpiry},
+	{"failed login lockout", checkFailedLoginLockout},
+	{"claim account", checkClaimAccount},
+	{"edited name", checkEditedName},
+	{"import workout names", checkImportWorkoutNames},
+	{"updates", checkUpdates},
+	{"deletes", checkDeletes},
+	{"exercise lookup", checkExerciseLookup},
+	{"api token lookup", checkApiTokenLookup},
+	{"refresh token family", checkRefreshTokenFamily},
+	{"set history", checkSetHistory},
+	{"workout per exercise", checkWorkoutPerExercise},
+	{"start routine", checkStartRoutine},
+	{"export set history", checkExportSetHistory},
+	{"backup round trip", checkBackupRoundTrip},
+	{"session paging", checkSessionPaging},
+	{"sets by session", checkSetsBySession},
+}
+
+func TestStoreConformance(t *testing.T) {
+	for _, store := range stores {
+		for _, check := range storeChecks {
+			t.Run(store.name+"/"+check.name, func(t *testing.T) {
+				check.run(t, store.open(t))
+			})
+		}
+	}
+}
+
+// must returns v, panicking on err, for setup steps that cannot fail.
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// account is a user with one of everything they can own.
+type account struct {
+	userID, sessionID, workoutID, setID, routineID, tokenID int
+}
+
+func seedAccount(t *testing.T, s Store, email string) account {
+	t.Helper()
+	var a account
+	a.userID = int(must(s.CreateUser(User{Email: email, Name: email})))
+	bench := must(s.FindExercise("bench press", a.userID))
+	a.sessionID = int(must(s.CreateSessionForUser(Session{UserID: a.userID})))
+	a.workoutID = int(must(s.CreateWorkoutForSession(a.sessionID, bench.Id, bench.Name, a.userID)))
+	a.setID = int(must(s.CreateSetForWorkout(Set{WorkoutID: a.workoutID, Weight: 100, NumberOfReps: 5, Unit: UnitKg, Type: "working", Completed: true})))
+	routine := Routine{UserID: a.userID, Name: "push", Exercises: []RoutineExercise{{ExerciseID: bench.Id, TargetSets: 3}}}
+	a.routineID = int(must(s.CreateRoutine(routine)))
+	a.tokenID = int(must(s.CreateApiToken(ApiToken{UserID: a.userID, Name: "script", Scope: ApiTokenScopeRead}, "hash-"+email)))
+	return a
+}
+
+func checkOwnership(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	b := seedAccount(t, s, "b@example.com")
+	belongs := []struct {
+		name string
+		fn   func(id int, userID int) (bool, error)
+		id   int
+	}{
+		{"session", s.SessionBelongsToUser, a.sessionID},
+		{"workout", s.WorkoutBelongsToUser, a.workoutID},
+		{"set", s.SetBelongsToUser, a.setID},
+		{"routine", s.RoutineBelongsToUser, a.routineID},
+		{"api token", s.ApiTokenBelongsToUser, a.tokenID},
+	}
+	for _, c := range belongs {
+		if !must(c.fn(c.id, a.userID)) {
+			t.Errorf("%s %d does not belong to its owner", c.name, c.id)
+		}
+		if must(c.fn(c.id, b.userID)) {
+			t.Errorf("%s %d belongs to another user", c.name, c.id)
+		}
+		if must(c.fn(999, a.userID)) {
+			t.Errorf("missing %s belongs to a user", c.name)
+		}
+	}
+	sessions := must(s.GetSessionsByUserId(b.userID, SessionFilter{}))
+	if len(sessions) != 1 || sessions[0].Id != b.sessionID {
+		t.Errorf("user b lists sessions %+v, want only %d", sessions, b.sessionID)
+	}
+	routines := must(s.GetRoutinesByUserId(b.userID))
+	if len(routines) != 1 || routines[0].Id != b.routineID {
+		t.Errorf("user b lists routines %+v, want only %d", routines, b.routineID)
+	}
+	tokens := must(s.GetApiTokensByUserId(b.userID))
+	if len(tokens) != 1 || tokens[0].Id != b.tokenID {
+		t.Errorf("user b lists tokens %+v, want only %d", tokens, b.tokenID)
+	}
+}
+
+func checkMissingRows(t *testing.T, s Store) {
+	gets := map[string]func() error{
+		"user":      func() error { _, err := s.GetUserById(999); return err },
+		"email":     func() error { _, err := s.GetUserByEmail("nobody@example.com"); return err },
+		"session":   func() error { _, err := s.GetSessionById(999); return err },
+		"workout":   func() error { _, err := s.GetWorkoutById(999); return err },
+		"set":       func() error { _, err := s.GetSetById(999); return err },
+		"routine":   func() error { _, err := s.GetRoutineById(999); return err },
+		"api token": func() error { _, err := s.GetApiTokenById(999); return err },
+		"password":  func() error { _, err := s.GetPasswordCredential(999); return err },
+		"identity":  func() error { _, err := s.GetUserByIdentity("google", "nobody"); return err },
+	}
+	for name, get := range gets {
+		if err := get(); !errors.Is(err, ErrNotFound) {
+			t.Errorf("missing %s: got %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func checkDeleteSessionCascades(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	if err := s.DeleteSession(a.sessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetWorkoutById(a.workoutID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("workout of deleted session: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetSetById(a.setID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("set of deleted session: got %v, want ErrNotFound", err)
+	}
+	if history := must(s.GetSetHistoryForUser(a.userID)); len(history) != 0 {
+		t.Errorf("history after deleting the only session: %+v", history)
+	}
+}
+
+func checkDeleteWorkoutCascades(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	if err := s.DeleteWorkout(a.workoutID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSetById(a.setID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("set of deleted workout: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetSessionById(a.sessionID); err != nil {
+		t.Errorf("session of deleted workout: %v", err)
+	}
+}
+
+func checkUniqueViolations(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	bench := must(s.FindExercise("bench press", a.userID))
+	must(s.CreateUserWithIdentity(User{Email: "c@example.com"}, UserIdentity{Provider: "google", Subject: "c"}))
+	must(s.CreateExercise(Exercise{UserID: &a.userID, Name: "my lift"}))
+	violations := []struct {
+		name string
+		want string
+		err  error
+	}{
+		{"workout exercise", "Workout already exists", second(s.CreateWorkoutForSession(a.sessionID, bench.Id, "bench", a.userID))},
+		{"routine name", "Routine already exists", second(s.CreateRoutine(Routine{UserID: a.userID, Name: "push"}))},
+		{"exercise name", "Exercise already exists", second(s.CreateExercise(Exercise{UserID: &a.userID, Name: "my lift"}))},
+		{"api token name", "Token already exists", second(s.CreateApiToken(ApiToken{UserID: a.userID, Name: "script", Scope: ApiTokenScopeRead}, "other-hash"))},
+		{"password user email", "User already exists", second(s.CreateUserWithPassword(User{Email: "a@example.com"}, "hash"))},
+		{"identity", "Identity already exists", s.LinkIdentity(UserIdentity{UserID: a.userID, Provider: "google", Subject: "c"})},
+	}
+	for _, v := range violations {
+		if v.err == nil || !strings.Contains(v.err.Error(), v.want) {
+			t.Errorf("duplicate %s: got %v, want %q", v.name, v.err, v.want)
+		}
+	}
+	if _, err := s.CreateUser(User{Email: "a@example.com"}); err == nil {
+		t.Error("duplicate user email: got no error")
+	}
+}
+
+// second returns the error of a two-value call.
+func second[T any](_ T, err error) error {
+	return err
+}
+
+func checkRefreshTokenRotation(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	must(s.CreateRefreshToken(RefreshToken{UserID: a.userID, FamilyID: "login", TokenHash: "first", ExpiresAt: "2999-01-01 00:00:00"}))
+	next := must(s.RotateRefreshToken("first", RefreshToken{TokenHash: "second", ExpiresAt: "2999-01-01 00:00:00"}))
+	if next.UserID != a.userID || next.FamilyID != "login" {
+		t.Errorf("rotated token = %+v, want user %d in family login", next, a.userID)
+	}
+	if !must(s.RefreshTokenFamilyActive("login")) {
+		t.Error("family inactive after a rotation")
+	}
+	// Presenting the used token again signs out the whole login.
+	if _, err := s.RotateRefreshToken("first", RefreshToken{TokenHash: "third", ExpiresAt: "2999-01-01 00:00:00"}); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("reusing a token: got %v, want ErrTokenReused", err)
+	}
+	if must(s.RefreshTokenFamilyActive("login")) {
+		t.Error("family still active after reuse")
+	}
+	if _, err := s.RotateRefreshToken("second", RefreshToken{TokenHash: "fourth", ExpiresAt: "2999-01-01 00:00:00"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rotating a revoked token: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.RotateRefreshToken("unknown", RefreshToken{TokenHash: "fifth", ExpiresAt: "2999-01-01 00:00:00"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rotating an unknown token: got %v, want ErrNotFound", err)
+	}
+}
+
+func checkRefreshTokenExpiry(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	must(s.CreateRefreshToken(RefreshToken{UserID: a.userID, FamilyID: "old", TokenHash: "expired", ExpiresAt: "2000-01-01 00:00:00"}))
+	if _, err := s.RotateRefreshToken("expired", RefreshToken{TokenHash: "next", ExpiresAt: "2999-01-01 00:00:00"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rotating an expired token: got %v, want ErrNotFound", err)
+	}
+	must(s.CreateRefreshToken(RefreshToken{UserID: a.userID, FamilyID: "other", TokenHash: "live", ExpiresAt: "2999-01-01 00:00:00"}))
+	if err := s.RevokeUserRefreshTokens(a.userID); err != nil {
+		t.Fatal(err)
+	}
+	if must(s.RefreshTokenFamilyActive("other")) {
+		t.Error("family active after signing out every device")
+	}
+}
+
+func checkFailedLoginLockout(t *testing.T, s Store) {
+	userID := int(must(s.CreateUserWithPassword(User{Email: "a@example.com"}, "hash")))
+	for i := 1; i <= 2; i++ {
+		if must(s.RecordFailedLogin(userID, 3, "2999-01-01 00:00:00")) {
+			t.Fatalf("locked after %d failures, want 3", i)
+		}
+	}
+	if c := must(s.GetPasswordCredential(userID)); c.FailedAttempts != 2 || c.LockedUntil != "" {
+		t.Errorf("after 2 failures: %+v", c)
+	}
+	if !must(s.RecordFailedLogin(userID, 3, "2999-01-01 00:00:00")) {
+		t.Fatal("not locked after 3 failures")
+	}
+	if c := must(s.GetPasswordCredential(userID)); c.FailedAttempts != 0 || c.LockedUntil != "2999-01-01 00:00:00" {
+		t.Errorf("after lockout: %+v, want the count restarted and locked", c)
+	}
+	if err := s.ResetFailedLogins(userID); err != nil {
+		t.Fatal(err)
+	}
+	if c := must(s.GetPasswordCredential(userID)); c.FailedAttempts != 0 || c.LockedUntil != "" {
+		t.Errorf("after reset: %+v", c)
+	}
+	must(s.RecordFailedLogin(userID, 3, "2999-01-01 00:00:00"))
+	if err := s.SetPassword(userID, "new-hash"); err != nil {
+		t.Fatal(err)
+	}
+	if c := must(s.GetPasswordCredential(userID)); c.PasswordHash != "new-hash" || c.FailedAttempts != 0 {
+		t.Errorf("after a new password: %+v", c)
+	}
+	if _, err := s.RecordFailedLogin(999, 3, "2999-01-01 00:00:00"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("failed login without a password: got %v, want ErrNotFound", err)
+	}
+}
+
+func checkClaimAccount(t *testing.T, s Store) {
+	userID := int(must(s.CreateUserWithPassword(User{Email: "a@example.com"}, "hash")))
+	must(s.CreateRefreshToken(RefreshToken{UserID: userID, FamilyID: "login", TokenHash: "refresh", ExpiresAt: "2999-01-01 00:00:00"}))
+	must(s.CreateApiToken(ApiToken{UserID: userID, Name: "script", Scope: ApiTokenScopeRead}, "api"))
+	other := seedAccount(t, s, "b@example.com")
+	if must(s.UserHasIdentity(userID)) {
+		t.Fatal("password user has an identity")
+	}
+
+	if err := s.ClaimAccount(UserIdentity{UserID: userID, Provider: "google", Subject: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if !must(s.UserHasIdentity(userID)) {
+		t.Error("no identity after claiming")
+	}
+	if user := must(s.GetUserByIdentity("google", "a")); user.Id != userID {
+		t.Errorf("identity signs in user %d, want %d", user.Id, userID)
+	}
+	if _, err := s.GetPasswordCredential(userID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("password after claiming: got %v, want ErrNotFound", err)
+	}
+	if must(s.RefreshTokenFamilyActive("login")) {
+		t.Error("login still active after claiming")
+	}
+	if tokens := must(s.GetApiTokensByUserId(userID)); len(tokens) != 0 {
+		t.Errorf("API tokens after claiming: %+v", tokens)
+	}
+	if tokens := must(s.GetApiTokensByUserId(other.userID)); len(tokens) != 1 {
+		t.Errorf("another user's API tokens after claiming: %+v", tokens)
+	}
+	if err := s.ClaimAccount(UserIdentity{UserID: other.userID, Provider: "google", Subject: "a"}); err == nil || !strings.Contains(err.Error(), "Identity already exists") {
+		t.Errorf("claiming with a linked identity: got %v, want Identity already exists", err)
+	}
+	if tokens := must(s.GetApiTokensByUserId(other.userID)); len(tokens) != 1 {
+		t.Errorf("a failed claim removed API tokens: %+v", tokens)
+	}
+}
+
+func checkEditedName(t *testing.T, s Store) {
+	userID := int(must(s.CreateUser(User{Email: "a@example.com", Name: "Alice"})))
+	if err := s.UpdateUserProfile(userID, User{Name: "Alice Smith", Picture: "a.png"}); err != nil {
+		t.Fatal(err)
+	}
+	user := must(s.GetUserById(userID))
+	if user.Name != "Alice Smith" || user.NameEdited {
+		t.Fatalf("after a sign-in: %+v", user)
+	}
+	user.Name = "Al"
+	user.NameEdited = true
+	if err := s.UpdateUser(userID, user.User); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateUserProfile(userID, User{Name: "Alice Jones", Picture: "b.png"}); err != nil {
+		t.Fatal(err)
+	}
+	if user := must(s.GetUserById(userID)); user.Name != "Al" || !user.NameEdited || user.Picture != "b.png" {
+		t.Errorf("after renaming and signing in: %+v, want name Al kept and picture b.png", user)
+	}
+}
+
+func checkImportWorkoutNames(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	builtin := must(s.FindExercise("squat", a.userID))
+	// A custom exercise may share a built-in exercise's name.
+	custom := int(must(s.CreateExercise(Exercise{UserID: &a.userID, Name: builtin.Name})))
+	// Workouts are matched on their exercise: the custom squat gets its own
+	// workout, and squat logged under another name joins the built-in one.
+	session := ImportedSession{ImportKey: "strong|1", DateTime: "2024-01-01 10:00:00", TimeZone: "UTC", Workouts: []ImportedWorkout{
+		{ExerciseID: builtin.Id, WorkoutName: builtin.Name, Sets: []ImportedSet{{ImportKey: "a|1", Set: Set{Weight: 100, NumberOfReps: 5, Unit: UnitKg}}}},
+		{ExerciseID: custom, WorkoutName: builtin.Name, Sets: []ImportedSet{{ImportKey: "b|1", Set: Set{Weight: 60, NumberOfReps: 5, Unit: UnitKg}}}},
+		{ExerciseID: builtin.Id, WorkoutName: "back squat", Sets: []ImportedSet{{ImportKey: "c|1", Set: Set{Weight: 110, NumberOfReps: 3, Unit: UnitKg}}}},
+	}}
+	result, err := s.ImportSession(a.userID, session)
+	if err != nil {
+		t.Fatalf("importing two exercises with one name: %v", err)
+	}
+	if result.SetsCreated != 3 {
+		t.Errorf("created %d sets, want 3", result.SetsCreated)
+	}
+	sets := map[int]int{}
+	for _, w := range must(s.GetWorkoutsBySessionId(result.SessionID)) {
+		sets[w.ExerciseID] = len(must(s.GetSetsByWorkoutId(w.Id)))
+	}
+	if len(sets) != 2 || sets[builtin.Id] != 2 || sets[custom] != 1 {
+		t.Errorf("import made workouts with sets by exercise %v, want 2 for %d and 1 for %d", sets, builtin.Id, custom)
+	}
+	again, err := s.ImportSession(a.userID, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.SetsCreated != 0 || again.SetsSkipped != 3 {
+		t.Errorf("re-import created %d and skipped %d sets, want 0 and 3", again.SetsCreated, again.SetsSkipped)
+	}
+}
+
+func checkUpdates(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	squat := must(s.FindExercise("squat", a.userID))
+
+	if err := s.UpdateSession(a.sessionID, Session{DateTime: "2024-01-02 10:00:00", TimeZone: "Europe/Berlin", Title: "legs", Notes: "heavy"}); err != nil {
+		t.Fatal(err)
+	}
+	session := must(s.GetSessionById(a.sessionID))
+	if session.UserID != a.userID || session.DateTime != "2024-01-02 10:00:00" || session.TimeZone != "Europe/Berlin" || session.Title != "legs" || session.Notes != "heavy" {
+		t.Errorf("updated session = %+v", session)
+	}
+
+	if err := s.UpdateWorkout(a.workoutID, squat.Id, squat.Name); err != nil {
+		t.Fatal(err)
+	}
+	if workout := must(s.GetWorkoutById(a.workoutID)); workout.ExerciseID != squat.Id || workout.WorkoutName != squat.Name || workout.SessionID != a.sessionID {
+		t.Errorf("updated workout = %+v, want %s in session %d", workout, squat.Name, a.sessionID)
+	}
+
+	rpe := 8.5
+	if err := s.UpdateSet(a.setID, Set{Weight: 110, NumberOfReps: 3, Unit: UnitLb, RPE: &rpe, Type: SetTypeAMRAP, Completed: true}); err != nil {
+		t.Fatal(err)
+	}
+	set := must(s.GetSetById(a.setID))
+	if set.WorkoutID != a.workoutID || set.Weight != 110 || set.NumberOfReps != 3 || set.Unit != UnitLb || set.RPE == nil || *set.RPE != rpe || set.Type != SetTypeAMRAP {
+		t.Errorf("updated set = %+v", set)
+	}
+
+	bench := must(s.FindExercise("bench press", a.userID))
+	routine := Routine{UserID: a.userID, Name: "legs", Exercises: []RoutineExercise{{ExerciseID: squat.Id, TargetSets: 5, TargetReps: 5}, {ExerciseID: bench.Id}}}
+	if err := s.UpdateRoutine(a.routineID, routine); err != nil {
+		t.Fatal(err)
+	}
+	got := must(s.GetRoutineById(a.routineID))
+	if got.Name != "legs" || len(got.Exercises) != 2 || got.Exercises[0].ExerciseName != squat.Name || got.Exercises[0].TargetSets != 5 || got.Exercises[1].ExerciseID != bench.Id {
+		t.Errorf("updated routine = %+v", got)
+	}
+	must(s.CreateRoutine(Routine{UserID: a.userID, Name: "push"}))
+	if err := s.UpdateRoutine(a.routineID, Routine{UserID: a.userID, Name: "push"}); err == nil || !strings.Contains(err.Error(), "Routine already exists") {
+		t.Errorf("renaming a routine to a taken name: got %v, want Routine already exists", err)
+	}
+	if got := must(s.GetRoutineById(a.routineID)); got.Name != "legs" || len(got.Exercises) != 2 {
+		t.Errorf("a failed rename changed the routine: %+v", got)
+	}
+}
+
+func checkDeletes(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	if err := s.DeleteSet(a.setID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSetById(a.setID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted set: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetWorkoutById(a.workoutID); err != nil {
+		t.Errorf("workout of deleted set: %v", err)
+	}
+	if err := s.DeleteRoutine(a.routineID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetRoutineById(a.routineID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted routine: got %v, want ErrNotFound", err)
+	}
+	// Deleting what is already gone is not an error.
+	if err := s.DeleteSet(a.setID); err != nil {
+		t.Errorf("deleting a missing set: %v", err)
+	}
+	if err := s.DeleteRoutine(a.routineID); err != nil {
+		t.Errorf("deleting a missing routine: %v", err)
+	}
+}
+
+func checkExerciseLookup(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	b := seedAccount(t, s, "b@example.com")
+	bench := must(s.FindExercise("  Bench   PRESS ", a.userID))
+	if bench.Name != "bench press" || bench.UserID != nil {
+		t.Errorf("FindExercise with odd spacing and case = %+v, want built-in bench press", bench)
+	}
+	if got := must(s.GetExerciseById(bench.Id)); got.Name != bench.Name {
+		t.Errorf("GetExerciseById(%d) = %+v, want %s", bench.Id, got, bench.Name)
+	}
+	if _, err := s.GetExerciseById(999999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing exercise: got %v, want ErrNotFound", err)
+	}
+	for _, e := range must(s.GetExercises(a.userID)) {
+		if len(e.Aliases) > 0 {
+			if got := must(s.FindExercise(e.Aliases[0], a.userID)); got.Id != e.Id {
+				t.Errorf("FindExercise(%q) = %s, want %s", e.Aliases[0], got.Name, e.Name)
+			}
+			break
+		}
+	}
+
+	custom := int(must(s.CreateExercise(Exercise{UserID: &a.userID, Name: "Zercher Carry"})))
+	if got := must(s.FindExercise("zercher carry", a.userID)); got.Id != custom || got.UserID == nil || *got.UserID != a.userID {
+		t.Errorf("FindExercise for the owner = %+v, want custom exercise %d", got, custom)
+	}
+	if _, err := s.FindExercise("zercher carry", b.userID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("another user's custom exercise: got %v, want ErrNotFound", err)
+	}
+	// Another user may reuse the name.
+	if _, err := s.CreateExercise(Exercise{UserID: &b.userID, Name: "zercher carry"}); err != nil {
+		t.Errorf("another user creating the same name: %v", err)
+	}
+}
+
+func checkApiTokenLookup(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	token := must(s.GetApiTokenByHash("hash-a@example.com"))
+	if token.Id != a.tokenID || token.UserID != a.userID || token.Scope != ApiTokenScopeRead || token.LastUsedAt != "" {
+		t.Errorf("GetApiTokenByHash = %+v, want unused token %d", token, a.tokenID)
+	}
+	if _, err := s.GetApiTokenByHash("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown hash: got %v, want ErrNotFound", err)
+	}
+	if err := s.TouchApiToken(a.tokenID); err != nil {
+		t.Fatal(err)
+	}
+	used := must(s.GetApiTokenById(a.tokenID)).LastUsedAt
+	if used == "" {
+		t.Error("LastUsedAt empty after a use")
+	}
+	if err := s.TouchApiToken(999); err != nil {
+		t.Errorf("touching a missing token: %v", err)
+	}
+	if err := s.DeleteApiToken(a.tokenID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetApiTokenByHash("hash-a@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted token by hash: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetApiTokenById(a.tokenID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted token: got %v, want ErrNotFound", err)
+	}
+}
+
+func checkRefreshTokenFamily(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	must(s.CreateRefreshToken(RefreshToken{UserID: a.userID, FamilyID: "phone", TokenHash: "phone-1", ExpiresAt: "2999-01-01 00:00:00"}))
+	must(s.CreateRefreshToken(RefreshToken{UserID: a.userID, FamilyID: "laptop", TokenHash: "laptop-1", ExpiresAt: "2999-01-01 00:00:00"}))
+	token := must(s.GetRefreshToken("phone-1"))
+	if token.UserID != a.userID || token.FamilyID != "phone" || token.ExpiresAt != "2999-01-01 00:00:00" || token.UsedAt != "" || token.RevokedAt != "" {
+		t.Errorf("GetRefreshToken = %+v", token)
+	}
+	if _, err := s.GetRefreshToken("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown refresh token: got %v, want ErrNotFound", err)
+	}
+	if err := s.RevokeRefreshTokenFamily("phone"); err != nil {
+		t.Fatal(err)
+	}
+	if token := must(s.GetRefreshToken("phone-1")); token.RevokedAt == "" {
+		t.Error("token not revoked with its family")
+	}
+	if must(s.RefreshTokenFamilyActive("phone")) {
+		t.Error("revoked family still active")
+	}
+	if !must(s.RefreshTokenFamilyActive("laptop")) {
+		t.Error("revoking one family signed out another")
+	}
+}
+
+func checkSetHistory(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	b := seedAccount(t, s, "b@example.com")
+	bench := must(s.FindExercise("bench press", a.userID))
+	must(s.CreateSetForWorkout(Set{WorkoutID: a.workoutID, Weight: 20, NumberOfReps: 10, Unit: UnitKg, Type: SetTypeWarmUp, Completed: true}))
+	must(s.CreateSetForWorkout(Set{WorkoutID: a.workoutID, Weight: 120, NumberOfReps: 1, Unit: UnitKg, Type: SetTypeWorking}))
+
+	// The workout being logged is skipped, leaving the one before it.
+	if id := must(s.GetLastWorkoutID(bench.Id, a.userID)); id != 0 {
+		t.Errorf("GetLastWorkoutID with one workout = %d, want 0", id)
+	}
+	later := int(must(s.CreateSessionForUser(Session{UserID: a.userID, DateTime: "2999-01-01 10:00:00"})))
+	must(s.CreateWorkoutForSession(later, bench.Id, bench.Name, a.userID))
+	if id := must(s.GetLastWorkoutID(bench.Id, a.userID)); id != a.workoutID {
+		t.Errorf("GetLastWorkoutID = %d, want %d", id, a.workoutID)
+	}
+
+	history := must(s.GetSetHistoryForExercise(bench.Id, a.userID))
+	if len(history) != 3 {
+		t.Fatalf("history has %d sets, want 3: %+v", len(history), history)
+	}
+	for _, h := range history {
+		if h.SessionID != a.sessionID || h.ExerciseID != bench.Id || h.ExerciseName != bench.Name || h.WorkoutID != a.workoutID {
+			t.Errorf("history entry %+v, want bench press in session %d", h, a.sessionID)
+		}
+	}
+	if history := must(s.GetSetHistoryForExercise(must(s.FindExercise("squat", a.userID)).Id, a.userID)); len(history) != 0 {
+		t.Errorf("squat history = %+v, want none", history)
+	}
+
+	// Warm-up and uncompleted sets do not count towards volume.
+	volumes := must(s.GetSessionVolumes([]int{a.sessionID, later, b.sessionID}))
+	want := map[int]float64{a.sessionID: 500, b.sessionID: 500}
+	if len(volumes) != len(want) || volumes[a.sessionID] != want[a.sessionID] || volumes[b.sessionID] != want[b.sessionID] {
+		t.Errorf("GetSessionVolumes = %v, want %v", volumes, want)
+	}
+	if volumes := must(s.GetSessionVolumes(nil)); len(volumes) != 0 {
+		t.Errorf("GetSessionVolumes(nil) = %v, want none", volumes)
+	}
+}
+
+// A session holds one workout per exercise, whatever the workouts are named.
+func checkWorkoutPerExercise(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	bench := must(s.FindExercise("bench press", a.userID))
+	squat := must(s.FindExercise("squat", a.userID))
+	// A custom exercise may share a built-in exercise's name.
+	custom := int(must(s.CreateExercise(Exercise{UserID: &a.userID, Name: squat.Name})))
+	squatID := int(must(s.CreateWorkoutForSession(a.sessionID, squat.Id, squat.Name, a.userID)))
+	if _, err := s.CreateWorkoutForSession(a.sessionID, custom, squat.Name, a.userID); err != nil {
+		t.Errorf("adding a second exercise named %s: %v", squat.Name, err)
+	}
+	if err := s.UpdateWorkout(squatID, bench.Id, "squat"); err == nil || !strings.Contains(err.Error(), "Workout already exists") {
+		t.Errorf("changing a workout to an exercise already in the session: got %v, want Workout already exists", err)
+	}
+	if workout := must(s.GetWorkoutById(squatID)); workout.ExerciseID != squat.Id {
+		t.Errorf("a failed change left workout %+v", workout)
+	}
+
+	routine := int(must(s.CreateRoutine(Routine{UserID: a.userID, Name: "legs", Exercises: []RoutineExercise{{ExerciseID: squat.Id}, {ExerciseID: custom}}})))
+	sessionID := int(must(s.StartRoutine(routine, Session{UserID: a.userID})))
+	workouts := must(s.GetWorkoutsBySessionId(sessionID))
+	if len(workouts) != 2 || workouts[0].ExerciseID != squat.Id || workouts[1].ExerciseID != custom {
+		t.Errorf("started routine with workouts %+v, want the built-in then the custom squat", workouts)
+	}
+}
+
+func checkStartRoutine(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	bench := must(s.FindExercise("bench press", a.userID))
+	squat := must(s.FindExercise("squat", a.userID))
+	routine := int(must(s.CreateRoutine(Routine{UserID: a.userID, Name: "legs", Exercises: []RoutineExercise{{ExerciseID: squat.Id}, {ExerciseID: bench.Id}}})))
+	sessionID := int(must(s.StartRoutine(routine, Session{UserID: a.userID, Title: "legs", TimeZone: "Europe/Berlin"})))
+	if session := must(s.GetSessionById(sessionID)); session.UserID != a.userID || session.Title != "legs" || session.TimeZone != "Europe/Berlin" {
+		t.Errorf("started session = %+v", session)
+	}
+	workouts := must(s.GetWorkoutsBySessionId(sessionID))
+	if len(workouts) != 2 || workouts[0].ExerciseID != squat.Id || workouts[0].WorkoutName != squat.Name || workouts[1].ExerciseID != bench.Id {
+		t.Errorf("started workouts %+v, want squat then bench press", workouts)
+	}
+	for _, w := range workouts {
+		if w.UserID != a.userID {
+			t.Errorf("started workout %+v belongs to user %d, want %d", w, w.UserID, a.userID)
+		}
+	}
+
+	// A routine listing an exercise twice, which the handlers reject on
+	// write, fails on its last workout and leaves nothing behind.
+	sessions := must(s.CountSessions(a.userID, SessionFilter{}))
+	twice := int(must(s.CreateRoutine(Routine{UserID: a.userID, Name: "twice", Exercises: []RoutineExercise{{ExerciseID: squat.Id}, {ExerciseID: bench.Id}, {ExerciseID: squat.Id}}})))
+	if _, err := s.StartRoutine(twice, Session{UserID: a.userID}); err == nil || !strings.Contains(err.Error(), "Workout already exists") {
+		t.Fatalf("starting a routine with a repeated exercise: got %v, want Workout already exists", err)
+	}
+	if after := must(s.CountSessions(a.userID, SessionFilter{})); after != sessions {
+		t.Errorf("%d sessions after a failed start, want %d", after, sessions)
+	}
+	// A leftover bench press workout would now be the latest but one.
+	if id := must(s.GetLastWorkoutID(bench.Id, a.userID)); id != a.workoutID {
+		t.Errorf("last bench workout after a failed start = %d, want %d", id, a.workoutID)
+	}
+}
+
+func checkExportSetHistory(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	seedAccount(t, s, "b@example.com")
+	squat := must(s.FindExercise("squat", a.userID))
+	bench := must(s.FindExercise("bench press", a.userID))
+	if err := s.UpdateSession(a.sessionID, Session{DateTime: "2024-03-02 10:00:00"}); err != nil {
+		t.Fatal(err)
+	}
+	// Added after bench press, so listed before it.
+	squatID := int(must(s.CreateWorkoutForSession(a.sessionID, squat.Id, squat.Name, a.userID)))
+	squat1 := int(must(s.CreateSetForWorkout(Set{WorkoutID: squatID, Weight: 140, NumberOfReps: 5, Unit: UnitKg})))
+	squat2 := int(must(s.CreateSetForWorkout(Set{WorkoutID: squatID, Weight: 150, NumberOfReps: 3, Unit: UnitKg})))
+	earlier := int(must(s.CreateSessionForUser(Session{UserID: a.userID, DateTime: "2024-03-01 10:00:00"})))
+	earlierSet := int(must(s.CreateSetForWorkout(Set{WorkoutID: int(must(s.CreateWorkoutForSession(earlier, bench.Id, bench.Name, a.userID))), Weight: 90, NumberOfReps: 5, Unit: UnitKg})))
+
+	export := func(from string, to string) []int {
+		t.Helper()
+		var ids []int
+		err := s.ExportSetHistory(a.userID, from, to, func(set ExerciseSet) error {
+			ids = append(ids, set.Id)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+	tests := []struct {
+		from, to string
+		want     []int
+	}{
+		{"", "", []int{earlierSet, squat1, squat2, a.setID}},
+		{"2024-03-02 10:00:00", "", []int{squat1, squat2, a.setID}},
+		{"", "2024-03-02 10:00:00", []int{earlierSet}},
+		{"2024-03-01 00:00:00", "2024-03-01 23:59:59", []int{earlierSet}},
+		{"2024-03-03 00:00:00", "", nil},
+	}
+	for _, tt := range tests {
+		if got := export(tt.from, tt.to); !slices.Equal(got, tt.want) {
+			t.Errorf("export from %q to %q = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	stop := errors.New("stop")
+	var seen int
+	err := s.ExportSetHistory(a.userID, "", "", func(set ExerciseSet) error {
+		seen++
+		return stop
+	})
+	if !errors.Is(err, stop) || seen != 1 {
+		t.Errorf("export stopped by its callback: got %v after %d sets, want stop after 1", err, seen)
+	}
+}
+
+// Restoring a backup into another account and exporting that gives the same
+// backup, apart from IDs and the account itself.
+func checkBackupRoundTrip(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	bodyweight, rpe, rir := 81.5, 8.5, 2
+	if err := s.UpdateUser(a.userID, User{Name: "Alice", NameEdited: true, Unit: UnitLb, TimeZone: "Europe/Berlin", Bodyweight: &bodyweight}); err != nil {
+		t.Fatal(err)
+	}
+	session := Session{DateTime: "2024-03-02 10:00:00", EndDateTime: "2024-03-02 11:00:00", TimeZone: "Europe/Berlin", Title: "push", Notes: "felt strong", Bodyweight: &bodyweight}
+	if err := s.UpdateSession(a.sessionID, session); err != nil {
+		t.Fatal(err)
+	}
+	custom := Exercise{UserID: &a.userID, Name: "my lift", Aliases: []string{"mine"}, PrimaryMuscles: []string{"chest"}, Equipment: "cable", Type: "strength"}
+	customID := int(must(s.CreateExercise(custom)))
+	customWorkout := int(must(s.CreateWorkoutForSession(a.sessionID, customID, "my lift", a.userID)))
+	must(s.CreateSetForWorkout(Set{WorkoutID: customWorkout, Weight: 20, NumberOfReps: 12, Unit: UnitLb, RPE: &rpe, RIR: &rir, Type: "drop", Tempo: "3010", Duration: 45}))
+	if err := s.ArrangeWorkouts(a.sessionID, [][]int{{a.workoutID, customWorkout}}); err != nil {
+		t.Fatal(err)
+	}
+	must(s.CreateRoutine(Routine{UserID: a.userID, Name: "arms", Exercises: []RoutineExercise{{ExerciseID: customID, TargetSets: 3, TargetReps: 12, TargetWeight: 20}}}))
+	must(s.ImportSession(a.userID, ImportedSession{ImportKey: "strong-1", DateTime: "2024-03-01 10:00:00", Workouts: []ImportedWorkout{{
+		ExerciseID: customID, WorkoutName: "my lift", Sets: []ImportedSet{{ImportKey: "1", Set: Set{Weight: 15, NumberOfReps: 10, Unit: UnitKg, Type: "working", Completed: true}}},
+	}}}))
+
+	backup := must(s.ExportAccount(a.userID))
+	b := int(must(s.CreateUser(User{Email: "b@example.com", Name: "b"})))
+	if err := s.RestoreAccount(b, backup); err != nil {
+		t.Fatal(err)
+	}
+	if user := must(s.GetUserById(b)); user.Email != "b@example.com" {
+		t.Errorf("restored user has email %s, want it left as b@example.com", user.Email)
+	}
+	restored := must(s.ExportAccount(b))
+	if want, got := normalizeBackup(backup), normalizeBackup(restored); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored account exports as\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// normalizeBackup clears what a restore is expected to change: IDs, the owner
+// and the export time.
+func normalizeBackup(backup Backup) Backup {
+	backup.ExportedAt = ""
+	backup.User.Email = ""
+	for i := range backup.Exercises {
+		backup.Exercises[i].Id = 0
+		backup.Exercises[i].UserID = nil
+	}
+	for i := range backup.Routines {
+		routine := &backup.Routines[i]
+		routine.Id, routine.UserID = 0, 0
+		for j := range routine.Exercises {
+			routine.Exercises[j].ExerciseID = 0
+		}
+	}
+	for i := range backup.Sessions {
+		session := &backup.Sessions[i]
+		session.Id = 0
+		for j := range session.Workouts {
+			workout := &session.Workouts[j]
+			workout.Id, workout.ExerciseID = 0, 0
+			for k := range workout.Sets {
+				workout.Sets[k].Id, workout.Sets[k].WorkoutID = 0, 0
+			}
+		}
+	}
+	return backup
+}
+
+// Sessions list newest first, ties by ID, and a cursor continues after the
+// session it names whatever the filter.
+func checkSessionPaging(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	b := seedAccount(t, s, "b@example.com")
+	squat := must(s.FindExercise("squat", a.userID))
+	must(s.CreateWorkoutForSession(b.sessionID, squat.Id, squat.Name, b.userID))
+	if err := s.UpdateSession(a.sessionID, Session{DateTime: "2024-03-01 10:00:00"}); err != nil {
+		t.Fatal(err)
+	}
+	sessions := []int{a.sessionID}
+	for _, dateTime := range []string{"2024-03-02 10:00:00", "2024-03-02 10:00:00", "2024-03-02 10:00:00", "2024-03-03 10:00:00"} {
+		sessions = append(sessions, int(must(s.CreateSessionForUser(Session{UserID: a.userID, DateTime: dateTime}))))
+	}
+	for _, i := range []int{0, 2, 4} {
+		must(s.CreateWorkoutForSession(sessions[i], squat.Id, squat.Name, a.userID))
+	}
+
+	list := func(filter SessionFilter) [][]int {
+		t.Helper()
+		var pages [][]int
+		for {
+			rows := must(s.GetSessionsByUserId(a.userID, filter))
+			var page []int
+			for _, row := range rows {
+				page = append(page, row.Id)
+			}
+			pages = append(pages, page)
+			if len(rows) < filter.Limit || len(pages) > len(sessions) {
+				return pages
+			}
+			last := rows[len(rows)-1]
+			filter.After = &SessionCursor{DateTime: last.DateTime, Id: last.Id}
+		}
+	}
+	tests := []struct {
+		filter SessionFilter
+		want   [][]int
+		count  int
+	}{
+		{SessionFilter{Limit: 2}, [][]int{{sessions[4], sessions[3]}, {sessions[2], sessions[1]}, {sessions[0]}}, 5},
+		{SessionFilter{Limit: 3}, [][]int{{sessions[4], sessions[3], sessions[2]}, {sessions[1], sessions[0]}}, 5},
+		{SessionFilter{Limit: 1, ExerciseID: squat.Id}, [][]int{{sessions[4]}, {sessions[2]}, {sessions[0]}, nil}, 3},
+		{SessionFilter{Limit: 2, ExerciseID: squat.Id, From: "2024-03-02 00:00:00"}, [][]int{{sessions[4], sessions[2]}, nil}, 2},
+		{SessionFilter{Limit: 2, ExerciseID: -1}, [][]int{nil}, 0},
+	}
+	for _, tt := range tests {
+		if got := list(tt.filter); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("pages of %+v = %v, want %v", tt.filter, got, tt.want)
+		}
+		// The cursor does not change the count.
+		tt.filter.After = &SessionCursor{DateTime: "2024-03-02 10:00:00", Id: sessions[2]}
+		if count := must(s.CountSessions(a.userID, tt.filter)); count != tt.count {
+			t.Errorf("CountSessions(%+v) = %d, want %d", tt.filter, count, tt.count)
+		}
+	}
+}
+
+// GetSetsBySessionId loads the same sets, in the same order, as loading each
+// workout's sets in the order the session lists its workouts.
+func checkSetsBySession(t *testing.T, s Store) {
+	a := seedAccount(t, s, "a@example.com")
+	b := seedAccount(t, s, "b@example.com")
+	squat := must(s.FindExercise("squat", a.userID))
+	deadlift := must(s.FindExercise("deadlift", a.userID))
+	squatID := int(must(s.CreateWorkoutForSession(a.sessionID, squat.Id, squat.Name, a.userID)))
+	deadliftID := int(must(s.CreateWorkoutForSession(a.sessionID, deadlift.Id, deadlift.Name, a.userID)))
+	// Sets are added out of workout order, and the workouts rearranged.
+	for _, workoutID := range []int{deadliftID, squatID, a.workoutID, squatID, deadliftID, squatID} {
+		must(s.CreateSetForWorkout(Set{WorkoutID: workoutID, Weight: 100, NumberOfReps: 5, Unit: UnitKg, Type: "working"}))
+	}
+	must(s.CreateSetForWorkout(Set{WorkoutID: b.workoutID, Weight: 60, NumberOfReps: 5, Unit: UnitKg, Type: "working"}))
+
+	check := func(when string) {
+		t.Helper()
+		var want []SetRow
+		for _, workout := range must(s.GetWorkoutsBySessionId(a.sessionID)) {
+			want = append(want, must(s.GetSetsByWorkoutId(workout.Id))...)
+		}
+		if got := must(s.GetSetsBySessionId(a.sessionID)); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: GetSetsBySessionId = %+v, want %+v", when, got, want)
+		}
+		if len(want) != 7 {
+			t.Errorf("%s: %d sets in the session, want 7", when, len(want))
+		}
+	}
+	check("as added")
+	if err := s.ArrangeWorkouts(a.sessionID, [][]int{{squatID}, {a.workoutID, deadliftID}}); err != nil {
+		t.Fatal(err)
+	}
+	check("rearranged")
+}
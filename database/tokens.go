@@ -0,0 +1,123 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// refreshTokenColumns are the RefreshToken columns read by refreshTokenFields,
+// in order.
+const refreshTokenColumns = "tokenID, userID, familyID, tokenHash, expiresAt, COALESCE(usedAt, ''), COALESCE(revokedAt, '')"
+
+// refreshTokenFields returns the scan destinations for refreshTokenColumns.
+func refreshTokenFields(token *RefreshTokenRow) []any {
+	return []any{&token.Id, &token.UserID, &token.FamilyID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt}
+}
+
+// CreateRefreshToken saves the first token of a new login. The user's expired
+// tokens are cleared out at the same time.
+func (d *DBConn) CreateRefreshToken(token RefreshToken) (int64, error) {
+	if _, err := d.db.Exec(d.rebind("DELETE FROM RefreshToken WHERE userID = ? AND expiresAt < ?"), token.UserID, NowDateTime()); err != nil {
+		return 0, fmt.Errorf("CreateRefreshToken: %w", err)
+	}
+	var tokenID int64
+	query := "INSERT INTO RefreshToken (userID, familyID, tokenHash, expiresAt) VALUES (?, ?, ?, ?) RETURNING tokenID"
+	if err := d.db.QueryRow(d.rebind(query), token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).Scan(&tokenID); err != nil {
+		return 0, fmt.Errorf("CreateRefreshToken: %w", err)
+	}
+	return tokenID, nil
+}
+
+func (d *DBConn) GetRefreshToken(tokenHash string) (RefreshTokenRow, error) {
+	var token RefreshTokenRow
+	query := "SELECT " + refreshTokenColumns + " FROM RefreshToken WHERE tokenHash = ?"
+	if err := d.db.QueryRow(d.rebind(query), tokenHash).Scan(refreshTokenFields(&token)...); err != nil {
+		if err == sql.ErrNoRows {
+			return token, fmt.Errorf("GetRefreshToken: %w", ErrNotFound)
+		}
+		return token, fmt.Errorf("GetRefreshToken: %w", err)
+	}
+	return token, nil
+}
+
+// RotateRefreshToken trades in the token with tokenHash for next, which joins
+// the same login. Unknown, expired and revoked tokens give ErrNotFound. A token
+// that was already traded in gives ErrTokenReused and revokes its login.
+func (d *DBConn) RotateRefreshToken(tokenHash string, next RefreshToken) (RefreshTokenRow, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", err)
+	}
+	defer tx.Rollback()
+
+	var old RefreshTokenRow
+	query := "SELECT " + refreshTokenColumns + " FROM RefreshToken WHERE tokenHash = ?"
+	if err := tx.QueryRow(d.rebind(query), tokenHash).Scan(refreshTokenFields(&old)...); err != nil {
+		if err == sql.ErrNoRows {
+			return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", ErrNotFound)
+		}
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", err)
+	}
+	now := NowDateTime()
+	if old.RevokedAt != "" || old.ExpiresAt <= now {
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", ErrNotFound)
+	}
+	// Marking the token used only succeeds once, so of two concurrent
+	// refreshes with the same token one is treated as reuse.
+	result, err := tx.Exec(d.rebind("UPDATE RefreshToken SET usedAt = ? WHERE tokenID = ? AND usedAt IS NULL"), now, old.Id)
+	if err != nil {
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", err)
+	}
+	if n == 0 {
+		if _, err := tx.Exec(d.rebind("UPDATE RefreshToken SET revokedAt = ? WHERE familyID = ? AND revokedAt IS NULL"), now, old.FamilyID); err != nil {
+			return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", err)
+		}
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", ErrTokenReused)
+	}
+
+	token := RefreshTokenRow{RefreshToken: RefreshToken{UserID: old.UserID, FamilyID: old.FamilyID, TokenHash: next.TokenHash, ExpiresAt: next.ExpiresAt}}
+	query = "INSERT INTO RefreshToken (userID, familyID, tokenHash, expiresAt) VALUES (?, ?, ?, ?) RETURNING tokenID"
+	if err := tx.QueryRow(d.rebind(query), token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).Scan(&token.Id); err != nil {
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return RefreshTokenRow{}, fmt.Errorf("RotateRefreshToken: %w", err)
+	}
+	return token, nil
+}
+
+// RefreshTokenFamilyActive reports whether a login has not been signed out.
+// Access tokens name their login, so they stop working with it.
+func (d *DBConn) RefreshTokenFamilyActive(familyID string) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS(SELECT 1 FROM RefreshToken WHERE familyID = ? AND revokedAt IS NULL)"
+	if err := d.db.QueryRow(d.rebind(query), familyID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("RefreshTokenFamilyActive: %w", err)
+	}
+	return exists, nil
+}
+
+// RevokeRefreshTokenFamily signs out one login.
+func (d *DBConn) RevokeRefreshTokenFamily(familyID string) error {
+	query := "UPDATE RefreshToken SET revokedAt = ? WHERE familyID = ? AND revokedAt IS NULL"
+	if _, err := d.db.Exec(d.rebind(query), NowDateTime(), familyID); err != nil {
+		return fmt.Errorf("RevokeRefreshTokenFamily: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserRefreshTokens signs out every login of the user.
+func (d *DBConn) RevokeUserRefreshTokens(userID int) error {
+	query := "UPDATE RefreshToken SET revokedAt = ? WHERE userID = ? AND revokedAt IS NULL"
+	if _, err := d.db.Exec(d.rebind(query), NowDateTime(), userID); err != nil {
+		return fmt.Errorf("RevokeUserRefreshTokens: %w", err)
+	}
+	return nil
+}
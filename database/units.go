@@ -0,0 +1,41 @@
+package database
+
+import (
+	"math"
+	"slices"
+)
+
+// Weight units. Weights are stored in kilograms; the unit only says how a
+// weight was entered or should be shown.
+const (
+	UnitKg = "kg"
+	UnitLb = "lb"
+)
+
+// Units lists the valid weight units.
+var Units = []string{UnitKg, UnitLb}
+
+// validUnit reports whether unit is one of Units.
+func validUnit(unit string) bool {
+	return slices.Contains(Units, unit)
+}
+
+// kgPerLb is the exact international avoirdupois pound.
+const kgPerLb = 0.45359237
+
+// WeightToKg converts a weight entered in unit to kilograms.
+func WeightToKg(weight float64, unit string) float64 {
+	if unit == UnitLb {
+		return weight * kgPerLb
+	}
+	return weight
+}
+
+// WeightFromKg converts kilograms to unit. The result is rounded to a
+// thousandth so a weight entered in pounds reads back exactly.
+func WeightFromKg(kg float64, unit string) float64 {
+	if unit == UnitLb {
+		kg = kg / kgPerLb
+	}
+	return math.Round(kg*1000) / 1000
+}
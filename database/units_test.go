@@ -0,0 +1,44 @@
+package database
+
+import "testing"
+
+func TestWeightRoundTrip(t *testing.T) {
+	// Weights as they are typed in, including plate maths in quarter and
+	// eighth pounds and a pound weight with three decimals.
+	weights := []float64{0, 0.5, 1, 2.5, 5, 45, 135, 225.25, 315.125, 405, 1000.001, 2.205}
+	for _, unit := range Units {
+		for _, weight := range weights {
+			if got := WeightFromKg(WeightToKg(weight, unit), unit); got != weight {
+				t.Errorf("%v %s reads back as %v", weight, unit, got)
+			}
+		}
+	}
+}
+
+func TestWeightConversion(t *testing.T) {
+	tests := []struct {
+		kg   float64
+		unit string
+		want float64
+	}{
+		{100, UnitKg, 100},
+		{kgPerLb, UnitLb, 1},
+		{100, UnitLb, 220.462},
+		{20, UnitLb, 44.092},
+		// Kilograms are rounded to a thousandth too.
+		{82.12345, UnitKg, 82.123},
+		{82.1235, UnitKg, 82.124},
+		{0.0004, UnitKg, 0},
+	}
+	for _, tt := range tests {
+		if got := WeightFromKg(tt.kg, tt.unit); got != tt.want {
+			t.Errorf("WeightFromKg(%v, %s) = %v, want %v", tt.kg, tt.unit, got, tt.want)
+		}
+	}
+	if got := WeightToKg(225, UnitLb); got != 225*kgPerLb {
+		t.Errorf("WeightToKg(225, lb) = %v, want %v", got, 225*kgPerLb)
+	}
+	if got := WeightToKg(100, UnitKg); got != 100 {
+		t.Errorf("WeightToKg(100, kg) = %v, want 100", got)
+	}
+}
@@ -0,0 +1,80 @@
+package database
+
+import "fmt"
+
+// workoutColumns are the Workouts columns read by workoutFields, in order.
+const workoutColumns = "workoutID, sessionID, workoutname, userID, exerciseID, position, groupNumber"
+
+// workoutFields returns the scan destinations for workoutColumns.
+func workoutFields(workout *WorkoutRow) []any {
+	return []any{&workout.Id, &workout.SessionID, &workout.WorkoutName, &workout.UserID, &workout.ExerciseID, &workout.Position, &workout.Group}
+}
+
+// workoutOrder sorts a session's workouts the way they are listed. Ties on
+// position, left by workouts added before it was recorded, go newest first.
+const workoutOrder = "position, workoutID DESC"
+
+// workoutLayout is where ArrangeWorkouts puts one workout.
+type workoutLayout struct {
+	position int
+	group    int
+}
+
+// arrangeLayout maps each workout in groups to its place in the session.
+// Positions follow the order of groups, and groups of two or more workouts are
+// numbered from 1; a group of one is a workout on its own.
+func arrangeLayout(groups [][]int) map[int]workoutLayout {
+	layout := map[int]workoutLayout{}
+	position, group := 0, 0
+	for _, ids := range groups {
+		number := 0
+		if len(ids) > 1 {
+			group++
+			number = group
+		}
+		for _, id := range ids {
+			layout[id] = workoutLayout{position: position, group: number}
+			position++
+		}
+	}
+	return layout
+}
+
+// ArrangeWorkouts reorders a session's workouts and regroups them into
+// supersets or circuits. groups lists every workout of the session exactly
+// once, in order, with the members of each superset together.
+func (d *DBConn) ArrangeWorkouts(sessionID int, groups [][]int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ArrangeWorkouts: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE Workouts SET position = ?, groupNumber = ? WHERE workoutID = ? AND sessionID = ?"
+	for workoutID, layout := range arrangeLayout(groups) {
+		if _, err := tx.Exec(d.rebind(query), layout.position, layout.group, workoutID, sessionID); err != nil {
+			return fmt.Errorf("ArrangeWorkouts: workout %d: %w", workoutID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ArrangeWorkouts: %w", err)
+	}
+	return nil
+}
+
+// workoutGroupsVersion is the schema version that added workout order and
+// groups, for reading older backups.
+const workoutGroupsVersion = 8
+
+// restoredLayout returns the Position and Group of the i-th of a session's n
+// workouts read from a backup written at schema version. Sessions in older
+// backups had no layout and were listed newest first.
+func restoredLayout(workout BackupWorkout, i int, n int, version int) (int, int, error) {
+	if version < workoutGroupsVersion {
+		return n - 1 - i, 0, nil
+	}
+	if workout.Position < 0 || workout.Group < 0 {
+		return 0, 0, fmt.Errorf("invalid position or group: %w", ErrInvalidBackup)
+	}
+	return workout.Position, workout.Group, nil
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// maxBackupSize caps the size of an uploaded account backup.
+const maxBackupSize = 64 << 20
+
+// Download everything the user owns as a JSON backup
+func (app *App) AccountExportHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	backup, err := app.db.ExportAccount(userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("AccountExportHandler: %v", err)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="account.json"`)
+	app.writeJSON(w, http.StatusOK, backup)
+}
+
+// Replace all of the user's data with the contents of a JSON backup
+func (app *App) AccountImportHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var backup database.Backup
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBackupSize)).Decode(&backup); err != nil {
+		http.Error(w, "Could not decode backup", http.StatusBadRequest)
+		return
+	}
+	version, err := database.SchemaVersion()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("AccountImportHandler: %v", err)
+		return
+	}
+	if backup.Version < 1 || backup.Version > version {
+		http.Error(w, fmt.Sprintf("Unsupported backup version %d, this server reads versions up to %d", backup.Version, version), http.StatusBadRequest)
+		return
+	}
+	if err := app.db.RestoreAccount(userID, backup); err != nil {
+		if errors.Is(err, database.ErrInvalidBackup) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Could not restore backup", http.StatusInternalServerError)
+		app.logger.Error().Msgf("AccountImportHandler: %v", err)
+		return
+	}
+
+	report := RestoreReport{Exercises: len(backup.Exercises), Sessions: len(backup.Sessions), Routines: len(backup.Routines)}
+	for _, session := range backup.Sessions {
+		report.Workouts += len(session.Workouts)
+		for _, workout := range session.Workouts {
+			report.Sets += len(workout.Sets)
+		}
+	}
+	app.writeJSON(w, http.StatusOK, report)
+}
@@ -2,7 +2,12 @@ package web
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -12,15 +17,27 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/milindtheengineer/workout-tracker-server/config"
 	"github.com/milindtheengineer/workout-tracker-server/database"
-	"google.golang.org/api/idtoken"
 )
 
 type contextKey string
 
 var contextKeyUserID = contextKey("userID")
 
+// contextKeyApiToken holds the ID of the API token a request was
+// authenticated with, when it was.
+var contextKeyApiToken = contextKey("apiToken")
+
 const userIDKey = "userID"
 
+// Access tokens are short-lived JWTs; the refresh token that renews them is
+// an opaque random string checked against the database.
+const (
+	accessCookie    = "token"
+	refreshCookie   = "refresh_token"
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
 func (app *App) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	var loginInfo LoginInfo
 	if err := json.NewDecoder(r.Body).Decode(&loginInfo); err != nil {
@@ -28,75 +45,390 @@ func (app *App) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		app.logger.Error().Msgf("Decode: %v", err)
 		return
 	}
-	payload, err := idtoken.Validate(context.Background(), loginInfo.Credential, config.AppConfig.GoogleToken)
+	if loginInfo.Provider == "" {
+		loginInfo.Provider = "google"
+	}
+	provider, ok := app.providers[loginInfo.Provider]
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusBadRequest)
+		return
+	}
+	identity, err := provider.Verify(r.Context(), loginInfo.Credential)
 	if err != nil {
 		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
-		app.logger.Error().Msgf("Validate: %v", err)
+		app.logger.Error().Msgf("Verify: %v", err)
 		return
 	}
-	user, err := app.db.GetUserByEmail(payload.Claims["email"].(string))
+	user, err := app.identityUser(identity)
 	if err != nil {
-		if strings.Contains(err.Error(), "no user found") {
-			id, err := app.db.CreateUser(database.User{Email: payload.Claims["email"].(string), Name: "test"})
-			if err != nil {
-				http.Error(w, "Unauthenticated", http.StatusUnauthorized)
-				app.logger.Error().Msgf("GetUserByEmail: %v", err)
-				return
-			}
-			user = database.UserRow{Id: int(id)}
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		app.logger.Error().Msgf("identityUser: %v", err)
+		return
+	}
+	if err := app.startLogin(w, user.Id); err != nil {
+		app.logger.Error().Msgf("%v", err)
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+}
+
+// identityUser finds the user an identity belongs to and refreshes their
+// profile from it. An identity seen for the first time is linked to the user
+// with its email, if the provider has verified the email, and otherwise gets
+// a new user. Linking to a password account nobody has proven is theirs
+// claims it, see unprovenAccount.
+func (app *App) identityUser(identity Identity) (database.UserRow, error) {
+	user, err := app.db.GetUserByIdentity(identity.Provider, identity.Subject)
+	if err == nil {
+		return user, app.refreshProfile(user, identity)
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return user, err
+	}
+	if identity.Email == "" {
+		return user, fmt.Errorf("%s identity %s has no email", identity.Provider, identity.Subject)
+	}
+	link := database.UserIdentity{Provider: identity.Provider, Subject: identity.Subject}
+	user, err = app.db.GetUserByEmail(identity.Email)
+	if err == nil {
+		if !identity.EmailVerified {
+			return user, fmt.Errorf("%s identity %s has the unverified email of user %d", identity.Provider, identity.Subject, user.Id)
+		}
+		link.UserID = user.Id
+		claim, err := app.unprovenAccount(user.Id)
+		if err != nil {
+			return user, err
+		}
+		if claim {
+			err = app.db.ClaimAccount(link)
 		} else {
-			http.Error(w, "Unauthenticated", http.StatusUnauthorized)
-			app.logger.Error().Msgf("GetUserByEmail: %v", err)
+			err = app.db.LinkIdentity(link)
+		}
+		if err != nil {
+			return user, err
+		}
+		return user, app.refreshProfile(user, identity)
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return user, err
+	}
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	newUser := database.User{Email: identity.Email, Name: name, Picture: identity.Picture, Locale: identity.Locale}
+	id, err := app.db.CreateUserWithIdentity(newUser, link)
+	if err != nil {
+		return user, err
+	}
+	return app.db.GetUserById(int(id))
+}
+
+// unprovenAccount reports whether the user registered with a password and
+// has never signed in with a provider, so nobody has proven the email is
+// theirs. Anyone can register any email, so the first provider to verify it
+// claims the account from whoever set the password.
+func (app *App) unprovenAccount(userID int) (bool, error) {
+	if _, err := app.db.GetPasswordCredential(userID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	linked, err := app.db.UserHasIdentity(userID)
+	return !linked, err
+}
+
+// refreshProfile saves the name, picture and locale the identity carries when
+// they differ from the user's. Claims the provider left out keep their value,
+// and so does a name the user has changed.
+func (app *App) refreshProfile(user database.UserRow, identity Identity) error {
+	profile := user.User
+	if identity.Name != "" && !user.NameEdited {
+		profile.Name = identity.Name
+	}
+	if identity.Picture != "" {
+		profile.Picture = identity.Picture
+	}
+	if identity.Locale != "" {
+		profile.Locale = identity.Locale
+	}
+	if profile.Name == user.Name && profile.Picture == user.Picture && profile.Locale == user.Locale {
+		return nil
+	}
+	return app.db.UpdateUserProfile(user.Id, profile)
+}
+
+// Trade the refresh token cookie for a new access token and refresh token
+func (app *App) HandleRefresh(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(refreshCookie)
+	if err != nil {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	value, hash, err := newRefreshToken()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandleRefresh: %v", err)
+		return
+	}
+	next := database.RefreshToken{TokenHash: hash, ExpiresAt: time.Now().Add(refreshTokenTTL).UTC().Format(database.DateTimeLayout)}
+	token, err := app.db.RotateRefreshToken(hashToken(cookie.Value), next)
+	if err != nil {
+		if errors.Is(err, database.ErrTokenReused) {
+			app.logger.Warn().Msgf("HandleRefresh: %v", err)
+		} else if !errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("HandleRefresh: %v", err)
 			return
 		}
+		clearAuthCookies(w)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err := setAuthCookies(w, token, value); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandleRefresh: %v", err)
+	}
+}
+
+// Sign out this device: revoke its login and clear the cookies. Works with an
+// expired access token, and succeeds when there is nothing to sign out. The
+// login is found from the refresh token or, when that is missing or unknown,
+// from the access token.
+func (app *App) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	var loginID string
+	if cookie, err := r.Cookie(refreshCookie); err == nil {
+		token, err := app.db.GetRefreshToken(hashToken(cookie.Value))
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("HandleLogout: %v", err)
+			return
+		}
+		loginID = token.FamilyID
+	}
+	if cookie, err := r.Cookie(accessCookie); err == nil && loginID == "" {
+		if claims, err := app.decodeJWT(cookie.Value); err == nil {
+			loginID = claims.LoginID
+		}
+	}
+	if loginID != "" {
+		if err := app.db.RevokeRefreshTokenFamily(loginID); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("HandleLogout: %v", err)
+			return
+		}
+	}
+	clearAuthCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sign out every device of the signed-in user
+func (app *App) HandleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
 	}
-	expirationTime := time.Now().Add(24 * time.Hour)
+	if err := app.db.RevokeUserRefreshTokens(userID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandleLogoutAll: %v", err)
+		return
+	}
+	clearAuthCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startLogin begins a new login for the user and sets its cookies.
+func (app *App) startLogin(w http.ResponseWriter, userID int) error {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return fmt.Errorf("startLogin: %w", err)
+	}
+	value, hash, err := newRefreshToken()
+	if err != nil {
+		return fmt.Errorf("startLogin: %w", err)
+	}
+	token := database.RefreshTokenRow{RefreshToken: database.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(refreshTokenTTL).UTC().Format(database.DateTimeLayout),
+	}}
+	id, err := app.db.CreateRefreshToken(token.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("startLogin: %w", err)
+	}
+	token.Id = int(id)
+	return setAuthCookies(w, token, value)
+}
+
+// setAuthCookies sets a fresh access token for the login of token, and the
+// refresh token itself, whose unhashed value is value.
+func setAuthCookies(w http.ResponseWriter, token database.RefreshTokenRow, value string) error {
+	expirationTime := time.Now().Add(accessTokenTTL)
 	claims := &Claims{
-		UserID: strconv.Itoa(user.Id),
+		UserID:  strconv.Itoa(token.UserID),
+		LoginID: token.FamilyID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: &jwt.NumericDate{expirationTime},
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(config.AppConfig.SigningKey))
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.SigningKey))
 	if err != nil {
-		app.logger.Error().Msgf("%v", err)
-		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
-		return
+		return fmt.Errorf("setAuthCookies: %w", err)
+	}
+	refreshExpires, err := time.Parse(database.DateTimeLayout, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("setAuthCookies: %w", err)
 	}
 
 	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
+		Name:     accessCookie,
 		Value:    tokenString,
+		Path:     "/",
 		Expires:  expirationTime,
 		SameSite: http.SameSiteNoneMode,
 		Secure:   true,
 	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  refreshExpires,
+		SameSite: http.SameSiteNoneMode,
+		Secure:   true,
+		HttpOnly: true,
+	})
+	return nil
+}
+
+// clearAuthCookies tells the browser to drop both tokens.
+func clearAuthCookies(w http.ResponseWriter) {
+	for _, name := range []string{accessCookie, refreshCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     "/",
+			MaxAge:   -1,
+			SameSite: http.SameSiteNoneMode,
+			Secure:   true,
+			HttpOnly: name == refreshCookie,
+		})
+	}
 }
 
+// newRefreshToken returns a new refresh token and the hash it is stored as.
+func newRefreshToken() (string, string, error) {
+	value, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return value, hashToken(value), nil
+}
+
+// randomToken returns n random bytes, base64url encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is the form a token is stored and looked up in.
+func hashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// authMiddleware accepts either an API token in an Authorization: Bearer
+// header or the access token cookie.
 func (app *App) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, err := r.Cookie("token")
+		if header := r.Header.Get("Authorization"); header != "" {
+			app.apiTokenAuth(w, r, next, header)
+			return
+		}
+		token, err := r.Cookie(accessCookie)
 		if err != nil {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		userID, err := app.decodeJWT(token.Value)
+		claims, err := app.decodeJWT(token.Value)
 		if err != nil {
 			app.logger.Error().Msgf("decode: %v", err)
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
+		active, err := app.db.RefreshTokenFamilyActive(claims.LoginID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("authMiddleware: %v", err)
+			return
+		}
+		if !active {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
 
-		ctx := context.WithValue(r.Context(), contextKeyUserID, userID)
+		ctx := context.WithValue(r.Context(), contextKeyUserID, claims.UserID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func (app *App) decodeJWT(tokenStr string) (string, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+// apiTokenAuth serves the request as the owner of the API token in header.
+// Read tokens are limited to requests that change nothing. The scheme is
+// case-insensitive, as in any HTTP authorization header.
+func (app *App) apiTokenAuth(w http.ResponseWriter, r *http.Request, next http.Handler, header string) {
+	scheme, value, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	token, err := app.db.GetApiTokenByHash(hashToken(strings.TrimSpace(value)))
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("apiTokenAuth: %v", err)
+			return
+		}
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	if token.Scope != database.ApiTokenScopeWrite && !readOnlyMethod(r.Method) {
+		http.Error(w, "Token is read-only", http.StatusForbidden)
+		return
+	}
+	if err := app.db.TouchApiToken(token.Id); err != nil {
+		app.logger.Error().Msgf("apiTokenAuth: %v", err)
+	}
+	ctx := context.WithValue(r.Context(), contextKeyUserID, strconv.Itoa(token.UserID))
+	ctx = context.WithValue(ctx, contextKeyApiToken, token.Id)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// readOnlyMethod reports whether requests with method change nothing.
+func readOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// sessionOnly turns away requests authenticated with an API token, for the
+// endpoints that manage sign-ins and tokens themselves and for restoring a
+// backup, which replaces all of the user's data.
+func sessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(contextKeyApiToken).(int); ok {
+			http.Error(w, "Not allowed with an API token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *App) decodeJWT(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
 		// Don't forget to validate the alg is what you expect:
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -104,19 +436,17 @@ func (app *App) decodeJWT(tokenStr string) (string, error) {
 		return []byte(config.AppConfig.SigningKey), nil
 	})
 	if err != nil {
-		return "", fmt.Errorf("DecodeJWT: %v", err)
+		return nil, fmt.Errorf("DecodeJWT: %v", err)
 	}
 	if !token.Valid {
-		return "", fmt.Errorf("DecodeJWT:: invalid token")
+		return nil, fmt.Errorf("DecodeJWT:: invalid token")
 	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", fmt.Errorf("DecodeJWT:: Invalid claims")
+	if claims.UserID == "" {
+		return nil, fmt.Errorf("DecodeJWT:: User ID not found in claims")
 	}
-	userID, ok := claims["userId"].(string)
-	if !ok {
-		return "", fmt.Errorf("DecodeJWT:: User ID not found in claims")
+	// Tokens from before logins were tracked cannot be revoked.
+	if claims.LoginID == "" {
+		return nil, fmt.Errorf("DecodeJWT:: Login ID not found in claims")
 	}
-	return userID, nil
+	return claims, nil
 }
@@ -0,0 +1,207 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// fakeProvider signs in the identity a credential names.
+type fakeProvider map[string]Identity
+
+func (p fakeProvider) Verify(ctx context.Context, credential string) (Identity, error) {
+	identity, ok := p[credential]
+	if !ok {
+		return identity, errors.New("unknown credential")
+	}
+	return identity, nil
+}
+
+// passwordSignIn registers email with a password and signs in with it.
+func passwordSignIn(t *testing.T, handler http.Handler, email string, password string) []*http.Cookie {
+	t.Helper()
+	body := fmt.Sprintf(`{"Email": %q, "Password": %q}`, email, password)
+	if rec := call(handler, nil, http.MethodPost, "/register", body); rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /register = %d %s", rec.Code, rec.Body)
+	}
+	rec := call(handler, nil, http.MethodPost, "/login/password", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /login/password = %d %s", rec.Code, rec.Body)
+	}
+	return rec.Result().Cookies()
+}
+
+// withCookie returns cookies with the value of the named one replaced, or the
+// cookie dropped when value is empty.
+func withCookie(cookies []*http.Cookie, name string, value string) []*http.Cookie {
+	var changed []*http.Cookie
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			if value == "" {
+				continue
+			}
+			cookie = &http.Cookie{Name: name, Value: value}
+		}
+		changed = append(changed, cookie)
+	}
+	return changed
+}
+
+func TestLogoutSignsOutThisDevice(t *testing.T) {
+	tests := []struct {
+		name    string
+		refresh string
+	}{
+		{name: "refresh token"},
+		{name: "no refresh token", refresh: "-"},
+		{name: "unknown refresh token", refresh: "not a refresh token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, handler := testApp(t)
+			userID, cookies := newUser(t, app, "alice@example.com")
+			other := signIn(t, app, userID)
+			sent := cookies
+			switch tt.refresh {
+			case "":
+			case "-":
+				sent = withCookie(cookies, refreshCookie, "")
+			default:
+				sent = withCookie(cookies, refreshCookie, tt.refresh)
+			}
+			if rec := call(handler, sent, http.MethodPost, "/logout", ""); rec.Code != http.StatusNoContent {
+				t.Fatalf("POST /logout = %d %s", rec.Code, rec.Body)
+			}
+			if rec := call(handler, cookies, http.MethodGet, "/me", ""); rec.Code != http.StatusUnauthorized {
+				t.Errorf("GET /me after signing out = %d, want 401", rec.Code)
+			}
+			if rec := call(handler, other, http.MethodGet, "/me", ""); rec.Code != http.StatusOK {
+				t.Errorf("GET /me on another device = %d, want 200", rec.Code)
+			}
+		})
+	}
+}
+
+// Signing out with a refresh token that was already traded for a new pair,
+// as when a refresh raced the logout, still ends the login.
+func TestLogoutAfterRefresh(t *testing.T) {
+	app, handler := testApp(t)
+	_, cookies := newUser(t, app, "alice@example.com")
+	rec := call(handler, cookies, http.MethodPost, "/refresh", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /refresh = %d %s", rec.Code, rec.Body)
+	}
+	var access string
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == accessCookie {
+			access = cookie.Value
+		}
+	}
+	refreshed := withCookie(cookies, accessCookie, access)
+	if rec := call(handler, refreshed, http.MethodPost, "/logout", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("POST /logout = %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(handler, withCookie(refreshed, refreshCookie, ""), http.MethodGet, "/me", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /me after signing out = %d, want 401", rec.Code)
+	}
+}
+
+// Anyone can register someone else's email, so the first sign-in by a
+// provider that verified the email takes the account over.
+func TestProviderClaimsPasswordAccount(t *testing.T) {
+	app, handler := testApp(t)
+	app.providers = map[string]IdentityProvider{"fake": fakeProvider{
+		"owner":      {Provider: "fake", Subject: "1", Email: "alice@example.com", EmailVerified: true},
+		"unverified": {Provider: "fake", Subject: "2", Email: "alice@example.com"},
+	}}
+	squatter := passwordSignIn(t, handler, "alice@example.com", "squatter's password")
+	create(t, handler, squatter, "/tokens", `{"Name": "backdoor"}`)
+
+	if rec := call(handler, nil, http.MethodPost, "/login", `{"provider": "fake", "credential": "unverified"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("sign-in with an unverified email = %d, want 401", rec.Code)
+	}
+	rec := call(handler, nil, http.MethodPost, "/login", `{"provider": "fake", "credential": "owner"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("sign-in with a verified email = %d %s", rec.Code, rec.Body)
+	}
+	owner := rec.Result().Cookies()
+	if rec := call(handler, owner, http.MethodGet, "/tokens", ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("the squatter's API tokens survived: %s", rec.Body)
+	}
+	if rec := call(handler, squatter, http.MethodGet, "/me", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("the squatter's login = %d, want 401", rec.Code)
+	}
+	body := `{"Email": "alice@example.com", "Password": "squatter's password"}`
+	if rec := call(handler, nil, http.MethodPost, "/login/password", body); rec.Code != http.StatusUnauthorized {
+		t.Errorf("the squatter's password = %d, want 401", rec.Code)
+	}
+}
+
+// A password account that has signed in with a provider has proven its email,
+// so another provider verifying it only adds a way to sign in.
+func TestProviderLinksProvenPasswordAccount(t *testing.T) {
+	app, handler := testApp(t)
+	app.providers = map[string]IdentityProvider{"fake": fakeProvider{
+		"first":  {Provider: "fake", Subject: "1", Email: "alice@example.com", EmailVerified: true},
+		"second": {Provider: "fake", Subject: "2", Email: "alice@example.com", EmailVerified: true},
+	}}
+	userID, err := app.db.CreateUserWithIdentity(database.User{Email: "alice@example.com"}, database.UserIdentity{Provider: "fake", Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashPassword("alice's password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.db.SetPassword(int(userID), hash); err != nil {
+		t.Fatal(err)
+	}
+	if rec := call(handler, nil, http.MethodPost, "/login", `{"provider": "fake", "credential": "second"}`); rec.Code != http.StatusOK {
+		t.Fatalf("sign-in with a second identity = %d %s", rec.Code, rec.Body)
+	}
+	body := `{"Email": "alice@example.com", "Password": "alice's password"}`
+	if rec := call(handler, nil, http.MethodPost, "/login/password", body); rec.Code != http.StatusOK {
+		t.Errorf("password sign-in after linking = %d, want 200", rec.Code)
+	}
+}
+
+func TestSignInKeepsEditedName(t *testing.T) {
+	app, handler := testApp(t)
+	app.providers = map[string]IdentityProvider{"fake": fakeProvider{
+		"first":  {Provider: "fake", Subject: "1", Email: "alice@example.com", EmailVerified: true, Name: "Alice", Picture: "first.png"},
+		"second": {Provider: "fake", Subject: "1", Email: "alice@example.com", EmailVerified: true, Name: "Alice Smith", Picture: "second.png"},
+		"third":  {Provider: "fake", Subject: "1", Email: "alice@example.com", EmailVerified: true, Name: "Alice Jones", Picture: "third.png"},
+	}}
+	profile := func(credential string) database.UserRow {
+		t.Helper()
+		rec := call(handler, nil, http.MethodPost, "/login", fmt.Sprintf(`{"provider": "fake", "credential": %q}`, credential))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("sign-in = %d %s", rec.Code, rec.Body)
+		}
+		user, err := app.db.GetUserByIdentity("fake", "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	cookies := signIn(t, app, profile("first").Id)
+	// Changing only the unit leaves the name to the provider.
+	if rec := call(handler, cookies, http.MethodPatch, "/me", `{"Unit": "lb"}`); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH /me = %d %s", rec.Code, rec.Body)
+	}
+	if user := profile("second"); user.Name != "Alice Smith" || user.NameEdited {
+		t.Errorf("after a sign-in: name %q, edited %v, want the provider's name", user.Name, user.NameEdited)
+	}
+	if rec := call(handler, cookies, http.MethodPatch, "/me", `{"Name": "Al"}`); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH /me = %d %s", rec.Code, rec.Body)
+	}
+	if user := profile("third"); user.Name != "Al" || user.Picture != "third.png" {
+		t.Errorf("after renaming and signing in: name %q, picture %q, want Al and third.png", user.Name, user.Picture)
+	}
+}
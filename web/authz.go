@@ -0,0 +1,102 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// getUserID returns the ID of the authenticated user placed in the request
+// context by authMiddleware.
+func getUserID(r *http.Request) (int, error) {
+	userIDStr, ok := r.Context().Value(contextKeyUserID).(string)
+	if !ok {
+		return 0, fmt.Errorf("getUserID: user ID missing from context")
+	}
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return 0, fmt.Errorf("getUserID: %w", err)
+	}
+	return userID, nil
+}
+
+// authorizeSession writes a 404 and returns false unless the session exists and
+// belongs to userID. Sessions owned by other users are indistinguishable from
+// missing ones so IDs cannot be probed.
+func (app *App) authorizeSession(w http.ResponseWriter, sessionID int, userID int) bool {
+	ok, err := app.db.SessionBelongsToUser(sessionID, userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("authorizeSession: %v", err)
+		return false
+	}
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// authorizeWorkout writes a 404 and returns false unless the workout exists and
+// belongs to userID.
+func (app *App) authorizeWorkout(w http.ResponseWriter, workoutID int, userID int) bool {
+	ok, err := app.db.WorkoutBelongsToUser(workoutID, userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("authorizeWorkout: %v", err)
+		return false
+	}
+	if !ok {
+		http.Error(w, "Workout not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// authorizeSet writes a 404 and returns false unless the set exists and
+// belongs to userID.
+func (app *App) authorizeSet(w http.ResponseWriter, setID int, userID int) bool {
+	ok, err := app.db.SetBelongsToUser(setID, userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("authorizeSet: %v", err)
+		return false
+	}
+	if !ok {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// authorizeRoutine writes a 404 and returns false unless the routine exists and
+// belongs to userID.
+func (app *App) authorizeRoutine(w http.ResponseWriter, routineID int, userID int) bool {
+	ok, err := app.db.RoutineBelongsToUser(routineID, userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("authorizeRoutine: %v", err)
+		return false
+	}
+	if !ok {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// authorizeApiToken writes a 404 and returns false unless the API token exists
+// and belongs to userID.
+func (app *App) authorizeApiToken(w http.ResponseWriter, tokenID int, userID int) bool {
+	ok, err := app.db.ApiTokenBelongsToUser(tokenID, userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("authorizeApiToken: %v", err)
+		return false
+	}
+	if !ok {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
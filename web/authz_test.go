@@ -0,0 +1,212 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/milindtheengineer/workout-tracker-server/config"
+	"github.com/milindtheengineer/workout-tracker-server/database"
+	"github.com/rs/zerolog"
+)
+
+// testApp serves the API from an empty in-memory store, with password
+// accounts enabled.
+func testApp(t *testing.T) (*App, http.Handler) {
+	t.Helper()
+	config.AppConfig.SigningKey = "test signing key"
+	app := &App{db: database.NewMemoryStore(), logger: zerolog.Nop(), localAccounts: true}
+	r := chi.NewRouter()
+	app.routes(r)
+	return app, r
+}
+
+// signIn starts a new login for userID and returns its cookies.
+func signIn(t *testing.T, app *App, userID int) []*http.Cookie {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if err := app.startLogin(rec, userID); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Result().Cookies()
+}
+
+// newUser creates a user with email and signs them in.
+func newUser(t *testing.T, app *App, email string) (int, []*http.Cookie) {
+	t.Helper()
+	userID, err := app.db.CreateUser(database.User{Email: email, Name: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(userID), signIn(t, app, int(userID))
+}
+
+// call serves a request with the cookies and body and returns the response.
+func call(handler http.Handler, cookies []*http.Cookie, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// create calls a route that creates something and returns the new ID.
+func create(t *testing.T, handler http.Handler, cookies []*http.Cookie, path string, body string) int {
+	t.Helper()
+	rec := call(handler, cookies, http.MethodPost, path, body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST %s %s = %d %s", path, body, rec.Code, rec.Body)
+	}
+	var created struct{ Id int }
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	return created.Id
+}
+
+// decode reads the JSON body of rec into v.
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+}
+
+// ownedData is what one user created, each named with a marker that must not
+// show up in anyone else's responses.
+type ownedData struct {
+	exercise, session, workout, set, routine, token int
+}
+
+func seedOwnedData(t *testing.T, handler http.Handler, cookies []*http.Cookie, marker string) ownedData {
+	t.Helper()
+	var d ownedData
+	d.exercise = create(t, handler, cookies, "/exercises", fmt.Sprintf(`{"Name": "%s press"}`, marker))
+	d.session = create(t, handler, cookies, "/sessions", fmt.Sprintf(`{"Title": "%s session"}`, marker))
+	d.workout = create(t, handler, cookies, "/workouts", fmt.Sprintf(`{"SessionID": %d, "ExerciseID": %d}`, d.session, d.exercise))
+	d.set = create(t, handler, cookies, "/sets", fmt.Sprintf(`{"WorkoutID": %d, "Weight": 50, "NumberOfReps": 5}`, d.workout))
+	d.routine = create(t, handler, cookies, "/routines", fmt.Sprintf(`{"Name": "%s routine", "Exercises": [{"ExerciseID": %d}]}`, marker, d.exercise))
+	d.token = create(t, handler, cookies, "/tokens", fmt.Sprintf(`{"Name": "%s token"}`, marker))
+	return d
+}
+
+// publicRoutes are served without signing in, so they have no owner to check.
+var publicRoutes = map[string]bool{
+	"GET /health":          true,
+	"POST /login":          true,
+	"POST /refresh":        true,
+	"POST /logout":         true,
+	"POST /register":       true,
+	"POST /login/password": true,
+}
+
+// TestOtherUsersDataIsNotFound signs in as one user, creates one of
+// everything, then calls every signed-in route as a second user. Routes given
+// the first user's IDs, in the path or the body, must answer 404; routes that
+// only see the caller's own data must not show any of the first user's.
+func TestOtherUsersDataIsNotFound(t *testing.T) {
+	app, handler := testApp(t)
+	_, aliceCookies := newUser(t, app, "alice@example.com")
+	alice := seedOwnedData(t, handler, aliceCookies, "alice")
+	bobID, bobCookies := newUser(t, app, "bob@example.com")
+	bob := seedOwnedData(t, handler, bobCookies, "bob")
+
+	tests := []struct {
+		route  string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST /logout/all", "/logout/all", "", http.StatusNoContent},
+		{"PUT /me/password", "/me/password", `{"NewPassword": "bob's new password"}`, http.StatusUnauthorized},
+		{"POST /account/import", "/account/import", fmt.Sprintf(`{"Version": 1, "Routines": [{"Name": "copy", "Exercises": [{"ExerciseID": %d}]}]}`, alice.exercise), http.StatusBadRequest},
+		{"GET /tokens", "/tokens", "", http.StatusOK},
+		{"POST /tokens", "/tokens", `{"Name": "another token"}`, http.StatusCreated},
+		{"DELETE /tokens/{tokenID}", fmt.Sprintf("/tokens/%d", alice.token), "", http.StatusNotFound},
+		{"GET /me", "/me", "", http.StatusOK},
+		{"GET /sessions", "/sessions", "", http.StatusOK},
+		{"GET /sessions/{sessionID}", fmt.Sprintf("/sessions/%d", alice.session), "", http.StatusNotFound},
+		{"GET /workouts/{sessionID}", fmt.Sprintf("/workouts/%d", alice.session), "", http.StatusNotFound},
+		{"GET /sets/{workoutID}", fmt.Sprintf("/sets/%d", alice.workout), "", http.StatusNotFound},
+		{"GET /lastworkout/{workout}", "/lastworkout/alice%20press", "", http.StatusOK},
+		{"GET /exercises", "/exercises", "", http.StatusOK},
+		{"POST /exercises", "/exercises", `{"Name": "another press"}`, http.StatusCreated},
+		{"GET /exercises/{exerciseID}", fmt.Sprintf("/exercises/%d", alice.exercise), "", http.StatusNotFound},
+		{"GET /exercises/{name}/progress", "/exercises/alice%20press/progress", "", http.StatusNotFound},
+		{"GET /records", "/records", "", http.StatusOK},
+		{"GET /export.csv", "/export.csv", "", http.StatusOK},
+		{"GET /account/export", "/account/export", "", http.StatusOK},
+		{"GET /routines", "/routines", "", http.StatusOK},
+		{"GET /routines/{routineID}", fmt.Sprintf("/routines/%d", alice.routine), "", http.StatusNotFound},
+		{"POST /sessions", "/sessions", "", http.StatusCreated},
+		{"POST /workouts", "/workouts", fmt.Sprintf(`{"SessionID": %d, "WorkoutName": "squat"}`, alice.session), http.StatusNotFound},
+		{"POST /workouts", "/workouts", fmt.Sprintf(`{"SessionID": %d, "ExerciseID": %d}`, bob.session, alice.exercise), http.StatusNotFound},
+		{"POST /sets", "/sets", fmt.Sprintf(`{"WorkoutID": %d, "Weight": 50, "NumberOfReps": 5}`, alice.workout), http.StatusNotFound},
+		{"POST /import", "/import", "Date,Exercise,Reps\n2024-01-02,Squat,5\n", http.StatusOK},
+		{"POST /routines", "/routines", fmt.Sprintf(`{"Name": "copy", "Exercises": [{"ExerciseID": %d}]}`, alice.exercise), http.StatusNotFound},
+		{"POST /routines/{routineID}/start", fmt.Sprintf("/routines/%d/start", alice.routine), "", http.StatusNotFound},
+		{"POST /sessions/{sessionID}/finish", fmt.Sprintf("/sessions/%d/finish", alice.session), "", http.StatusNotFound},
+		{"PATCH /me", "/me", `{"Name": "Bob"}`, http.StatusOK},
+		{"PUT /sessions/{sessionID}", fmt.Sprintf("/sessions/%d", alice.session), `{"Title": "taken"}`, http.StatusNotFound},
+		{"PATCH /sessions/{sessionID}", fmt.Sprintf("/sessions/%d", alice.session), `{"Title": "taken"}`, http.StatusNotFound},
+		{"DELETE /sessions/{sessionID}", fmt.Sprintf("/sessions/%d", alice.session), "", http.StatusNotFound},
+		{"PUT /sessions/{sessionID}/layout", fmt.Sprintf("/sessions/%d/layout", alice.session), fmt.Sprintf(`{"Groups": [[%d]]}`, alice.workout), http.StatusNotFound},
+		{"PUT /workouts/{workoutID}", fmt.Sprintf("/workouts/%d", alice.workout), `{"WorkoutName": "squat"}`, http.StatusNotFound},
+		{"PATCH /workouts/{workoutID}", fmt.Sprintf("/workouts/%d", alice.workout), `{"WorkoutName": "squat"}`, http.StatusNotFound},
+		{"PATCH /workouts/{workoutID}", fmt.Sprintf("/workouts/%d", bob.workout), fmt.Sprintf(`{"ExerciseID": %d}`, alice.exercise), http.StatusNotFound},
+		{"DELETE /workouts/{workoutID}", fmt.Sprintf("/workouts/%d", alice.workout), "", http.StatusNotFound},
+		{"PUT /sets/{setID}", fmt.Sprintf("/sets/%d", alice.set), `{"Weight": 60, "NumberOfReps": 5}`, http.StatusNotFound},
+		{"PATCH /sets/{setID}", fmt.Sprintf("/sets/%d", alice.set), `{"Weight": 60}`, http.StatusNotFound},
+		{"DELETE /sets/{setID}", fmt.Sprintf("/sets/%d", alice.set), "", http.StatusNotFound},
+		{"PUT /routines/{routineID}", fmt.Sprintf("/routines/%d", alice.routine), `{"Name": "taken"}`, http.StatusNotFound},
+		{"PATCH /routines/{routineID}", fmt.Sprintf("/routines/%d", alice.routine), `{"Name": "taken"}`, http.StatusNotFound},
+		{"PATCH /routines/{routineID}", fmt.Sprintf("/routines/%d", bob.routine), fmt.Sprintf(`{"Exercises": [{"ExerciseID": %d}]}`, alice.exercise), http.StatusNotFound},
+		{"DELETE /routines/{routineID}", fmt.Sprintf("/routines/%d", alice.routine), "", http.StatusNotFound},
+	}
+
+	tested := map[string]bool{}
+	for _, tt := range tests {
+		tested[tt.route] = true
+		method, _, _ := strings.Cut(tt.route, " ")
+		// Each request gets its own login, as some sign Bob out.
+		rec := call(handler, signIn(t, app, bobID), method, tt.path, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s %s = %d %s, want %d", method, tt.path, tt.body, rec.Code, strings.TrimSpace(rec.Body.String()), tt.status)
+		}
+		if strings.Contains(strings.ToLower(rec.Body.String()), "alice") {
+			t.Errorf("%s %s shows Alice's data: %s", method, tt.path, rec.Body)
+		}
+	}
+	err := chi.Walk(handler.(chi.Routes), func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if key := method + " " + route; !publicRoutes[key] && !tested[key] {
+			t.Errorf("%s is not checked against another user's data", key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing Bob did touched Alice's data.
+	for _, path := range []string{
+		fmt.Sprintf("/sessions/%d", alice.session),
+		fmt.Sprintf("/routines/%d", alice.routine),
+		fmt.Sprintf("/exercises/%d", alice.exercise),
+	} {
+		if rec := call(handler, aliceCookies, http.MethodGet, path, ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "alice") {
+			t.Errorf("Alice's GET %s = %d %s", path, rec.Code, rec.Body)
+		}
+	}
+	if rec := call(handler, aliceCookies, http.MethodGet, fmt.Sprintf("/sets/%d", alice.workout), ""); !strings.Contains(rec.Body.String(), fmt.Sprintf(`"Id":%d`, alice.set)) {
+		t.Errorf("Alice's set is gone: %s", rec.Body)
+	}
+	if rec := call(handler, aliceCookies, http.MethodGet, "/tokens", ""); !strings.Contains(rec.Body.String(), "alice token") {
+		t.Errorf("Alice's token is gone: %s", rec.Body)
+	}
+}
@@ -0,0 +1,182 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// resolveExercise picks the exercise a workout refers to. An explicit
+// exerciseID must be visible to the user. Otherwise the name is resolved
+// through the catalog and its aliases, and a custom exercise is created for
+// names the catalog does not know so free-text clients keep working. It writes
+// the error response and returns false on failure.
+func (app *App) resolveExercise(w http.ResponseWriter, userID int, exerciseID int, name string) (database.ExerciseRow, bool) {
+	if exerciseID > 0 {
+		exercise, err := app.db.GetExerciseById(exerciseID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("resolveExercise: %v", err)
+			return exercise, false
+		}
+		if err != nil || (exercise.UserID != nil && *exercise.UserID != userID) {
+			http.Error(w, "Exercise not found", http.StatusNotFound)
+			return exercise, false
+		}
+		return exercise, true
+	}
+
+	if database.NormalizeExerciseName(name) == "" {
+		http.Error(w, "Invalid workout name", http.StatusBadRequest)
+		return database.ExerciseRow{}, false
+	}
+	exercise, err := app.findOrCreateExercise(userID, name)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("resolveExercise: %v", err)
+		return exercise, false
+	}
+	return exercise, true
+}
+
+// findOrCreateExercise resolves name through the catalog and its aliases,
+// creating a custom strength exercise for the user when nothing matches.
+func (app *App) findOrCreateExercise(userID int, name string) (database.ExerciseRow, error) {
+	name = database.NormalizeExerciseName(name)
+	exercise, err := app.db.FindExercise(name, userID)
+	if !errors.Is(err, database.ErrNotFound) {
+		return exercise, err
+	}
+	id, err := app.db.CreateExercise(database.Exercise{UserID: &userID, Name: name, Type: database.ExerciseTypeStrength})
+	if err != nil {
+		return exercise, err
+	}
+	return app.db.GetExerciseById(int(id))
+}
+
+// lookupExercise resolves a name or alias without creating anything. found is
+// false when the user has never heard of the exercise; ok is false when an
+// error response has been written.
+func (app *App) lookupExercise(w http.ResponseWriter, userID int, name string) (exercise database.ExerciseRow, found bool, ok bool) {
+	exercise, err := app.db.FindExercise(name, userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return exercise, false, true
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("lookupExercise: %v", err)
+		return exercise, false, false
+	}
+	return exercise, true, true
+}
+
+// Get the exercise catalog plus the user's custom exercises
+func (app *App) ExerciseListHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	exercises, err := app.db.GetExercises(userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ExerciseListHandler: %v", err)
+		return
+	}
+	if q := database.NormalizeExerciseName(r.URL.Query().Get("q")); q != "" {
+		filtered := []database.ExerciseRow{}
+		for _, exercise := range exercises {
+			match := strings.Contains(exercise.Name, q)
+			for _, alias := range exercise.Aliases {
+				match = match || strings.Contains(alias, q)
+			}
+			if match {
+				filtered = append(filtered, exercise)
+			}
+		}
+		exercises = filtered
+	}
+	if exercises == nil {
+		exercises = []database.ExerciseRow{}
+	}
+	app.writeJSON(w, http.StatusOK, exercises)
+}
+
+func (app *App) ExerciseGetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	exerciseID, err := urlParamID(r, "exerciseID")
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+	exercise, ok := app.resolveExercise(w, userID, exerciseID, "")
+	if !ok {
+		return
+	}
+	app.writeJSON(w, http.StatusOK, exercise)
+}
+
+func (app *App) ExerciseCreateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var exercise database.Exercise
+	if err := json.NewDecoder(r.Body).Decode(&exercise); err != nil {
+		http.Error(w, "Could not decode exercise", http.StatusBadRequest)
+		return
+	}
+	exercise.UserID = &userID
+	exercise.Name = database.NormalizeExerciseName(exercise.Name)
+	if exercise.Name == "" {
+		http.Error(w, "Invalid exercise name", http.StatusBadRequest)
+		return
+	}
+	if exercise.Type == "" {
+		exercise.Type = database.ExerciseTypeStrength
+	}
+	if !slices.Contains(database.ExerciseTypes, exercise.Type) {
+		http.Error(w, fmt.Sprintf("Invalid exercise type, expected one of %s", strings.Join(database.ExerciseTypes, ", ")), http.StatusBadRequest)
+		return
+	}
+	// Names and aliases must not shadow anything the user can already see,
+	// otherwise resolving a name would become ambiguous.
+	for _, name := range append([]string{exercise.Name}, exercise.Aliases...) {
+		_, found, ok := app.lookupExercise(w, userID, name)
+		if !ok {
+			return
+		}
+		if found {
+			http.Error(w, fmt.Sprintf("Exercise %q already exists", database.NormalizeExerciseName(name)), http.StatusConflict)
+			return
+		}
+	}
+	exerciseID, err := app.db.CreateExercise(exercise)
+	if err != nil {
+		if strings.Contains(err.Error(), "Exercise already exists") {
+			http.Error(w, "Exercise already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Could not add exercise", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ExerciseCreateHandler: %v", err)
+		return
+	}
+	created, err := app.db.GetExerciseById(int(exerciseID))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ExerciseCreateHandler: %v", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/exercises/%d", created.Id))
+	app.writeJSON(w, http.StatusCreated, created)
+}
@@ -0,0 +1,153 @@
+package web
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// dateParamLayout is the format of the from/to query parameters.
+const dateParamLayout = "2006-01-02"
+
+// exportRow is one set as it appears in the CSV export, with its weight in the
+// export's unit. SetIndex counts sets within their workout, starting at 1.
+type exportRow struct {
+	database.ExerciseSet
+	SetIndex int
+}
+
+// exportColumns defines the CSV export, in column order. New set fields only
+// need an entry here.
+var exportColumns = []struct {
+	header string
+	value  func(exportRow) string
+}{
+	{"date", func(row exportRow) string { return row.DateTime }},
+	{"session_id", func(row exportRow) string { return strconv.Itoa(row.SessionID) }},
+	{"exercise", func(row exportRow) string { return row.ExerciseName }},
+	{"group", func(row exportRow) string {
+		if row.Group == 0 {
+			return ""
+		}
+		return strconv.Itoa(row.Group)
+	}},
+	{"set_index", func(row exportRow) string { return strconv.Itoa(row.SetIndex) }},
+	{"weight", func(row exportRow) string { return strconv.FormatFloat(row.Weight, 'f', -1, 64) }},
+	{"unit", func(row exportRow) string { return row.Unit }},
+	{"reps", func(row exportRow) string { return strconv.Itoa(row.NumberOfReps) }},
+	{"set_type", func(row exportRow) string { return row.Type }},
+	{"rpe", func(row exportRow) string {
+		if row.RPE == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*row.RPE, 'f', -1, 64)
+	}},
+	{"rir", func(row exportRow) string {
+		if row.RIR == nil {
+			return ""
+		}
+		return strconv.Itoa(*row.RIR)
+	}},
+	{"tempo", func(row exportRow) string { return row.Tempo }},
+	{"completed", func(row exportRow) string { return strconv.FormatBool(row.Completed) }},
+	{"duration_seconds", func(row exportRow) string { return strconv.Itoa(row.Duration) }},
+	{"distance_m", func(row exportRow) string { return strconv.FormatFloat(row.Distance, 'f', -1, 64) }},
+	{"calories", func(row exportRow) string { return strconv.Itoa(row.Calories) }},
+}
+
+// exportFlushEvery is how many rows are buffered before flushing to the client.
+const exportFlushEvery = 100
+
+// parseDateRange turns the optional from/to dates (inclusive, YYYY-MM-DD, in
+// loc) into the half-open UTC DateTime bounds the store's queries expect.
+func parseDateRange(r *http.Request, loc *time.Location) (string, string, error) {
+	var from, to string
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.ParseInLocation(dateParamLayout, v, loc)
+		if err != nil {
+			return "", "", err
+		}
+		from = t.UTC().Format(database.DateTimeLayout)
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.ParseInLocation(dateParamLayout, v, loc)
+		if err != nil {
+			return "", "", err
+		}
+		to = t.AddDate(0, 0, 1).UTC().Format(database.DateTimeLayout)
+	}
+	return from, to, nil
+}
+
+// Stream the user's training history as CSV, one row per set
+func (app *App) ExportCSVHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	user, ok := app.currentUser(w, userID)
+	if !ok {
+		return
+	}
+	from, to, err := parseDateRange(r, userLocation(user))
+	if err != nil {
+		http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="workouts.csv"`)
+	flusher, _ := w.(http.Flusher)
+	cw := csv.NewWriter(w)
+
+	record := make([]string, len(exportColumns))
+	for i, column := range exportColumns {
+		record[i] = column.header
+	}
+	if err := cw.Write(record); err != nil {
+		app.logger.Error().Msgf("ExportCSVHandler: %v", err)
+		return
+	}
+
+	var rows, workoutID, setIndex int
+	err = app.db.ExportSetHistory(userID, from, to, func(set database.ExerciseSet) error {
+		if set.WorkoutID != workoutID {
+			workoutID, setIndex = set.WorkoutID, 0
+		}
+		setIndex++
+		set.SetRow = inUnit(set.SetRow, unit)
+		row := exportRow{ExerciseSet: set, SetIndex: setIndex}
+		for i, column := range exportColumns {
+			record[i] = column.value(row)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			cw.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return cw.Error()
+		}
+		return nil
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	// The status line has already gone out, so a failure can only truncate
+	// the download.
+	if err != nil {
+		app.logger.Error().Msgf("ExportCSVHandler: %v", err)
+	}
+}
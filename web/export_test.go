@@ -0,0 +1,93 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	app, handler := testApp(t)
+	_, cookies := newUser(t, app, "alice@example.com")
+	if rec := call(handler, cookies, http.MethodPatch, "/me", `{"TimeZone": "America/New_York"}`); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH /me = %d %s", rec.Code, rec.Body)
+	}
+	// Session times are UTC. In New York the second is on 29 February and the
+	// third late on 2 March.
+	var sessions []int
+	for _, dateTime := range []string{"2024-03-02 15:00:00", "2024-03-01 03:00:00", "2024-03-03 04:30:00"} {
+		sessions = append(sessions, create(t, handler, cookies, "/sessions", fmt.Sprintf(`{"DateTime": %q}`, dateTime)))
+	}
+	logSets := func(session int, exercise string, weights ...int) {
+		t.Helper()
+		workout := create(t, handler, cookies, "/workouts", fmt.Sprintf(`{"SessionID": %d, "WorkoutName": %q}`, session, exercise))
+		for _, weight := range weights {
+			create(t, handler, cookies, "/sets", fmt.Sprintf(`{"WorkoutID": %d, "Weight": %d, "NumberOfReps": 5}`, workout, weight))
+		}
+	}
+	logSets(sessions[0], "bench press", 80)
+	// Added last, so listed first in its session.
+	logSets(sessions[0], "squat", 100, 110)
+	logSets(sessions[1], "deadlift", 140)
+	logSets(sessions[2], "overhead press", 50)
+
+	export := func(query string) (header []string, rows []string) {
+		t.Helper()
+		rec := call(handler, cookies, http.MethodGet, "/export.csv"+query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /export.csv%s = %d %s", query, rec.Code, rec.Body)
+		}
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		header = records[0]
+		column := map[string]int{}
+		for i, name := range header {
+			column[name] = i
+		}
+		for _, record := range records[1:] {
+			rows = append(rows, strings.Join([]string{
+				record[column["date"]],
+				record[column["session_id"]],
+				record[column["exercise"]],
+				record[column["set_index"]],
+				record[column["weight"]],
+				record[column["reps"]],
+			}, " "))
+		}
+		return header, rows
+	}
+
+	header, rows := export("")
+	want := "date,session_id,exercise,group,set_index,weight,unit,reps,set_type,rpe,rir,tempo,completed,duration_seconds,distance_m,calories"
+	if got := strings.Join(header, ","); got != want {
+		t.Errorf("header %s, want %s", got, want)
+	}
+	wantRows := []string{
+		fmt.Sprintf("2024-03-01 03:00:00 %d deadlift 1 140 5", sessions[1]),
+		fmt.Sprintf("2024-03-02 15:00:00 %d squat 1 100 5", sessions[0]),
+		fmt.Sprintf("2024-03-02 15:00:00 %d squat 2 110 5", sessions[0]),
+		fmt.Sprintf("2024-03-02 15:00:00 %d bench press 1 80 5", sessions[0]),
+		fmt.Sprintf("2024-03-03 04:30:00 %d overhead press 1 50 5", sessions[2]),
+	}
+	if strings.Join(rows, "\n") != strings.Join(wantRows, "\n") {
+		t.Errorf("rows:\n%s\nwant:\n%s", strings.Join(rows, "\n"), strings.Join(wantRows, "\n"))
+	}
+
+	// Dates are whole days in the user's time zone, both ends included.
+	_, rows = export("?from=2024-03-01&to=2024-03-02")
+	if strings.Join(rows, "\n") != strings.Join(wantRows[1:], "\n") {
+		t.Errorf("rows from 1 to 2 March:\n%s\nwant:\n%s", strings.Join(rows, "\n"), strings.Join(wantRows[1:], "\n"))
+	}
+	_, rows = export("?to=2024-02-29")
+	if strings.Join(rows, "\n") != wantRows[0] {
+		t.Errorf("rows to 29 February:\n%s\nwant:\n%s", strings.Join(rows, "\n"), wantRows[0])
+	}
+
+	if rec := call(handler, cookies, http.MethodGet, "/export.csv?from=March", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /export.csv with a bad date = %d, want 400", rec.Code)
+	}
+}
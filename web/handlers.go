@@ -2,6 +2,8 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -17,49 +19,169 @@ func HealthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 type App struct {
-	db     *database.DBConn
-	logger zerolog.Logger
+	db        database.Store
+	logger    zerolog.Logger
+	providers map[string]IdentityProvider
+	// localAccounts allows registering and signing in with a password.
+	localAccounts bool
 }
 
+// LoginInfo is the body of POST /login: a credential from the named identity
+// provider, "google" when left out.
 type LoginInfo struct {
+	Provider   string `json:"provider"`
 	Credential string `json:"credential"`
 }
 
+// PasswordLogin is the body of POST /register and POST /login/password. Name
+// is only read on registration and defaults to the start of the email.
+type PasswordLogin struct {
+	Email    string
+	Password string
+	Name     string
+}
+
+// PasswordChange is the body of PUT /me/password. A user with no password yet
+// proves who they are with a Credential from signing in to one of their
+// identity providers again, Provider defaulting to "google", instead of
+// CurrentPassword.
+type PasswordChange struct {
+	CurrentPassword string
+	NewPassword     string
+	Provider        string
+	Credential      string
+}
+
+// Claims are the claims of an access token. LoginID is the refresh token
+// family the token was issued for; the token stops working once that login is
+// signed out.
 type Claims struct {
-	UserID string `json:"userId"`
+	UserID  string `json:"userId"`
+	LoginID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// Get Sessions based on userID (restrict to 10 in the future maybe)
+// Get a page of the user's sessions, newest first, optionally limited to a
+// date range or to sessions containing an exercise
 func (app *App) SessionListHandler(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := r.Context().Value(contextKeyUserID).(string)
-	if !ok {
+	userID, err := getUserID(r)
+	if err != nil {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
-	userID, err := strconv.Atoi(userIDStr)
+	user, ok := app.currentUser(w, userID)
+	if !ok {
+		return
+	}
+	filter, ok := app.sessionFilter(w, r, user)
+	if !ok {
+		return
+	}
+	unit, ok := unitOverride(w, r, user.Unit)
+	if !ok {
+		return
+	}
+	total, err := app.db.CountSessions(userID, filter)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionHandler: %v", err)
 		return
 	}
-	sessions, err := app.db.GetSessionsByUserId(userID)
+	// One extra session tells whether there is a next page.
+	limit := filter.Limit
+	filter.Limit++
+	sessions, err := app.db.GetSessionsByUserId(userID, filter)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		app.logger.Error().Msgf("SessionHandler: %v", err)
 		return
 	}
-	body, err := json.Marshal(sessions)
+	more := len(sessions) > limit
+	if more {
+		sessions = sessions[:limit]
+	}
+	ids := make([]int, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.Id
+	}
+	volumes, err := app.db.GetSessionVolumes(ids)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		app.logger.Error().Msgf("SessionHandler: %v", err)
 		return
 	}
-	w.Write(body)
+	var summaries []SessionSummary
+	for _, session := range sessions {
+		summaries = append(summaries, SessionSummary{
+			SessionView: displaySession(session, unit),
+			Duration:    sessionDuration(session.Session),
+			Volume:      round2(database.WeightFromKg(volumes[session.Id], unit)),
+		})
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if more {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", encodeSessionCursor(sessions[len(sessions)-1]))
+		query.Set("limit", strconv.Itoa(limit))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+	app.writeJSON(w, http.StatusOK, summaries)
+}
+
+// Get a session with its workouts in order, each with its sets
+func (app *App) SessionGetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	sessionID, err := urlParamID(r, "sessionID")
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeSession(w, sessionID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	session, err := app.db.GetSessionById(sessionID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionGetHandler: %v", err)
+		return
+	}
+	workouts, ok := app.sessionWorkouts(w, sessionID, unit)
+	if !ok {
+		return
+	}
+	volumes, err := app.db.GetSessionVolumes([]int{sessionID})
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionGetHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, SessionDetail{
+		SessionSummary: SessionSummary{
+			SessionView: displaySession(session, unit),
+			Duration:    sessionDuration(session.Session),
+			Volume:      round2(database.WeightFromKg(volumes[sessionID], unit)),
+		},
+		Workouts: workouts,
+	})
 }
 
 // Get Workouts based on sessionId
 func (app *App) WorkoutListHandler(w http.ResponseWriter, r *http.Request) {
-	workoutResponse := []Workout{}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
 	sessionIDstr := chi.URLParam(r, "sessionID")
 	if len(sessionIDstr) < 1 {
 		http.Error(w, "Invalid session ID", http.StatusBadRequest)
@@ -70,23 +192,16 @@ func (app *App) WorkoutListHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid session ID", http.StatusBadRequest)
 		return
 	}
-	workouts, err := app.db.GetWorkoutsBySessionId(sessionID)
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		app.logger.Error().Msgf("WorkoutHandler: %v", err)
+	if !app.authorizeSession(w, sessionID, userID) {
 		return
 	}
-	for _, workout := range workouts {
-		sets, err := app.db.GetSetsByWorkoutId(workout.Id)
-		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			app.logger.Error().Msgf("WorkoutHandler: %v", err)
-			return
-		}
-		if len(sets) == 0 {
-			sets = []database.SetRow{}
-		}
-		workoutResponse = append(workoutResponse, Workout{WorkoutRow: workout, Sets: sets})
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	workoutResponse, ok := app.sessionWorkouts(w, sessionID, unit)
+	if !ok {
+		return
 	}
 	body, err := json.Marshal(workoutResponse)
 	if err != nil {
@@ -97,8 +212,39 @@ func (app *App) WorkoutListHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
+// sessionWorkouts returns the session's workouts in order, each with its sets
+// in unit. It writes the error response and returns false on failure.
+func (app *App) sessionWorkouts(w http.ResponseWriter, sessionID int, unit string) ([]Workout, bool) {
+	workouts, err := app.db.GetWorkoutsBySessionId(sessionID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("sessionWorkouts: %v", err)
+		return nil, false
+	}
+	sets, err := app.db.GetSetsBySessionId(sessionID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("sessionWorkouts: %v", err)
+		return nil, false
+	}
+	byWorkout := map[int][]database.SetRow{}
+	for _, set := range sets {
+		byWorkout[set.WorkoutID] = append(byWorkout[set.WorkoutID], set)
+	}
+	response := []Workout{}
+	for _, workout := range workouts {
+		response = append(response, Workout{WorkoutRow: workout, Sets: displaySets(byWorkout[workout.Id], unit)})
+	}
+	return response, true
+}
+
 // Get sets based on workoutID
 func (app *App) SetListHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
 	workoutIDstr := chi.URLParam(r, "workoutID")
 	if len(workoutIDstr) < 1 {
 		http.Error(w, "Invalid workout ID", http.StatusBadRequest)
@@ -109,13 +255,24 @@ func (app *App) SetListHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid workout ID", http.StatusBadRequest)
 		return
 	}
+	if !app.authorizeWorkout(w, workoutID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
 	sets, err := app.db.GetSetsByWorkoutId(workoutID)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		app.logger.Error().Msgf("SetListHandler: %v", err)
 		return
 	}
-	body, err := json.Marshal(sets)
+	var views []SetView
+	if sets != nil {
+		views = displaySets(sets, unit)
+	}
+	body, err := json.Marshal(views)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		app.logger.Error().Msgf("SetListHandler: %v", err)
@@ -125,12 +282,7 @@ func (app *App) SetListHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *App) WorkoutCreateHandler(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := r.Context().Value(contextKeyUserID).(string)
-	if !ok {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-	userID, err := strconv.Atoi(userIDStr)
+	userID, err := getUserID(r)
 	if err != nil {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
@@ -141,7 +293,15 @@ func (app *App) WorkoutCreateHandler(w http.ResponseWriter, r *http.Request) {
 		app.logger.Error().Msgf("WorkoutCreateHandler: %v", err)
 		return
 	}
-	if err := app.db.CreateWorkoutForSession(workout.SessionID, strings.ToLower(workout.WorkoutName), userID); err != nil {
+	if !app.authorizeSession(w, workout.SessionID, userID) {
+		return
+	}
+	exercise, ok := app.resolveExercise(w, userID, workout.ExerciseID, workout.WorkoutName)
+	if !ok {
+		return
+	}
+	workoutID, err := app.db.CreateWorkoutForSession(workout.SessionID, exercise.Id, exercise.Name, userID)
+	if err != nil {
 		if strings.Contains(err.Error(), "Workout already exists") {
 			http.Error(w, "Workout already exists", http.StatusConflict)
 			return
@@ -150,48 +310,126 @@ func (app *App) WorkoutCreateHandler(w http.ResponseWriter, r *http.Request) {
 		app.logger.Error().Msgf("WorkoutCreateHandler: %v", err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	created, err := app.db.GetWorkoutById(int(workoutID))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("WorkoutCreateHandler: %v", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/workouts/%d", created.Id))
+	app.writeJSON(w, http.StatusCreated, created)
 }
 
 func (app *App) SetCreateHandler(w http.ResponseWriter, r *http.Request) {
-	var set database.Set
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	set := database.Set{Type: database.SetTypeWorking, Completed: true}
 	if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
 		app.logger.Error().Msgf("%v", err)
 		http.Error(w, "Could not decode set", http.StatusBadRequest)
 		return
 
 	}
-	if err := app.db.CreateSetForWorkout(set.WorkoutID, set.NumberOfReps, set.Weight); err != nil {
+	if !app.authorizeWorkout(w, set.WorkoutID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	// Weight is given in the set's unit, or the display unit when omitted.
+	if set.Unit == "" {
+		set.Unit = unit
+	}
+	set.Tempo = strings.ToUpper(strings.TrimSpace(set.Tempo))
+	if err := validateSet(set); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	exercise, ok := app.workoutExercise(w, set.WorkoutID)
+	if !ok {
+		return
+	}
+	if err := checkSetForExercise(set, exercise); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	set.Weight = database.WeightToKg(set.Weight, set.Unit)
+	setID, err := app.db.CreateSetForWorkout(set)
+	if err != nil {
 		app.logger.Error().Msgf("%v", err)
 		http.Error(w, "Could not add set to workout", http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	created, err := app.db.GetSetById(int(setID))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SetCreateHandler: %v", err)
+		return
+	}
+	history, err := app.db.GetSetHistoryForExercise(exercise.Id, userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SetCreateHandler: %v", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/sets/%d", created.Id))
+	app.writeJSON(w, http.StatusCreated, SetCreateResponse{
+		SetView: displaySet(created, unit),
+		Records: recordsForSet(displayHistory(history, unit), created.Id),
+	})
 }
 
 func (app *App) SessionCreateHandler(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := r.Context().Value(contextKeyUserID).(string)
-	if !ok {
+	userID, err := getUserID(r)
+	if err != nil {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	// The body is optional; without one the session starts now in the
+	// user's time zone.
+	var update SessionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil && err != io.EOF {
+		http.Error(w, "Could not decode session", http.StatusBadRequest)
+		return
+	}
+	user, ok := app.currentUser(w, userID)
+	if !ok {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
 		return
 	}
-	if err := app.db.CreateSessionForUser(userID); err != nil {
+	session := database.Session{UserID: userID, DateTime: database.NowDateTime(), TimeZone: user.TimeZone}
+	applySessionUpdate(&session, update, unit, false)
+	if err := validateSession(session); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sessionID, err := app.db.CreateSessionForUser(session)
+	if err != nil {
 		app.logger.Error().Msgf("%v", err)
 		http.Error(w, "Could not add session", http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	created, err := app.db.GetSessionById(int(sessionID))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionCreateHandler: %v", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/sessions/%d", created.Id))
+	app.writeJSON(w, http.StatusCreated, displaySession(created, unit))
 }
 
 // Get Sessions based on userID (restrict to 10 in the future maybe)
 func (app *App) LastWorkoutHandler(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := r.Context().Value(contextKeyUserID).(string)
-	if !ok {
+	userID, err := getUserID(r)
+	if err != nil {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
@@ -200,25 +438,34 @@ func (app *App) LastWorkoutHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid workout name", http.StatusBadRequest)
 		return
 	}
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
 		return
 	}
-	workoutID, err := app.db.GetLastWorkoutID(workoutName, userID)
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		app.logger.Error().Msgf("LastWorkoutHandler: %v", err)
+	exercise, found, ok := app.lookupExercise(w, userID, workoutName)
+	if !ok {
 		return
 	}
-	var lastWorkoutDetails []database.SetRow
+	var workoutID int
+	if found {
+		workoutID, err = app.db.GetLastWorkoutID(exercise.Id, userID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("LastWorkoutHandler: %v", err)
+			return
+		}
+	}
+	var lastWorkoutDetails []SetView
 	if workoutID > 0 {
-		lastWorkoutDetails, err = app.db.GetSetsByWorkoutId(workoutID)
+		sets, err := app.db.GetSetsByWorkoutId(workoutID)
 		if err != nil {
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			app.logger.Error().Msgf("LastWorkoutHandler: %v", err)
 			return
 		}
+		if sets != nil {
+			lastWorkoutDetails = displaySets(sets, unit)
+		}
 	}
 	body, err := json.Marshal(lastWorkoutDetails)
 	if err != nil {
@@ -228,3 +475,399 @@ func (app *App) LastWorkoutHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Write(body)
 }
+
+// urlParamID parses a positive integer ID from the named URL parameter.
+func urlParamID(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil {
+		return 0, err
+	}
+	if id < 1 {
+		return 0, fmt.Errorf("invalid id %d", id)
+	}
+	return id, nil
+}
+
+// writeJSON marshals v and writes it with the given status code.
+func (app *App) writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("writeJSON: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (app *App) SessionUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	sessionID, err := urlParamID(r, "sessionID")
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeSession(w, sessionID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	var update SessionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Could not decode session", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && update.DateTime == nil {
+		http.Error(w, "DateTime is required", http.StatusBadRequest)
+		return
+	}
+	session, err := app.db.GetSessionById(sessionID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionUpdateHandler: %v", err)
+		return
+	}
+	applySessionUpdate(&session.Session, update, unit, r.Method == http.MethodPut)
+	if err := validateSession(session.Session); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := app.db.UpdateSession(sessionID, session.Session); err != nil {
+		http.Error(w, "Could not update session", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionUpdateHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, displaySession(session, unit))
+}
+
+// Mark a session as finished now
+func (app *App) SessionFinishHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	sessionID, err := urlParamID(r, "sessionID")
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeSession(w, sessionID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	session, err := app.db.GetSessionById(sessionID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionFinishHandler: %v", err)
+		return
+	}
+	if session.EndDateTime != "" {
+		http.Error(w, "Session already finished", http.StatusConflict)
+		return
+	}
+	session.EndDateTime = database.NowDateTime()
+	if session.EndDateTime < session.DateTime {
+		http.Error(w, "Session has not started yet", http.StatusConflict)
+		return
+	}
+	if err := app.db.UpdateSession(sessionID, session.Session); err != nil {
+		http.Error(w, "Could not finish session", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionFinishHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, displaySession(session, unit))
+}
+
+func (app *App) SessionDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	sessionID, err := urlParamID(r, "sessionID")
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeSession(w, sessionID, userID) {
+		return
+	}
+	if err := app.db.DeleteSession(sessionID); err != nil {
+		http.Error(w, "Could not delete session", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionDeleteHandler: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Reorder a session's workouts and group them into supersets or circuits
+func (app *App) SessionLayoutHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	sessionID, err := urlParamID(r, "sessionID")
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeSession(w, sessionID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	var layout WorkoutLayout
+	if err := json.NewDecoder(r.Body).Decode(&layout); err != nil {
+		http.Error(w, "Could not decode layout", http.StatusBadRequest)
+		return
+	}
+	workouts, err := app.db.GetWorkoutsBySessionId(sessionID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionLayoutHandler: %v", err)
+		return
+	}
+	// The layout must place every workout of the session exactly once.
+	remaining := map[int]bool{}
+	for _, workout := range workouts {
+		remaining[workout.Id] = true
+	}
+	for _, group := range layout.Groups {
+		if len(group) == 0 {
+			http.Error(w, "Invalid layout: empty group", http.StatusBadRequest)
+			return
+		}
+		for _, workoutID := range group {
+			if !remaining[workoutID] {
+				http.Error(w, fmt.Sprintf("Invalid layout: workout %d is not in the session or is listed twice", workoutID), http.StatusBadRequest)
+				return
+			}
+			delete(remaining, workoutID)
+		}
+	}
+	if len(remaining) > 0 {
+		http.Error(w, "Invalid layout: every workout of the session must be listed", http.StatusBadRequest)
+		return
+	}
+	if err := app.db.ArrangeWorkouts(sessionID, layout.Groups); err != nil {
+		http.Error(w, "Could not update layout", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SessionLayoutHandler: %v", err)
+		return
+	}
+	arranged, ok := app.sessionWorkouts(w, sessionID, unit)
+	if !ok {
+		return
+	}
+	app.writeJSON(w, http.StatusOK, arranged)
+}
+
+func (app *App) WorkoutUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	workoutID, err := urlParamID(r, "workoutID")
+	if err != nil {
+		http.Error(w, "Invalid workout ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeWorkout(w, workoutID, userID) {
+		return
+	}
+	var update WorkoutUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Could not decode workout", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && update.WorkoutName == nil && update.ExerciseID == nil {
+		http.Error(w, "ExerciseID or WorkoutName is required", http.StatusBadRequest)
+		return
+	}
+	workout, err := app.db.GetWorkoutById(workoutID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("WorkoutUpdateHandler: %v", err)
+		return
+	}
+	if update.WorkoutName != nil || update.ExerciseID != nil {
+		var exerciseID int
+		var name string
+		if update.ExerciseID != nil {
+			exerciseID = *update.ExerciseID
+		} else {
+			name = *update.WorkoutName
+		}
+		exercise, ok := app.resolveExercise(w, userID, exerciseID, name)
+		if !ok {
+			return
+		}
+		workout.ExerciseID = exercise.Id
+		workout.WorkoutName = exercise.Name
+	}
+	if err := app.db.UpdateWorkout(workoutID, workout.ExerciseID, workout.WorkoutName); err != nil {
+		if strings.Contains(err.Error(), "Workout already exists") {
+			http.Error(w, "Workout already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Could not update workout", http.StatusInternalServerError)
+		app.logger.Error().Msgf("WorkoutUpdateHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, workout)
+}
+
+func (app *App) WorkoutDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	workoutID, err := urlParamID(r, "workoutID")
+	if err != nil {
+		http.Error(w, "Invalid workout ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeWorkout(w, workoutID, userID) {
+		return
+	}
+	if err := app.db.DeleteWorkout(workoutID); err != nil {
+		http.Error(w, "Could not delete workout", http.StatusInternalServerError)
+		app.logger.Error().Msgf("WorkoutDeleteHandler: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *App) SetUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	setID, err := urlParamID(r, "setID")
+	if err != nil {
+		http.Error(w, "Invalid set ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeSet(w, setID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	var update SetUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Could not decode set", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && (update.Weight == nil || update.NumberOfReps == nil) {
+		http.Error(w, "Weight and NumberOfReps are required", http.StatusBadRequest)
+		return
+	}
+	set, err := app.db.GetSetById(setID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SetUpdateHandler: %v", err)
+		return
+	}
+	// PUT replaces the whole set, so details it leaves out are cleared.
+	if r.Method == http.MethodPut {
+		set.RPE, set.RIR, set.Type, set.Tempo, set.Completed = nil, nil, database.SetTypeWorking, "", true
+		set.Duration, set.Distance, set.Calories = 0, 0, 0
+	}
+	// A new weight is given in the update's unit, or the display unit when
+	// omitted, and becomes the set's entry unit. Changing only the unit
+	// keeps the weight and records the set as entered in that unit.
+	if update.Weight != nil {
+		set.Unit = unit
+		if update.Unit != nil {
+			set.Unit = *update.Unit
+		}
+		set.Weight = database.WeightToKg(*update.Weight, set.Unit)
+	} else if update.Unit != nil {
+		set.Unit = *update.Unit
+	}
+	if update.NumberOfReps != nil {
+		set.NumberOfReps = *update.NumberOfReps
+	}
+	if update.RPE != nil {
+		set.RPE = update.RPE
+	}
+	if update.RIR != nil {
+		set.RIR = update.RIR
+	}
+	if update.Type != nil {
+		set.Type = *update.Type
+	}
+	if update.Tempo != nil {
+		set.Tempo = strings.ToUpper(strings.TrimSpace(*update.Tempo))
+	}
+	if update.Completed != nil {
+		set.Completed = *update.Completed
+	}
+	if update.Duration != nil {
+		set.Duration = *update.Duration
+	}
+	if update.Distance != nil {
+		set.Distance = *update.Distance
+	}
+	if update.Calories != nil {
+		set.Calories = *update.Calories
+	}
+	if err := validateSet(set.Set); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	exercise, ok := app.workoutExercise(w, set.WorkoutID)
+	if !ok {
+		return
+	}
+	if err := checkSetForExercise(set.Set, exercise); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := app.db.UpdateSet(setID, set.Set); err != nil {
+		http.Error(w, "Could not update set", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SetUpdateHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, displaySet(set, unit))
+}
+
+func (app *App) SetDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	setID, err := urlParamID(r, "setID")
+	if err != nil {
+		http.Error(w, "Invalid set ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeSet(w, setID, userID) {
+		return
+	}
+	if err := app.db.DeleteSet(setID); err != nil {
+		http.Error(w, "Could not delete set", http.StatusInternalServerError)
+		app.logger.Error().Msgf("SetDeleteHandler: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,551 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// maxImportSize caps the size of an uploaded CSV file.
+const maxImportSize = 32 << 20
+
+// importRow is one set read from another app's export.
+type importRow struct {
+	line int
+	// dateTime and endDateTime are wall-clock times in the user's time
+	// zone; endDateTime is empty when the app does not record it.
+	dateTime    string
+	endDateTime string
+	title       string
+	exercise    string
+	weight      float64
+	unit        string
+	reps        int
+	setType     string
+	rpe         *float64
+	duration    int
+	// distance is in distanceUnit, or the user's distance unit when that
+	// is empty.
+	distance     float64
+	distanceUnit string
+	// superset identifies the row's superset within its session in the
+	// app's own terms, empty when the exercise was done on its own.
+	superset string
+}
+
+// set is the set the row records, with its weight in kilograms and its
+// distance in metres.
+func (row importRow) set() database.Set {
+	return database.Set{
+		Weight:       database.WeightToKg(row.weight, row.unit),
+		NumberOfReps: row.reps,
+		Unit:         row.unit,
+		RPE:          row.rpe,
+		Type:         row.setType,
+		Completed:    true,
+		Duration:     row.duration,
+		Distance:     row.distance * metresPer[row.distanceUnit],
+	}
+}
+
+// importRecord gives access to a CSV record by (lower-cased) column name.
+type importRecord struct {
+	columns map[string]int
+	fields  []string
+}
+
+// get returns the first of the named columns present in the file.
+func (r importRecord) get(names ...string) string {
+	for _, name := range names {
+		if i, ok := r.columns[name]; ok && i < len(r.fields) {
+			return strings.TrimSpace(r.fields[i])
+		}
+	}
+	return ""
+}
+
+// weightColumns maps the weight columns of the supported exports to the unit
+// they are in. A bare "weight" column is taken to be in the user's unit.
+var weightColumns = map[string]string{
+	"weight":       "",
+	"weight (kg)":  database.UnitKg,
+	"weight (kgs)": database.UnitKg,
+	"weight_kg":    database.UnitKg,
+	"weight (lbs)": database.UnitLb,
+	"weight_lbs":   database.UnitLb,
+}
+
+// weight returns the first of the named weight columns present in the file,
+// and its unit.
+func (r importRecord) weight(names ...string) (string, string) {
+	for _, name := range names {
+		if i, ok := r.columns[name]; ok && i < len(r.fields) {
+			return strings.TrimSpace(r.fields[i]), weightColumns[name]
+		}
+	}
+	return "", ""
+}
+
+// importFormat describes the CSV export of one app. A file is recognised by
+// having all of the required columns.
+type importFormat struct {
+	name     string
+	required []string
+	parse    func(r importRecord) (importRow, error)
+}
+
+var importFormats = []importFormat{
+	{
+		name:     "strong",
+		required: []string{"date", "exercise name", "set order", "reps"},
+		parse: func(r importRecord) (importRow, error) {
+			// Strong writes rest timers and notes as extra rows; only
+			// numbered sets and warm-up/drop/failure markers are sets.
+			setType := database.SetTypeWorking
+			switch order := r.get("set order"); order {
+			case "W":
+				setType = database.SetTypeWarmUp
+			case "D":
+				setType = database.SetTypeDrop
+			case "F":
+				setType = database.SetTypeFailure
+			default:
+				if _, err := strconv.Atoi(order); err != nil {
+					return importRow{}, fmt.Errorf("not a set (set order %q)", order)
+				}
+			}
+			dateTime, err := parseImportTime(r.get("date"), "2006-01-02 15:04:05", "2006-01-02 15:04")
+			if err != nil {
+				return importRow{}, err
+			}
+			weight, unit := r.weight("weight", "weight (kg)", "weight (lbs)")
+			row, err := importSet(dateTime, r.get("workout name"), r.get("exercise name"), weight, unit, r.get("reps"))
+			if err != nil {
+				return row, err
+			}
+			row.setType = setType
+			if row.rpe, err = parseImportRPE(r.get("rpe")); err != nil {
+				return row, err
+			}
+			return importCardio(row, r.get("seconds"), r.get("distance"), "")
+		},
+	},
+	{
+		name:     "hevy",
+		required: []string{"start_time", "exercise_title", "reps"},
+		parse: func(r importRecord) (importRow, error) {
+			dateTime, err := parseImportTime(r.get("start_time"), "2 Jan 2006, 15:04", "2006-01-02 15:04:05", time.RFC3339)
+			if err != nil {
+				return importRow{}, err
+			}
+			weight, unit := r.weight("weight_kg", "weight_lbs")
+			row, err := importSet(dateTime, r.get("title"), r.get("exercise_title"), weight, unit, r.get("reps"))
+			if err != nil {
+				return row, err
+			}
+			if setType, ok := hevySetTypes[r.get("set_type")]; ok {
+				row.setType = setType
+			}
+			if row.rpe, err = parseImportRPE(r.get("rpe")); err != nil {
+				return row, err
+			}
+			row.superset = r.get("superset_id")
+			if end, err := parseImportTime(r.get("end_time"), "2 Jan 2006, 15:04", "2006-01-02 15:04:05", time.RFC3339); err == nil {
+				row.endDateTime = end
+			}
+			return importCardio(row, r.get("duration_seconds"), r.get("distance_km"), "km")
+		},
+	},
+	{
+		name:     "fitnotes",
+		required: []string{"date", "exercise", "reps"},
+		parse: func(r importRecord) (importRow, error) {
+			// FitNotes only records the day, so each day is one session.
+			dateTime, err := parseImportTime(r.get("date"), "2006-01-02")
+			if err != nil {
+				return importRow{}, err
+			}
+			weight, unit := r.weight("weight (kgs)", "weight (lbs)", "weight")
+			row, err := importSet(dateTime, "", r.get("exercise"), weight, unit, r.get("reps"))
+			if err != nil {
+				return row, err
+			}
+			return importCardio(row, r.get("time"), r.get("distance"), strings.ToLower(r.get("distance unit")))
+		},
+	},
+}
+
+// hevySetTypes maps Hevy's set_type column to set types.
+var hevySetTypes = map[string]string{
+	"warmup":  database.SetTypeWarmUp,
+	"normal":  database.SetTypeWorking,
+	"dropset": database.SetTypeDrop,
+	"failure": database.SetTypeFailure,
+}
+
+// importUTC moves a wall-clock time read by parseImportTime from loc to UTC.
+func importUTC(dateTime string, loc *time.Location) string {
+	t, err := time.ParseInLocation(database.DateTimeLayout, dateTime, loc)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(database.DateTimeLayout)
+}
+
+func parseImportTime(value string, layouts ...string) (string, error) {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(database.DateTimeLayout), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q", value)
+}
+
+// parseImportNumber reads a number that may use a decimal comma. Empty
+// values are zero.
+func parseImportNumber(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if !strings.Contains(value, ".") {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// parseImportRPE reads an optional RPE; empty values are not recorded.
+func parseImportRPE(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	rpe, err := parseImportNumber(value)
+	if err != nil || !validRPE(rpe) {
+		return nil, fmt.Errorf("invalid RPE %q", value)
+	}
+	return &rpe, nil
+}
+
+func importSet(dateTime string, title string, exercise string, weight string, unit string, reps string) (importRow, error) {
+	row := importRow{dateTime: dateTime, title: title, exercise: exercise, unit: unit, setType: database.SetTypeWorking}
+	if database.NormalizeExerciseName(exercise) == "" {
+		return row, fmt.Errorf("missing exercise name")
+	}
+	w, err := parseImportNumber(weight)
+	if err != nil || w < 0 {
+		return row, fmt.Errorf("invalid weight %q", weight)
+	}
+	n, err := parseImportNumber(reps)
+	if err != nil || n != math.Trunc(n) || n < 0 {
+		return row, fmt.Errorf("invalid reps %q", reps)
+	}
+	row.weight = w
+	row.reps = int(n)
+	return row, nil
+}
+
+// metresPer converts the distance units of the supported exports to metres.
+var metresPer = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"mi": 1609.344,
+	"ft": 0.3048,
+	"yd": 0.9144,
+}
+
+// parseImportDuration reads a duration given in seconds or as m:ss or
+// h:mm:ss.
+func parseImportDuration(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) == 1 {
+		d, err := parseImportNumber(value)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return int(math.Round(d)), nil
+	}
+	seconds := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || len(parts) > 3 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, nil
+}
+
+// importCardio reads a set's duration and distance.
+func importCardio(row importRow, duration string, distance string, distanceUnit string) (importRow, error) {
+	var err error
+	if row.duration, err = parseImportDuration(duration); err != nil {
+		return row, err
+	}
+	d, err := parseImportNumber(distance)
+	if err != nil || d < 0 {
+		return row, fmt.Errorf("invalid distance %q", distance)
+	}
+	if _, ok := metresPer[distanceUnit]; !ok && distanceUnit != "" {
+		return row, fmt.Errorf("unknown distance unit %q", distanceUnit)
+	}
+	row.distance, row.distanceUnit = d, distanceUnit
+	return row, nil
+}
+
+// detectImportFormat picks the format named by the format query parameter,
+// or the first whose required columns are all in the header.
+func detectImportFormat(name string, columns map[string]int) (importFormat, error) {
+	for _, format := range importFormats {
+		if name != "" && format.name != name {
+			continue
+		}
+		matches := true
+		for _, column := range format.required {
+			if _, ok := columns[column]; !ok {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return format, nil
+		}
+		if name != "" {
+			return format, fmt.Errorf("file is missing the columns of a %s export", name)
+		}
+	}
+	if name != "" {
+		return importFormat{}, fmt.Errorf("unknown format %q", name)
+	}
+	return importFormat{}, errors.New("unrecognised CSV export, expected Strong, Hevy or FitNotes")
+}
+
+// importReader opens the uploaded file, which is either the request body or
+// a "file" field of a multipart form.
+func importReader(r *http.Request) (io.Reader, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+	return r.Body, nil
+}
+
+// splitEquipment splits names like "bench press (barbell)", the style Strong
+// and Hevy use, into the exercise and its equipment.
+func splitEquipment(name string) (string, string, bool) {
+	base, equipment, ok := strings.Cut(name, " (")
+	if !ok || !strings.HasSuffix(equipment, ")") {
+		return name, "", false
+	}
+	return base, strings.TrimSuffix(equipment, ")"), true
+}
+
+// resolveImportedExercise maps an exercise name from another app onto the
+// catalog. "Bench Press (Dumbbell)" is tried as "dumbbell bench press", and
+// "Bench Press (Barbell)" as "bench press" when that uses a barbell, before
+// falling back to the name as written.
+func (app *App) resolveImportedExercise(userID int, name string) (database.ExerciseRow, error) {
+	name = database.NormalizeExerciseName(name)
+	if base, equipment, ok := splitEquipment(name); ok {
+		exercise, err := app.db.FindExercise(equipment+" "+base, userID)
+		if err == nil {
+			return exercise, nil
+		}
+		if !errors.Is(err, database.ErrNotFound) {
+			return exercise, err
+		}
+		exercise, err = app.db.FindExercise(base, userID)
+		if err == nil && exercise.Equipment == equipment {
+			return exercise, nil
+		}
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return exercise, err
+		}
+	}
+	return app.findOrCreateExercise(userID, name)
+}
+
+// Import training history from a Strong, Hevy or FitNotes CSV export
+func (app *App) ImportCSVHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	body, err := importReader(r)
+	if err != nil {
+		http.Error(w, "Could not read upload", http.StatusBadRequest)
+		return
+	}
+
+	// Strong has exported with both commas and semicolons.
+	br := bufio.NewReader(body)
+	peek, _ := br.Peek(4096)
+	firstLine, _, _ := bytes.Cut(peek, []byte("\n"))
+	cr := csv.NewReader(br)
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		http.Error(w, "Could not read CSV header", http.StatusBadRequest)
+		return
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if _, ok := columns[column]; !ok {
+			columns[column] = i
+		}
+	}
+	format, err := detectImportFormat(strings.ToLower(r.URL.Query().Get("format")), columns)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, ok := app.currentUser(w, userID)
+	if !ok {
+		return
+	}
+	preferred, loc := user.Unit, userLocation(user)
+
+	report := ImportReport{Format: format.name, Errors: []ImportRowError{}}
+	var rows []importRow
+	for {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				http.Error(w, "Could not read CSV", http.StatusBadRequest)
+				return
+			}
+			report.Errors = append(report.Errors, ImportRowError{Row: parseErr.StartLine, Message: parseErr.Err.Error()})
+			continue
+		}
+		report.Rows++
+		row, err := format.parse(importRecord{columns: columns, fields: fields})
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{Row: line, Message: err.Error()})
+			continue
+		}
+		if row.reps == 0 && row.duration == 0 && row.distance == 0 {
+			report.Errors = append(report.Errors, ImportRowError{Row: line, Message: "set has no reps, duration or distance"})
+			continue
+		}
+		row.line = line
+		if row.unit == "" {
+			row.unit = preferred
+		}
+		if row.distanceUnit == "" {
+			// Apps that don't say follow the user's units.
+			row.distanceUnit = "km"
+			if preferred == database.UnitLb {
+				row.distanceUnit = "mi"
+			}
+		}
+		if err := validateSet(row.set()); err != nil {
+			report.Errors = append(report.Errors, ImportRowError{Row: line, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	// Group sets into sessions and workouts in file order. A set's import key
+	// is its position among the sets of the same exercise in the session, so
+	// the same file always produces the same keys.
+	var sessions []*database.ImportedSession
+	sessionIndex := map[string]*database.ImportedSession{}
+	workoutIndex := map[string]int{}
+	supersets := map[string]int{}
+	sessionSupersets := map[string]int{}
+	ordinals := map[string]int{}
+	exercises := map[string]database.ExerciseRow{}
+	for _, row := range rows {
+		sessionKey := format.name + "|" + row.dateTime + "|" + row.title
+		session, ok := sessionIndex[sessionKey]
+		if !ok {
+			session = &database.ImportedSession{
+				ImportKey: sessionKey,
+				DateTime:  importUTC(row.dateTime, loc),
+				TimeZone:  user.TimeZone,
+				Title:     row.title,
+			}
+			if row.endDateTime >= row.dateTime {
+				session.EndDateTime = importUTC(row.endDateTime, loc)
+			}
+			sessionIndex[sessionKey] = session
+			sessions = append(sessions, session)
+		}
+
+		name := database.NormalizeExerciseName(row.exercise)
+		exercise, ok := exercises[name]
+		if !ok {
+			exercise, err = app.resolveImportedExercise(userID, name)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				app.logger.Error().Msgf("ImportCSVHandler: row %d: %v", row.line, err)
+				return
+			}
+			exercises[name] = exercise
+		}
+
+		// Workouts are keyed by exercise like the session's workouts, so an
+		// alias joins the workout of the exercise it names.
+		workoutKey := fmt.Sprintf("%s|%d", sessionKey, exercise.Id)
+		i, ok := workoutIndex[workoutKey]
+		if !ok {
+			// Supersets are numbered within the session as they first appear.
+			group := 0
+			if row.superset != "" {
+				supersetKey := sessionKey + "|" + row.superset
+				if group, ok = supersets[supersetKey]; !ok {
+					sessionSupersets[sessionKey]++
+					group = sessionSupersets[sessionKey]
+					supersets[supersetKey] = group
+				}
+			}
+			session.Workouts = append(session.Workouts, database.ImportedWorkout{ExerciseID: exercise.Id, WorkoutName: exercise.Name, Group: group})
+			i = len(session.Workouts) - 1
+			workoutIndex[workoutKey] = i
+		}
+		ordinals[sessionKey+"|"+name]++
+		session.Workouts[i].Sets = append(session.Workouts[i].Sets, database.ImportedSet{
+			ImportKey: fmt.Sprintf("%s|%d", name, ordinals[sessionKey+"|"+name]),
+			Set:       row.set(),
+		})
+	}
+
+	for _, session := range sessions {
+		result, err := app.db.ImportSession(userID, *session)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("ImportCSVHandler: %v", err)
+			return
+		}
+		if result.SessionCreated {
+			report.SessionsCreated++
+		}
+		report.SetsCreated += result.SetsCreated
+		report.SetsSkipped += result.SetsSkipped
+	}
+	app.writeJSON(w, http.StatusOK, report)
+}
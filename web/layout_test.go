@@ -0,0 +1,72 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSessionLayoutListsEveryWorkoutOnce(t *testing.T) {
+	app, handler := testApp(t)
+	_, cookies := newUser(t, app, "alice@example.com")
+	_, bobCookies := newUser(t, app, "bob@example.com")
+	session := create(t, handler, cookies, "/sessions", `{}`)
+	var workouts []int
+	for _, exercise := range []string{"squat", "bench press", "deadlift"} {
+		workouts = append(workouts, create(t, handler, cookies, "/workouts", fmt.Sprintf(`{"SessionID": %d, "WorkoutName": %q}`, session, exercise)))
+	}
+	otherSession := create(t, handler, cookies, "/sessions", `{}`)
+	otherWorkout := create(t, handler, cookies, "/workouts", fmt.Sprintf(`{"SessionID": %d, "WorkoutName": "squat"}`, otherSession))
+	bobWorkout := seedOwnedData(t, handler, bobCookies, "bob").workout
+
+	path := fmt.Sprintf("/sessions/%d/layout", session)
+	a, b, c := workouts[0], workouts[1], workouts[2]
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing a workout", fmt.Sprintf(`{"Groups": [[%d, %d]]}`, a, b)},
+		{"no workouts", `{"Groups": []}`},
+		{"listed twice", fmt.Sprintf(`{"Groups": [[%d, %d], [%d], [%d]]}`, a, b, c, a)},
+		{"empty group", fmt.Sprintf(`{"Groups": [[%d], [], [%d, %d]]}`, a, b, c)},
+		{"workout of another session", fmt.Sprintf(`{"Groups": [[%d], [%d], [%d], [%d]]}`, a, b, c, otherWorkout)},
+		{"workout of another user", fmt.Sprintf(`{"Groups": [[%d], [%d], [%d], [%d]]}`, a, b, c, bobWorkout)},
+		{"another user's workout in place of one", fmt.Sprintf(`{"Groups": [[%d, %d], [%d]]}`, a, b, bobWorkout)},
+	}
+	before, err := app.db.GetWorkoutsBySessionId(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if rec := call(handler, cookies, http.MethodPut, path, tt.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: PUT %s = %d %s, want 400", tt.name, path, rec.Code, rec.Body)
+		}
+	}
+	after, err := app.db.GetWorkoutsBySessionId(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Errorf("rejected layouts changed the session's workouts to %+v, want %+v", after, before)
+	}
+	for _, id := range []int{otherWorkout, bobWorkout} {
+		if workout, err := app.db.GetWorkoutById(id); err != nil || workout.Position != 0 || workout.Group != 0 {
+			t.Errorf("rejected layouts changed workout %d to %+v (%v)", id, workout, err)
+		}
+	}
+
+	if rec := call(handler, cookies, http.MethodPut, path, fmt.Sprintf(`{"Groups": [[%d], [%d, %d]]}`, c, a, b)); rec.Code != http.StatusOK {
+		t.Fatalf("PUT %s = %d %s", path, rec.Code, rec.Body)
+	}
+	arranged, err := app.db.GetWorkoutsBySessionId(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, w := range arranged {
+		got = append(got, fmt.Sprintf("%d:%d", w.Id, w.Group))
+	}
+	if want := fmt.Sprint([]string{fmt.Sprintf("%d:0", c), fmt.Sprintf("%d:1", a), fmt.Sprintf("%d:1", b)}); fmt.Sprint(got) != want {
+		t.Errorf("arranged workouts %v, want %v", got, want)
+	}
+}
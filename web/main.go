@@ -1,6 +1,7 @@
 package web
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 
@@ -20,38 +21,109 @@ func StartRouter() {
 		// AllowedOrigins:   []string{"https://foo.com"}, // Use this to allow specific origin hosts
 		AllowedOrigins: []string{"https://workout-tracker.13059596.xyz"},
 		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"X-PINGOTHER", "Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "Location"},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 	// r.Use(authMiddleware)
 
 	// Shift this logic to main probably
-	db, err := database.CreateDBConnection(config.AppConfig.DBPath)
+	db, err := openStore(config.AppConfig)
+	if err != nil {
+		panic(err)
+	}
+	providers, err := newIdentityProviders(config.AppConfig)
 	if err != nil {
 		panic(err)
 	}
 	app := App{
-		db:     db,
-		logger: zerolog.New(os.Stdout).With().Timestamp().Logger(),
+		db:        db,
+		logger:    zerolog.New(os.Stdout).With().Timestamp().Logger(),
+		providers: providers,
+
+		localAccounts: config.AppConfig.LocalAccounts(),
+	}
+	app.routes(r)
+
+	// r.GET("/v1/user", authMiddleware(user.Crud))
+	if err := http.ListenAndServe(":8080", r); err != nil {
+		log.Panic().Msg(err.Error())
 	}
+}
+
+// openStore connects to the storage backend named by DatabaseDriver.
+func openStore(cfg config.Config) (database.Store, error) {
+	switch cfg.DatabaseDriver {
+	case "", "sqlite":
+		return database.CreateDBConnection(cfg.DBPath)
+	case "postgres":
+		dsn, err := cfg.PostgresDSN()
+		if err != nil {
+			return nil, fmt.Errorf("openStore: %w", err)
+		}
+		return database.CreatePostgresConnection(dsn)
+	case "memory":
+		return database.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("openStore: unknown database driver %q", cfg.DatabaseDriver)
+	}
+}
+
+func (app *App) routes(r chi.Router) {
 	r.Get("/health", HealthHandler)
 	r.Post("/login", app.HandleLogin)
+	r.Post("/refresh", app.HandleRefresh)
+	r.Post("/logout", app.HandleLogout)
+	r.Post("/register", app.HandleRegister)
+	r.Post("/login/password", app.HandlePasswordLogin)
 	r.Group(func(r chi.Router) {
 		r.Use(app.authMiddleware)
+		r.Group(func(r chi.Router) {
+			r.Use(sessionOnly)
+			r.Post("/logout/all", app.HandleLogoutAll)
+			r.Put("/me/password", app.HandlePasswordChange)
+			r.Post("/account/import", app.AccountImportHandler)
+			r.Get("/tokens", app.ApiTokenListHandler)
+			r.Post("/tokens", app.ApiTokenCreateHandler)
+			r.Delete("/tokens/{tokenID}", app.ApiTokenDeleteHandler)
+		})
+		r.Get("/me", app.MeHandler)
 		r.Get("/sessions", app.SessionListHandler)
+		r.Get("/sessions/{sessionID}", app.SessionGetHandler)
 		r.Get("/workouts/{sessionID}", app.WorkoutListHandler)
 		r.Get("/sets/{workoutID}", app.SetListHandler)
 		r.Get("/lastworkout/{workout}", app.LastWorkoutHandler)
+		r.Get("/exercises", app.ExerciseListHandler)
+		r.Post("/exercises", app.ExerciseCreateHandler)
+		r.Get("/exercises/{exerciseID}", app.ExerciseGetHandler)
+		r.Get("/exercises/{name}/progress", app.ExerciseProgressHandler)
+		r.Get("/records", app.RecordListHandler)
+		r.Get("/export.csv", app.ExportCSVHandler)
+		r.Get("/account/export", app.AccountExportHandler)
+		r.Get("/routines", app.RoutineListHandler)
+		r.Get("/routines/{routineID}", app.RoutineGetHandler)
 		r.Post("/sessions", app.SessionCreateHandler)
 		r.Post("/workouts", app.WorkoutCreateHandler)
 		r.Post("/sets", app.SetCreateHandler)
+		r.Post("/import", app.ImportCSVHandler)
+		r.Post("/routines", app.RoutineCreateHandler)
+		r.Post("/routines/{routineID}/start", app.RoutineStartHandler)
+		r.Post("/sessions/{sessionID}/finish", app.SessionFinishHandler)
+		r.Patch("/me", app.MeUpdateHandler)
+		r.Put("/sessions/{sessionID}", app.SessionUpdateHandler)
+		r.Patch("/sessions/{sessionID}", app.SessionUpdateHandler)
+		r.Delete("/sessions/{sessionID}", app.SessionDeleteHandler)
+		r.Put("/sessions/{sessionID}/layout", app.SessionLayoutHandler)
+		r.Put("/workouts/{workoutID}", app.WorkoutUpdateHandler)
+		r.Patch("/workouts/{workoutID}", app.WorkoutUpdateHandler)
+		r.Delete("/workouts/{workoutID}", app.WorkoutDeleteHandler)
+		r.Put("/sets/{setID}", app.SetUpdateHandler)
+		r.Patch("/sets/{setID}", app.SetUpdateHandler)
+		r.Delete("/sets/{setID}", app.SetDeleteHandler)
+		r.Put("/routines/{routineID}", app.RoutineUpdateHandler)
+		r.Patch("/routines/{routineID}", app.RoutineUpdateHandler)
+		r.Delete("/routines/{routineID}", app.RoutineDeleteHandler)
 	})
-
-	// r.GET("/v1/user", authMiddleware(user.Crud))
-	if err := http.ListenAndServe(":8080", r); err != nil {
-		log.Panic().Msg(err.Error())
-	}
 }
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// currentUser loads the signed-in user. It writes the error response and
+// returns false on failure.
+func (app *App) currentUser(w http.ResponseWriter, userID int) (database.UserRow, bool) {
+	user, err := app.db.GetUserById(userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("currentUser: %v", err)
+		return user, false
+	}
+	return user, true
+}
+
+// Get the signed-in user's profile and preferences
+func (app *App) MeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	user, err := app.db.GetUserById(userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("MeHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, displayUser(user))
+}
+
+// Change the signed-in user's profile and preferences
+func (app *App) MeUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var update MeUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Could not decode user", http.StatusBadRequest)
+		return
+	}
+	user, err := app.db.GetUserById(userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("MeUpdateHandler: %v", err)
+		return
+	}
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			http.Error(w, "Invalid name", http.StatusBadRequest)
+			return
+		}
+		if name != user.Name {
+			user.Name = name
+			user.NameEdited = true
+		}
+	}
+	if update.Unit != nil {
+		if !slices.Contains(database.Units, *update.Unit) {
+			http.Error(w, "Invalid unit", http.StatusBadRequest)
+			return
+		}
+		user.Unit = *update.Unit
+	}
+	if update.TimeZone != nil {
+		if !database.ValidTimeZone(*update.TimeZone) {
+			http.Error(w, "Invalid time zone", http.StatusBadRequest)
+			return
+		}
+		user.TimeZone = *update.TimeZone
+	}
+	if update.Bodyweight != nil {
+		if *update.Bodyweight <= 0 {
+			http.Error(w, "Bodyweight must be positive", http.StatusBadRequest)
+			return
+		}
+		bodyweight := database.WeightToKg(*update.Bodyweight, user.Unit)
+		user.Bodyweight = &bodyweight
+	}
+	if err := app.db.UpdateUser(userID, user.User); err != nil {
+		http.Error(w, "Could not update user", http.StatusInternalServerError)
+		app.logger.Error().Msgf("MeUpdateHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, displayUser(user))
+}
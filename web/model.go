@@ -4,9 +4,187 @@ import "github.com/milindtheengineer/workout-tracker-server/database"
 
 type Workout struct {
 	database.WorkoutRow
-	Sets []database.SetRow
+	Sets []SetView
 }
 
-type WorkoutIDResponse struct {
-	WorkoutID int
+// SetView is a set as returned by the API. Pace (seconds per kilometre) and
+// Speed (km/h) are derived from Duration and Distance, and zero when either is
+// missing.
+type SetView struct {
+	database.SetRow
+	Pace  float64
+	Speed float64
+}
+
+// MeUpdate is the body of PATCH /me. Bodyweight is in Unit, or the user's
+// unit when Unit is left out.
+type MeUpdate struct {
+	Name       *string
+	Unit       *string
+	TimeZone   *string
+	Bodyweight *float64
+}
+
+// SessionView is a session as returned by the API, with Bodyweight in Unit.
+type SessionView struct {
+	database.SessionRow
+	Unit string
+}
+
+// SessionSummary is a session in the session list. Duration is in seconds,
+// zero until the session is finished, and Volume is the weight times reps of
+// its working sets in Unit.
+type SessionSummary struct {
+	SessionView
+	Duration int
+	Volume   float64
+}
+
+// SessionDetail is a session with its workouts in order, each with its sets,
+// as returned by GET /sessions/{id}.
+type SessionDetail struct {
+	SessionSummary
+	Workouts []Workout
+}
+
+// SessionUpdate is the body of POST /sessions and PUT/PATCH
+// /sessions/{sessionID}. Times are in UTC and Bodyweight is in the user's
+// display unit. Nil fields are left unchanged by PATCH; PUT requires DateTime
+// and clears the optional details it leaves out.
+type SessionUpdate struct {
+	DateTime    *string
+	EndDateTime *string
+	TimeZone    *string
+	Title       *string
+	Notes       *string
+	Bodyweight  *float64
+}
+
+// WorkoutLayout is the body of PUT /sessions/{sessionID}/layout. Groups lists
+// every workout ID of the session once, in order; IDs in the same inner list
+// form a superset or circuit, and a list of one is a workout on its own.
+type WorkoutLayout struct {
+	Groups [][]int
+}
+
+// WorkoutUpdate is the body of PUT/PATCH /workouts/{workoutID}. ExerciseID
+// takes precedence over WorkoutName, which is resolved through the catalog.
+type WorkoutUpdate struct {
+	ExerciseID  *int
+	WorkoutName *string
+}
+
+// SetUpdate is the body of PUT/PATCH /sets/{setID}. Weight is in Unit, or in
+// the user's display unit when Unit is nil. PUT clears the optional details
+// it leaves out.
+type SetUpdate struct {
+	Weight       *float64
+	NumberOfReps *int
+	Unit         *string
+	RPE          *float64
+	RIR          *int
+	Type         *string
+	Tempo        *string
+	Completed    *bool
+	Duration     *int
+	Distance     *float64
+	Calories     *int
+}
+
+// ProgressPoint summarises an exercise over one period: a single session, or
+// every session in a week or month when bucketed.
+type ProgressPoint struct {
+	Period             string
+	SessionIDs         []int
+	BestSet            *database.SetRow
+	EstimatedOneRepMax float64
+	TotalVolume        float64
+	TopWeight          float64
+}
+
+// ExerciseProgress gives weights, volumes and estimates in Unit.
+type ExerciseProgress struct {
+	ExerciseID int
+	Exercise   string
+	Unit       string
+	Formula    string
+	Bucket     string
+	Points     []ProgressPoint
+}
+
+// PersonalRecord is a set that beat every earlier set of the same exercise in
+// one category. For most_reps the record is the most reps at exactly Weight,
+// and only a set that beat an earlier set at that weight is reported as new.
+type PersonalRecord struct {
+	ExerciseID int
+	Exercise   string
+	Category   string
+	Value      float64
+	Previous   *float64
+	Weight     float64
+	Reps       int
+	SetID      int
+	SessionID  int
+	DateTime   string
+}
+
+// PersonalRecords gives weight-based values in Unit.
+type PersonalRecords struct {
+	Unit    string
+	Current []PersonalRecord
+	History []PersonalRecord
+}
+
+// SetCreateResponse is the created set plus any personal records it set.
+type SetCreateResponse struct {
+	SetView
+	Records []PersonalRecord
+}
+
+// RoutineUpdate is the body of PUT/PATCH /routines/{routineID}. Exercises,
+// when present, replaces the whole list.
+type RoutineUpdate struct {
+	Name      *string
+	Exercises *[]database.RoutineExercise
+}
+
+// RoutineSession is the session created by starting a routine, with its
+// workouts and the routine's targets.
+type RoutineSession struct {
+	SessionView
+	Routine  database.RoutineRow
+	Workouts []Workout
+}
+
+// ImportReport summarises a CSV import. Rows counts data rows in the file;
+// rows listed in Errors were not imported.
+type ImportReport struct {
+	Format          string
+	Rows            int
+	SessionsCreated int
+	SetsCreated     int
+	SetsSkipped     int
+	Errors          []ImportRowError
+}
+
+// ImportRowError is a problem with one line of an imported file.
+type ImportRowError struct {
+	Row     int
+	Message string
+}
+
+// RestoreReport counts what an account restore wrote.
+type RestoreReport struct {
+	Exercises int
+	Sessions  int
+	Workouts  int
+	Sets      int
+	Routines  int
+}
+
+// ApiTokenCreated is the response to creating an API token. Token is the
+// secret itself, which is only ever shown here.
+type ApiTokenCreated struct {
+	database.ApiTokenRow
+	Token string
 }
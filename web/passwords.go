@@ -0,0 +1,337 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id parameters for new password hashes, the OWASP minimum. Stored
+// hashes carry their own parameters, so these can be raised later.
+const (
+	passwordTime    = 2
+	passwordMemory  = 19 * 1024
+	passwordThreads = 1
+	passwordKeyLen  = 32
+	passwordSaltLen = 16
+)
+
+// Passwords are between these lengths, in characters and bytes.
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 1024
+)
+
+// maxFailedLogins wrong passwords in a row lock sign-in for lockoutDuration.
+const (
+	maxFailedLogins = 5
+	lockoutDuration = 15 * time.Minute
+)
+
+// reauthMaxAge is how recent the provider sign-in setting a user's first
+// password must be.
+const reauthMaxAge = 5 * time.Minute
+
+// dummyPasswordHash is checked against when there is no user or password, so
+// that a failed sign-in takes as long either way.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := hashPassword("workout-tracker")
+	return hash
+})
+
+// Create an account that signs in with an email and password
+func (app *App) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	if !app.localAccounts {
+		http.NotFound(w, r)
+		return
+	}
+	var login PasswordLogin
+	if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
+		http.Error(w, "Could not decode registration", http.StatusBadRequest)
+		return
+	}
+	email, ok := normalizeEmail(login.Email)
+	if !ok {
+		http.Error(w, "Invalid email", http.StatusBadRequest)
+		return
+	}
+	if !validPassword(login.Password) {
+		http.Error(w, "Invalid password, expected 8 to 1024 characters", http.StatusBadRequest)
+		return
+	}
+	hash, err := hashPassword(login.Password)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandleRegister: %v", err)
+		return
+	}
+	name := strings.TrimSpace(login.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	// An email that is taken gets the same answer, so registering does not
+	// tell who has an account.
+	if _, err := app.db.CreateUserWithPassword(database.User{Email: email, Name: name}, hash); err != nil && !strings.Contains(err.Error(), "User already exists") {
+		http.Error(w, "Could not add user", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandleRegister: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Sign in with an email and password
+func (app *App) HandlePasswordLogin(w http.ResponseWriter, r *http.Request) {
+	if !app.localAccounts {
+		http.NotFound(w, r)
+		return
+	}
+	var login PasswordLogin
+	if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
+		http.Error(w, "Could not decode login", http.StatusBadRequest)
+		return
+	}
+	credential, err := app.emailCredential(login.Email)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("HandlePasswordLogin: %v", err)
+			return
+		}
+		verifyPassword(dummyPasswordHash(), login.Password)
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+	if !app.checkPassword(w, credential, login.Password, "Invalid email or password") {
+		return
+	}
+	if err := app.startLogin(w, credential.UserID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandlePasswordLogin: %v", err)
+		return
+	}
+}
+
+// Set or change the signed-in user's password. Other devices are signed out
+// and this one gets a new login.
+func (app *App) HandlePasswordChange(w http.ResponseWriter, r *http.Request) {
+	if !app.localAccounts {
+		http.NotFound(w, r)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var change PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		http.Error(w, "Could not decode password", http.StatusBadRequest)
+		return
+	}
+	if !validPassword(change.NewPassword) {
+		http.Error(w, "Invalid password, expected 8 to 1024 characters", http.StatusBadRequest)
+		return
+	}
+	credential, err := app.db.GetPasswordCredential(userID)
+	if err == nil {
+		if !app.checkPassword(w, credential, change.CurrentPassword, "Invalid current password") {
+			return
+		}
+	} else if errors.Is(err, database.ErrNotFound) {
+		if !app.checkRecentSignIn(w, r, userID, change) {
+			return
+		}
+	} else {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandlePasswordChange: %v", err)
+		return
+	}
+	hash, err := hashPassword(change.NewPassword)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandlePasswordChange: %v", err)
+		return
+	}
+	if err := app.db.SetPassword(userID, hash); err != nil {
+		http.Error(w, "Could not change password", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandlePasswordChange: %v", err)
+		return
+	}
+	if err := app.db.RevokeUserRefreshTokens(userID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandlePasswordChange: %v", err)
+		return
+	}
+	if err := app.startLogin(w, userID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("HandlePasswordChange: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkRecentSignIn verifies that the change carries a credential from one of
+// the user's identity providers issued within reauthMaxAge, and otherwise
+// writes the error response. A stolen access token alone cannot set a
+// password that outlives it.
+func (app *App) checkRecentSignIn(w http.ResponseWriter, r *http.Request, userID int, change PasswordChange) bool {
+	if change.Credential == "" {
+		http.Error(w, "Sign in again to set a password", http.StatusUnauthorized)
+		return false
+	}
+	if change.Provider == "" {
+		change.Provider = "google"
+	}
+	provider, ok := app.providers[change.Provider]
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusBadRequest)
+		return false
+	}
+	identity, err := provider.Verify(r.Context(), change.Credential)
+	if err != nil {
+		http.Error(w, "Sign in again to set a password", http.StatusUnauthorized)
+		app.logger.Error().Msgf("checkRecentSignIn: %v", err)
+		return false
+	}
+	if time.Since(identity.IssuedAt) > reauthMaxAge {
+		http.Error(w, "Sign in again to set a password", http.StatusUnauthorized)
+		return false
+	}
+	user, err := app.db.GetUserByIdentity(identity.Provider, identity.Subject)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("checkRecentSignIn: %v", err)
+		return false
+	}
+	if err != nil || user.Id != userID {
+		http.Error(w, "Sign in again to set a password", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// emailCredential returns the password of the user with the email. Emails of
+// registered users are lowercase but those from identity providers may not be,
+// so the email is tried as typed first.
+func (app *App) emailCredential(email string) (database.PasswordCredential, error) {
+	email = strings.TrimSpace(email)
+	user, err := app.db.GetUserByEmail(email)
+	if errors.Is(err, database.ErrNotFound) && strings.ToLower(email) != email {
+		user, err = app.db.GetUserByEmail(strings.ToLower(email))
+	}
+	if err != nil {
+		return database.PasswordCredential{}, err
+	}
+	return app.db.GetPasswordCredential(user.Id)
+}
+
+// checkPassword checks password against the user's credential, counting it
+// towards a lockout when wrong. It writes the error response, with invalid as
+// the message for a wrong password, and returns false on failure.
+func (app *App) checkPassword(w http.ResponseWriter, credential database.PasswordCredential, password string, invalid string) bool {
+	if credential.LockedUntil != "" {
+		lockedUntil, err := time.Parse(database.DateTimeLayout, credential.LockedUntil)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			app.logger.Error().Msgf("checkPassword: %v", err)
+			return false
+		}
+		if wait := time.Until(lockedUntil); wait > 0 {
+			tooManyAttempts(w, wait)
+			return false
+		}
+	}
+	if verifyPassword(credential.PasswordHash, password) {
+		if credential.FailedAttempts > 0 || credential.LockedUntil != "" {
+			if err := app.db.ResetFailedLogins(credential.UserID); err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				app.logger.Error().Msgf("checkPassword: %v", err)
+				return false
+			}
+		}
+		return true
+	}
+	lockUntil := time.Now().Add(lockoutDuration).UTC()
+	locked, err := app.db.RecordFailedLogin(credential.UserID, maxFailedLogins, lockUntil.Format(database.DateTimeLayout))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("checkPassword: %v", err)
+		return false
+	}
+	if locked {
+		app.logger.Warn().Msgf("checkPassword: locked sign-in for user %d", credential.UserID)
+		tooManyAttempts(w, lockoutDuration)
+		return false
+	}
+	http.Error(w, invalid, http.StatusUnauthorized)
+	return false
+}
+
+// tooManyAttempts answers a sign-in while it is locked for wait.
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+}
+
+// normalizeEmail trims and lowercases a bare email address, reporting
+// whether it is one.
+func normalizeEmail(email string) (string, bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", false
+	}
+	return email, true
+}
+
+func validPassword(password string) bool {
+	return utf8.RuneCountInString(password) >= minPasswordLength && len(password) <= maxPasswordBytes
+}
+
+// hashPassword hashes password with argon2id into a PHC string such as
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("hashPassword: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, passwordTime, passwordMemory, passwordThreads, passwordKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, passwordMemory, passwordTime, passwordThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verifyPassword reports whether password matches a hash from hashPassword.
+func verifyPassword(hash string, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return false
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
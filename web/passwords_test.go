@@ -0,0 +1,118 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+func TestRegisterDoesNotRevealAccounts(t *testing.T) {
+	app, handler := testApp(t)
+	newUser(t, app, "provider@example.com")
+	passwordSignIn(t, handler, "password@example.com", "first password")
+
+	register := func(email string) (int, string, int) {
+		rec := call(handler, nil, http.MethodPost, "/register", fmt.Sprintf(`{"Email": %q, "Password": "second password"}`, email))
+		return rec.Code, rec.Body.String(), len(rec.Result().Cookies())
+	}
+	wantCode, wantBody, wantCookies := register("new@example.com")
+	if wantCode != http.StatusAccepted || wantCookies != 0 {
+		t.Fatalf("registering a new email = %d with %d cookies, want 202 without signing in", wantCode, wantCookies)
+	}
+	for _, email := range []string{"provider@example.com", "password@example.com"} {
+		if code, body, cookies := register(email); code != wantCode || body != wantBody || cookies != wantCookies {
+			t.Errorf("registering taken %s = %d %q with %d cookies, want the same as a new email", email, code, body, cookies)
+		}
+	}
+
+	login := func(email string, password string) int {
+		return call(handler, nil, http.MethodPost, "/login/password", fmt.Sprintf(`{"Email": %q, "Password": %q}`, email, password)).Code
+	}
+	if code := login("new@example.com", "second password"); code != http.StatusOK {
+		t.Errorf("signing in to the new account = %d, want 200", code)
+	}
+	if code := login("password@example.com", "first password"); code != http.StatusOK {
+		t.Errorf("signing in with the original password = %d, want 200", code)
+	}
+	for _, email := range []string{"provider@example.com", "password@example.com"} {
+		if code := login(email, "second password"); code != http.StatusUnauthorized {
+			t.Errorf("signing in to taken %s with the registered password = %d, want 401", email, code)
+		}
+	}
+}
+
+func TestFirstPasswordNeedsRecentSignIn(t *testing.T) {
+	app, handler := testApp(t)
+	app.providers = map[string]IdentityProvider{"fake": fakeProvider{
+		"fresh": {Provider: "fake", Subject: "alice", IssuedAt: time.Now()},
+		"stale": {Provider: "fake", Subject: "alice", IssuedAt: time.Now().Add(-time.Hour)},
+		"bob":   {Provider: "fake", Subject: "bob", IssuedAt: time.Now()},
+	}}
+	for _, subject := range []string{"alice", "bob"} {
+		if _, err := app.db.CreateUserWithIdentity(database.User{Email: subject + "@example.com"}, database.UserIdentity{Provider: "fake", Subject: subject}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	user, err := app.db.GetUserByIdentity("fake", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := signIn(t, app, user.Id)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"no credential", `{"NewPassword": "alice's password"}`, http.StatusUnauthorized},
+		{"unknown provider", `{"NewPassword": "alice's password", "Provider": "other", "Credential": "fresh"}`, http.StatusBadRequest},
+		{"invalid credential", `{"NewPassword": "alice's password", "Provider": "fake", "Credential": "forged"}`, http.StatusUnauthorized},
+		{"old sign-in", `{"NewPassword": "alice's password", "Provider": "fake", "Credential": "stale"}`, http.StatusUnauthorized},
+		{"another user's sign-in", `{"NewPassword": "alice's password", "Provider": "fake", "Credential": "bob"}`, http.StatusUnauthorized},
+		{"recent sign-in", `{"NewPassword": "alice's password", "Provider": "fake", "Credential": "fresh"}`, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		rec := call(handler, cookies, http.MethodPut, "/me/password", tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s: PUT /me/password = %d %s, want %d", tt.name, rec.Code, strings.TrimSpace(rec.Body.String()), tt.status)
+		}
+		if rec.Code == http.StatusNoContent {
+			cookies = rec.Result().Cookies()
+		}
+	}
+	body := `{"Email": "alice@example.com", "Password": "alice's password"}`
+	if rec := call(handler, nil, http.MethodPost, "/login/password", body); rec.Code != http.StatusOK {
+		t.Errorf("signing in with the new password = %d, want 200", rec.Code)
+	}
+	// Once there is a password, changing it takes the current one.
+	change := `{"CurrentPassword": "alice's password", "NewPassword": "another password"}`
+	if rec := call(handler, cookies, http.MethodPut, "/me/password", change); rec.Code != http.StatusNoContent {
+		t.Errorf("changing the password = %d %s, want 204", rec.Code, rec.Body)
+	}
+}
+
+func TestPasswordChangeRejectsApiTokens(t *testing.T) {
+	_, handler := testApp(t)
+	cookies := passwordSignIn(t, handler, "alice@example.com", "alice's password")
+	rec := call(handler, cookies, http.MethodPost, "/tokens", `{"Name": "script", "Scope": "write"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /tokens = %d %s", rec.Code, rec.Body)
+	}
+	var created ApiTokenCreated
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPut, "/me/password", strings.NewReader(`{"CurrentPassword": "alice's password", "NewPassword": "script's password"}`))
+	req.Header.Set("Authorization", "Bearer "+created.Token)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	if res.Code != http.StatusForbidden {
+		t.Errorf("PUT /me/password with an API token = %d, want 403", res.Code)
+	}
+}
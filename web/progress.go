@@ -0,0 +1,178 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+const (
+	formulaEpley   = "epley"
+	formulaBrzycki = "brzycki"
+
+	bucketSession = "session"
+	bucketWeek    = "week"
+	bucketMonth   = "month"
+)
+
+// estimateOneRepMax returns the estimated one-rep max for a set using the
+// named formula. A single rep is its own max; sets without reps score zero.
+func estimateOneRepMax(formula string, weight float64, reps int) float64 {
+	if reps <= 0 || weight <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return weight
+	}
+	switch formula {
+	case formulaBrzycki:
+		// Brzycki diverges as reps approach 37; clamp to Epley beyond its
+		// useful range rather than returning nonsense.
+		if reps >= 37 {
+			return weight * (1 + float64(reps)/30)
+		}
+		return weight * 36 / float64(37-reps)
+	default:
+		return weight * (1 + float64(reps)/30)
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// progressPeriod returns the key a set is grouped under for the given bucket:
+// the session itself, or the Monday starting its week or its month in loc.
+func progressPeriod(bucket string, set database.ExerciseSet, loc *time.Location) (string, error) {
+	if bucket == bucketSession {
+		return fmt.Sprintf("%d", set.SessionID), nil
+	}
+	t, err := time.ParseInLocation(database.DateTimeLayout, set.DateTime, time.UTC)
+	if err != nil {
+		return "", fmt.Errorf("progressPeriod: %w", err)
+	}
+	t = t.In(loc)
+	if bucket == bucketMonth {
+		return t.Format("2006-01"), nil
+	}
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset).Format("2006-01-02"), nil
+}
+
+// buildProgress folds an exercise's set history (oldest first) into one
+// ProgressPoint per bucket, with weeks and months in loc. Warm-ups and
+// incomplete sets are left out.
+func buildProgress(history []database.ExerciseSet, formula string, bucket string, loc *time.Location) ([]ProgressPoint, error) {
+	points := []ProgressPoint{}
+	index := map[string]int{}
+	for _, set := range history {
+		if !set.CountsTowardsRecords() {
+			continue
+		}
+		key, err := progressPeriod(bucket, set, loc)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := index[key]
+		if !ok {
+			period := key
+			if bucket == bucketSession {
+				period = set.DateTime
+			}
+			points = append(points, ProgressPoint{Period: period})
+			i = len(points) - 1
+			index[key] = i
+		}
+		p := &points[i]
+		if len(p.SessionIDs) == 0 || p.SessionIDs[len(p.SessionIDs)-1] != set.SessionID {
+			p.SessionIDs = append(p.SessionIDs, set.SessionID)
+		}
+
+		weight := set.Weight
+		e1rm := round2(estimateOneRepMax(formula, weight, set.NumberOfReps))
+		if p.BestSet == nil || e1rm > p.EstimatedOneRepMax {
+			best := set.SetRow
+			p.BestSet = &best
+			p.EstimatedOneRepMax = e1rm
+		}
+		p.TotalVolume = round2(p.TotalVolume + weight*float64(set.NumberOfReps))
+		if weight > p.TopWeight {
+			p.TopWeight = weight
+		}
+	}
+	return points, nil
+}
+
+// Get the progression of an exercise over time
+func (app *App) ExerciseProgressHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	name := chi.URLParam(r, "name")
+	if len(name) < 1 {
+		http.Error(w, "Invalid exercise name", http.StatusBadRequest)
+		return
+	}
+	formula := strings.ToLower(r.URL.Query().Get("formula"))
+	switch formula {
+	case "":
+		formula = formulaEpley
+	case formulaEpley, formulaBrzycki:
+	default:
+		http.Error(w, "Invalid formula", http.StatusBadRequest)
+		return
+	}
+	bucket := strings.ToLower(r.URL.Query().Get("bucket"))
+	switch bucket {
+	case "":
+		bucket = bucketSession
+	case bucketSession, bucketWeek, bucketMonth:
+	default:
+		http.Error(w, "Invalid bucket", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := app.currentUser(w, userID)
+	if !ok {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	exercise, found, ok := app.lookupExercise(w, userID, name)
+	if !ok {
+		return
+	}
+	if !found {
+		http.Error(w, "Exercise not found", http.StatusNotFound)
+		return
+	}
+	history, err := app.db.GetSetHistoryForExercise(exercise.Id, userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ExerciseProgressHandler: %v", err)
+		return
+	}
+	points, err := buildProgress(displayHistory(history, unit), formula, bucket, userLocation(user))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ExerciseProgressHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, ExerciseProgress{
+		ExerciseID: exercise.Id,
+		Exercise:   exercise.Name,
+		Unit:       unit,
+		Formula:    formula,
+		Bucket:     bucket,
+		Points:     points,
+	})
+}
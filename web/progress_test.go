@@ -0,0 +1,42 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+func TestProgressPeriodInUserTimeZone(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	tests := []struct {
+		name     string
+		dateTime string
+		bucket   string
+		loc      *time.Location
+		want     string
+	}{
+		// Sunday 2024-03-10 20:00 in Los Angeles is Monday 03:00 UTC.
+		{"week in UTC", "2024-03-11 03:00:00", bucketWeek, time.UTC, "2024-03-11"},
+		{"week in user zone", "2024-03-11 03:00:00", bucketWeek, la, "2024-03-04"},
+		// 2024-03-31 22:00 in Los Angeles is 2024-04-01 05:00 UTC.
+		{"month in UTC", "2024-04-01 05:00:00", bucketMonth, time.UTC, "2024-04"},
+		{"month in user zone", "2024-04-01 05:00:00", bucketMonth, la, "2024-03"},
+		{"session", "2024-04-01 05:00:00", bucketSession, la, "7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := database.ExerciseSet{SessionID: 7, DateTime: tt.dateTime}
+			got, err := progressPeriod(tt.bucket, set, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("progressPeriod(%s, %s) = %s, want %s", tt.bucket, tt.dateTime, got, tt.want)
+			}
+		})
+	}
+}
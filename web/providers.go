@@ -0,0 +1,259 @@
+package web
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/milindtheengineer/workout-tracker-server/config"
+	"google.golang.org/api/idtoken"
+)
+
+// Identity is who an identity provider says signed in. Subject is the
+// provider's stable ID for the user; the email and profile may change.
+// IssuedAt is when the provider issued the credential, zero if it did not say.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+	Locale        string
+	IssuedAt      time.Time
+}
+
+// IdentityProvider verifies the credential a client obtained from a sign-in
+// service, such as an OpenID Connect ID token.
+type IdentityProvider interface {
+	Verify(ctx context.Context, credential string) (Identity, error)
+}
+
+// newIdentityProviders builds the providers enabled in cfg, keyed by name.
+func newIdentityProviders(cfg config.Config) (map[string]IdentityProvider, error) {
+	providers := map[string]IdentityProvider{}
+	for _, name := range cfg.AuthProviders {
+		if strings.EqualFold(strings.TrimSpace(name), "google") {
+			providers["google"] = googleProvider{audience: cfg.GoogleToken}
+		}
+	}
+	oidc, err := cfg.OIDCProviders()
+	if err != nil {
+		return nil, fmt.Errorf("newIdentityProviders: %w", err)
+	}
+	for _, provider := range oidc {
+		providers[provider.Name] = newOIDCProvider(provider)
+	}
+	return providers, nil
+}
+
+// googleProvider verifies Google ID tokens issued to audience.
+type googleProvider struct {
+	audience string
+}
+
+func (p googleProvider) Verify(ctx context.Context, credential string) (Identity, error) {
+	payload, err := idtoken.Validate(ctx, credential, p.audience)
+	if err != nil {
+		return Identity{}, fmt.Errorf("googleProvider: %w", err)
+	}
+	identity := Identity{Provider: "google", Subject: payload.Subject, IssuedAt: time.Unix(payload.IssuedAt, 0)}
+	identity.Email, _ = payload.Claims["email"].(string)
+	identity.EmailVerified, _ = payload.Claims["email_verified"].(bool)
+	identity.Name, _ = payload.Claims["name"].(string)
+	identity.Picture, _ = payload.Claims["picture"].(string)
+	identity.Locale, _ = payload.Claims["locale"].(string)
+	return identity, nil
+}
+
+// oidcHTTPTimeout bounds discovery and key requests to a provider.
+const oidcHTTPTimeout = 10 * time.Second
+
+// oidcKeyRefreshEvery is how often an unknown key ID may trigger a refetch
+// of the provider's keys.
+const oidcKeyRefreshEvery = time.Minute
+
+// oidcProvider verifies ID tokens from a generic OpenID Connect provider. The
+// discovery document and signing keys are fetched on first use and the keys
+// again when a token is signed with one not seen yet.
+type oidcProvider struct {
+	config.OIDCProvider
+	client *http.Client
+
+	mu        sync.Mutex
+	jwksURI   string
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func newOIDCProvider(cfg config.OIDCProvider) *oidcProvider {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &oidcProvider{OIDCProvider: cfg, client: &http.Client{Timeout: oidcHTTPTimeout}}
+}
+
+// oidcClaims are the ID token claims the server reads. Some providers send
+// email_verified as a string.
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	Locale        string `json:"locale"`
+	jwt.RegisteredClaims
+}
+
+func (p *oidcProvider) Verify(ctx context.Context, credential string) (Identity, error) {
+	claims := &oidcClaims{}
+	_, err := jwt.ParseWithClaims(credential, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("oidcProvider %s: %w", p.Name, err)
+	}
+	if claims.Subject == "" {
+		return Identity{}, fmt.Errorf("oidcProvider %s: token has no subject", p.Name)
+	}
+	verified := claims.EmailVerified == true || claims.EmailVerified == "true"
+	identity := Identity{
+		Provider:      p.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+		Locale:        claims.Locale,
+	}
+	if claims.IssuedAt != nil {
+		identity.IssuedAt = claims.IssuedAt.Time
+	}
+	return identity, nil
+}
+
+// key returns the provider's signing key with ID kid, fetching the keys when
+// they are missing or do not include it.
+func (p *oidcProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if p.keys != nil && time.Since(p.fetchedAt) < oidcKeyRefreshEvery {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if p.jwksURI == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("discovery: %w", err)
+		}
+		if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer || discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("discovery: issuer %q does not match", discovery.Issuer)
+		}
+		p.jwksURI = discovery.JWKSURI
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("keys: %w", err)
+	}
+	p.keys = map[string]crypto.PublicKey{}
+	p.fetchedAt = time.Now()
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			p.keys[k.Kid] = key
+		}
+	}
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jwk is a public key from a provider's JSON Web Key Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
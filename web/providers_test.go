@@ -0,0 +1,211 @@
+package web
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/milindtheengineer/workout-tracker-server/config"
+)
+
+// testIssuer is an OpenID Connect provider serving a discovery document and
+// a key set that the test can change, counting the requests for each.
+type testIssuer struct {
+	*httptest.Server
+	issuer string
+
+	mu             sync.Mutex
+	keys           []jwk
+	discoveryCalls int
+	keyCalls       int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	issuer := &testIssuer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+		issuer.discoveryCalls++
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.issuer, "jwks_uri": issuer.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+		issuer.keyCalls++
+		json.NewEncoder(w).Encode(map[string][]jwk{"keys": issuer.keys})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	issuer.issuer = issuer.URL
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (i *testIssuer) publish(key jwk) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys = append(i.keys, key)
+}
+
+func (i *testIssuer) calls() (discovery int, keys int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.discoveryCalls, i.keyCalls
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	return jwk{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// idToken signs an ID token for claims with key, naming it kid.
+func idToken(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOIDCProviderVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.publish(rsaJWK("rsa-1", rsaKey))
+	// A trailing slash in the configured issuer is not part of its identity.
+	provider := newOIDCProvider(config.OIDCProvider{Name: "dex", Issuer: issuer.URL + "/", ClientID: "workouts"})
+	claims := func(changes jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"iss":            issuer.URL,
+			"aud":            "workouts",
+			"sub":            "user-1",
+			"email":          "alice@example.com",
+			"email_verified": "true",
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+		for name, value := range changes {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+	ctx := context.Background()
+
+	identity, err := provider.Verify(ctx, idToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Provider != "dex" || identity.Subject != "user-1" || identity.Email != "alice@example.com" || !identity.EmailVerified || identity.IssuedAt.IsZero() {
+		t.Errorf("identity %+v", identity)
+	}
+
+	rejected := []struct {
+		name  string
+		token string
+	}{
+		{"another issuer", idToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(jwt.MapClaims{"iss": "https://accounts.example.com"}))},
+		{"another audience", idToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(jwt.MapClaims{"aud": "someone-else"}))},
+		{"expired", idToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}))},
+		{"no expiry", idToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(jwt.MapClaims{"exp": nil}))},
+		{"no subject", idToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(jwt.MapClaims{"sub": nil}))},
+		{"HMAC", idToken(t, jwt.SigningMethodHS256, []byte("secret"), "rsa-1", claims(nil))},
+	}
+	for _, tt := range rejected {
+		if _, err := provider.Verify(ctx, tt.token); err == nil {
+			t.Errorf("%s: token verified", tt.name)
+		}
+	}
+	if discovery, keys := issuer.calls(); discovery != 1 || keys != 1 {
+		t.Errorf("%d discovery and %d key requests, want 1 of each", discovery, keys)
+	}
+
+	// The provider rotates to a new key. Until the refresh interval has
+	// passed, tokens signed with it are refused without asking again.
+	issuer.publish(ecJWK("ec-1", ecKey))
+	rotated := idToken(t, jwt.SigningMethodES256, ecKey, "ec-1", claims(nil))
+	for i := 0; i < 3; i++ {
+		if _, err := provider.Verify(ctx, rotated); err == nil || !strings.Contains(err.Error(), "unknown key") {
+			t.Errorf("token with a key fetched too soon: %v, want unknown key", err)
+		}
+	}
+	if _, keys := issuer.calls(); keys != 1 {
+		t.Errorf("%d key requests within the refresh interval, want 1", keys)
+	}
+
+	provider.mu.Lock()
+	provider.fetchedAt = time.Now().Add(-oidcKeyRefreshEvery)
+	provider.mu.Unlock()
+	if _, err := provider.Verify(ctx, rotated); err != nil {
+		t.Errorf("token with the new key after the refresh interval: %v", err)
+	}
+	if _, err := provider.Verify(ctx, idToken(t, jwt.SigningMethodES256, ecKey, "ec-2", claims(nil))); err == nil {
+		t.Error("token with an unpublished key verified")
+	}
+	if discovery, keys := issuer.calls(); discovery != 1 || keys != 2 {
+		t.Errorf("%d discovery and %d key requests, want 1 and 2", discovery, keys)
+	}
+}
+
+func TestOIDCProviderDiscoveryIssuerMismatch(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.issuer = "https://accounts.example.com"
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.publish(ecJWK("ec-1", key))
+	provider := newOIDCProvider(config.OIDCProvider{Name: "dex", Issuer: issuer.URL, ClientID: "workouts"})
+	token := idToken(t, jwt.SigningMethodES256, key, "ec-1", jwt.MapClaims{
+		"iss": issuer.URL,
+		"aud": "workouts",
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := provider.Verify(context.Background(), token); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Verify with a discovery document for another issuer: %v, want a mismatch", err)
+	}
+	if _, keys := issuer.calls(); keys != 0 {
+		t.Errorf("%d key requests after a mismatched discovery document, want 0", keys)
+	}
+}
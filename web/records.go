@@ -0,0 +1,235 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+const (
+	recordHeaviestWeight = "heaviest_weight"
+	recordEstimated1RM   = "estimated_1rm"
+	recordMostReps       = "most_reps"
+	recordSessionVolume  = "session_volume"
+)
+
+// recordTracker replays a user's set history in chronological order and emits
+// a PersonalRecord every time a set beats everything logged before it.
+// Records are derived rather than stored so that edited or deleted sets never
+// leave stale PRs behind. They are kept per exercise ID, so renaming an
+// exercise or logging it under an alias keeps one history.
+type recordTracker struct {
+	best map[int]map[string]PersonalRecord
+	// repMaxes holds the most reps at each weight, by exercise and weight.
+	repMaxes map[int]map[float64]PersonalRecord
+	volume   map[int]map[int]float64
+	history  []PersonalRecord
+}
+
+func newRecordTracker() *recordTracker {
+	return &recordTracker{
+		best:     map[int]map[string]PersonalRecord{},
+		repMaxes: map[int]map[float64]PersonalRecord{},
+		volume:   map[int]map[int]float64{},
+	}
+}
+
+func (rt *recordTracker) record(set database.ExerciseSet, category string, value float64) PersonalRecord {
+	return PersonalRecord{
+		ExerciseID: set.ExerciseID,
+		Exercise:   set.ExerciseName,
+		Category:   category,
+		Value:      round2(value),
+		Weight:     set.Weight,
+		Reps:       set.NumberOfReps,
+		SetID:      set.Id,
+		SessionID:  set.SessionID,
+		DateTime:   set.DateTime,
+	}
+}
+
+// beat stores rec as the new best for its category if it improves on the
+// current one, appending it to the history.
+func (rt *recordTracker) beat(rec PersonalRecord) (PersonalRecord, bool) {
+	bests, ok := rt.best[rec.ExerciseID]
+	if !ok {
+		bests = map[string]PersonalRecord{}
+		rt.best[rec.ExerciseID] = bests
+	}
+	current, ok := bests[rec.Category]
+	if ok && rec.Value <= current.Value {
+		return rec, false
+	}
+	if ok {
+		previous := current.Value
+		rec.Previous = &previous
+	}
+	bests[rec.Category] = rec
+	rt.history = append(rt.history, rec)
+	return rec, true
+}
+
+// add feeds the next set in chronological order and returns the records it set.
+// Warm-ups and incomplete sets never set records.
+func (rt *recordTracker) add(set database.ExerciseSet) []PersonalRecord {
+	if set.NumberOfReps <= 0 || !set.CountsTowardsRecords() {
+		return nil
+	}
+	var hits []PersonalRecord
+	collect := func(rec PersonalRecord, ok bool) {
+		if ok {
+			hits = append(hits, rec)
+		}
+	}
+	weight := set.Weight
+	if weight > 0 {
+		collect(rt.beat(rt.record(set, recordHeaviestWeight, weight)))
+		collect(rt.beat(rt.record(set, recordEstimated1RM, estimateOneRepMax(formulaEpley, weight, set.NumberOfReps))))
+	}
+	collect(rt.addRepMax(set))
+	collect(rt.addVolume(set, weight*float64(set.NumberOfReps)))
+	return hits
+}
+
+// addRepMax checks the set against the most reps logged at the same weight.
+// The first set at a weight only starts its count; a record takes an earlier
+// set at that weight to beat.
+func (rt *recordTracker) addRepMax(set database.ExerciseSet) (PersonalRecord, bool) {
+	table, ok := rt.repMaxes[set.ExerciseID]
+	if !ok {
+		table = map[float64]PersonalRecord{}
+		rt.repMaxes[set.ExerciseID] = table
+	}
+	rec := rt.record(set, recordMostReps, float64(set.NumberOfReps))
+	current, ok := table[set.Weight]
+	if !ok {
+		table[set.Weight] = rec
+		return PersonalRecord{}, false
+	}
+	if set.NumberOfReps <= current.Reps {
+		return PersonalRecord{}, false
+	}
+	previous := current.Value
+	rec.Previous = &previous
+	table[set.Weight] = rec
+	rt.history = append(rt.history, rec)
+	return rec, true
+}
+
+// addVolume accumulates the session's volume for the exercise. A session that
+// passes the previous best keeps a single history entry, updated as more sets
+// are logged, so the history shows one volume PR per session.
+func (rt *recordTracker) addVolume(set database.ExerciseSet, volume float64) (PersonalRecord, bool) {
+	if volume <= 0 {
+		return PersonalRecord{}, false
+	}
+	sessions, ok := rt.volume[set.ExerciseID]
+	if !ok {
+		sessions = map[int]float64{}
+		rt.volume[set.ExerciseID] = sessions
+	}
+	sessions[set.SessionID] += volume
+	total := sessions[set.SessionID]
+
+	current, ok := rt.best[set.ExerciseID][recordSessionVolume]
+	if !ok || current.SessionID != set.SessionID {
+		return rt.beat(rt.record(set, recordSessionVolume, total))
+	}
+	rec := rt.record(set, recordSessionVolume, total)
+	rec.Previous = current.Previous
+	rt.best[set.ExerciseID][recordSessionVolume] = rec
+	for i := len(rt.history) - 1; i >= 0; i-- {
+		h := rt.history[i]
+		if h.ExerciseID == rec.ExerciseID && h.Category == recordSessionVolume {
+			rt.history = append(rt.history[:i], rt.history[i+1:]...)
+			break
+		}
+	}
+	rt.history = append(rt.history, rec)
+	return rec, true
+}
+
+// current returns the standing records: the best of each category plus the
+// most reps at each weight, ordered by exercise, category and heaviest weight
+// first.
+func (rt *recordTracker) current() []PersonalRecord {
+	records := []PersonalRecord{}
+	for _, bests := range rt.best {
+		for _, rec := range bests {
+			records = append(records, rec)
+		}
+	}
+	for _, table := range rt.repMaxes {
+		for _, rec := range table {
+			records = append(records, rec)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Exercise != b.Exercise {
+			return a.Exercise < b.Exercise
+		}
+		if a.ExerciseID != b.ExerciseID {
+			return a.ExerciseID < b.ExerciseID
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return a.Weight > b.Weight
+	})
+	return records
+}
+
+// recordsForSet replays an exercise's history and returns the records set by
+// setID, if any.
+func recordsForSet(history []database.ExerciseSet, setID int) []PersonalRecord {
+	rt := newRecordTracker()
+	records := []PersonalRecord{}
+	for _, set := range history {
+		hit := rt.add(set)
+		if set.Id == setID {
+			records = append(records, hit...)
+		}
+	}
+	return records
+}
+
+// Get current and historical personal records, optionally for one exercise
+func (app *App) RecordListHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	var history []database.ExerciseSet
+	if name := r.URL.Query().Get("exercise"); name != "" {
+		exercise, found, ok := app.lookupExercise(w, userID, name)
+		if !ok {
+			return
+		}
+		if found {
+			history, err = app.db.GetSetHistoryForExercise(exercise.Id, userID)
+		}
+	} else {
+		history, err = app.db.GetSetHistoryForUser(userID)
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RecordListHandler: %v", err)
+		return
+	}
+	rt := newRecordTracker()
+	for _, set := range displayHistory(history, unit) {
+		rt.add(set)
+	}
+	historical := rt.history
+	if historical == nil {
+		historical = []PersonalRecord{}
+	}
+	app.writeJSON(w, http.StatusOK, PersonalRecords{Unit: unit, Current: rt.current(), History: historical})
+}
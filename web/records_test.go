@@ -0,0 +1,73 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// exerciseSet is a completed working set of exerciseID, logged under name.
+func exerciseSet(id, sessionID, exerciseID int, name string, weight float64, reps int) database.ExerciseSet {
+	set := database.ExerciseSet{SessionID: sessionID, ExerciseID: exerciseID, ExerciseName: name}
+	set.Id = id
+	set.Weight = weight
+	set.NumberOfReps = reps
+	set.Type = database.SetTypeWorking
+	set.Completed = true
+	return set
+}
+
+// categories counts the records in each category.
+func categories(records []PersonalRecord) map[string]int {
+	counts := map[string]int{}
+	for _, rec := range records {
+		counts[rec.Category]++
+	}
+	return counts
+}
+
+func TestMostRepsAtEachWeight(t *testing.T) {
+	rt := newRecordTracker()
+	// The first set at a weight has nothing at that weight to beat.
+	if got := categories(rt.add(exerciseSet(1, 1, 1, "squat", 100, 5)))[recordMostReps]; got != 0 {
+		t.Errorf("first 100 x 5 set %d most-reps records, want 0", got)
+	}
+	if got := categories(rt.add(exerciseSet(2, 1, 1, "squat", 80, 3)))[recordMostReps]; got != 0 {
+		t.Errorf("first 80 x 3 set %d most-reps records, want 0", got)
+	}
+	if got := categories(rt.add(exerciseSet(3, 2, 1, "squat", 80, 3)))[recordMostReps]; got != 0 {
+		t.Errorf("repeating 80 x 3 set %d most-reps records, want 0", got)
+	}
+	hits := rt.add(exerciseSet(4, 2, 1, "squat", 80, 4))
+	var rec *PersonalRecord
+	for i := range hits {
+		if hits[i].Category == recordMostReps {
+			rec = &hits[i]
+		}
+	}
+	if rec == nil {
+		t.Fatal("80 x 4 set no most-reps record")
+	}
+	if rec.Previous == nil || *rec.Previous != 3 {
+		t.Errorf("80 x 4 previous = %v, want 3", rec.Previous)
+	}
+	if got := categories(rt.current())[recordMostReps]; got != 2 {
+		t.Errorf("current has %d most-reps records, want one for each of 2 weights", got)
+	}
+}
+
+func TestRecordsFollowExerciseID(t *testing.T) {
+	rt := newRecordTracker()
+	rt.add(exerciseSet(1, 1, 1, "squat", 100, 5))
+	// The same exercise after a rename keeps its records.
+	if hits := rt.add(exerciseSet(2, 2, 1, "back squat", 90, 5)); categories(hits)[recordHeaviestWeight] != 0 {
+		t.Errorf("lighter set after a rename beat the heaviest weight: %+v", hits)
+	}
+	// A different exercise with the old name starts its own records.
+	if hits := rt.add(exerciseSet(3, 3, 2, "squat", 60, 5)); categories(hits)[recordHeaviestWeight] != 1 {
+		t.Errorf("first set of another exercise set no heaviest weight: %+v", hits)
+	}
+	if got := categories(rt.current())[recordHeaviestWeight]; got != 2 {
+		t.Errorf("current has %d heaviest-weight records, want 2", got)
+	}
+}
@@ -0,0 +1,277 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// resolveRoutineExercises resolves each entry through the exercise catalog,
+// the same way workouts are, validates its targets and converts target weights
+// from unit to kilograms. It writes the error response and returns false on
+// failure.
+func (app *App) resolveRoutineExercises(w http.ResponseWriter, userID int, unit string, exercises []database.RoutineExercise) ([]database.RoutineExercise, bool) {
+	resolved := []database.RoutineExercise{}
+	seen := map[int]bool{}
+	for _, entry := range exercises {
+		if entry.TargetSets < 0 || entry.TargetReps < 0 || entry.TargetWeight < 0 {
+			http.Error(w, "Invalid routine targets", http.StatusBadRequest)
+			return nil, false
+		}
+		exercise, ok := app.resolveExercise(w, userID, entry.ExerciseID, entry.ExerciseName)
+		if !ok {
+			return nil, false
+		}
+		// A session holds one workout per exercise, so neither can a routine.
+		if seen[exercise.Id] {
+			http.Error(w, fmt.Sprintf("Exercise %q is listed more than once", exercise.Name), http.StatusBadRequest)
+			return nil, false
+		}
+		seen[exercise.Id] = true
+		entry.ExerciseID = exercise.Id
+		entry.ExerciseName = exercise.Name
+		entry.TargetWeight = database.WeightToKg(entry.TargetWeight, unit)
+		resolved = append(resolved, entry)
+	}
+	return resolved, true
+}
+
+// Get the user's routines
+func (app *App) RoutineListHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	routines, err := app.db.GetRoutinesByUserId(userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineListHandler: %v", err)
+		return
+	}
+	converted := []database.RoutineRow{}
+	for _, routine := range routines {
+		converted = append(converted, displayRoutine(routine, unit))
+	}
+	app.writeJSON(w, http.StatusOK, converted)
+}
+
+func (app *App) RoutineGetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	routineID, err := urlParamID(r, "routineID")
+	if err != nil {
+		http.Error(w, "Invalid routine ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeRoutine(w, routineID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	routine, err := app.db.GetRoutineById(routineID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineGetHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, displayRoutine(routine, unit))
+}
+
+func (app *App) RoutineCreateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var routine database.Routine
+	if err := json.NewDecoder(r.Body).Decode(&routine); err != nil {
+		http.Error(w, "Could not decode routine", http.StatusBadRequest)
+		return
+	}
+	routine.UserID = userID
+	routine.Name = strings.TrimSpace(routine.Name)
+	if routine.Name == "" {
+		http.Error(w, "Invalid routine name", http.StatusBadRequest)
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	exercises, ok := app.resolveRoutineExercises(w, userID, unit, routine.Exercises)
+	if !ok {
+		return
+	}
+	routine.Exercises = exercises
+	routineID, err := app.db.CreateRoutine(routine)
+	if err != nil {
+		if strings.Contains(err.Error(), "Routine already exists") {
+			http.Error(w, "Routine already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Could not add routine", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineCreateHandler: %v", err)
+		return
+	}
+	created, err := app.db.GetRoutineById(int(routineID))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineCreateHandler: %v", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/routines/%d", created.Id))
+	app.writeJSON(w, http.StatusCreated, displayRoutine(created, unit))
+}
+
+func (app *App) RoutineUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	routineID, err := urlParamID(r, "routineID")
+	if err != nil {
+		http.Error(w, "Invalid routine ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeRoutine(w, routineID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	var update RoutineUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Could not decode routine", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && (update.Name == nil || update.Exercises == nil) {
+		http.Error(w, "Name and Exercises are required", http.StatusBadRequest)
+		return
+	}
+	routine, err := app.db.GetRoutineById(routineID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineUpdateHandler: %v", err)
+		return
+	}
+	if update.Name != nil {
+		routine.Name = strings.TrimSpace(*update.Name)
+		if routine.Name == "" {
+			http.Error(w, "Invalid routine name", http.StatusBadRequest)
+			return
+		}
+	}
+	if update.Exercises != nil {
+		exercises, ok := app.resolveRoutineExercises(w, userID, unit, *update.Exercises)
+		if !ok {
+			return
+		}
+		routine.Exercises = exercises
+	}
+	if err := app.db.UpdateRoutine(routineID, routine.Routine); err != nil {
+		if strings.Contains(err.Error(), "Routine already exists") {
+			http.Error(w, "Routine already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Could not update routine", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineUpdateHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, displayRoutine(routine, unit))
+}
+
+func (app *App) RoutineDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	routineID, err := urlParamID(r, "routineID")
+	if err != nil {
+		http.Error(w, "Invalid routine ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeRoutine(w, routineID, userID) {
+		return
+	}
+	if err := app.db.DeleteRoutine(routineID); err != nil {
+		http.Error(w, "Could not delete routine", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineDeleteHandler: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Start a new session pre-filled with one workout per routine exercise
+func (app *App) RoutineStartHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	routineID, err := urlParamID(r, "routineID")
+	if err != nil {
+		http.Error(w, "Invalid routine ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeRoutine(w, routineID, userID) {
+		return
+	}
+	unit, ok := app.displayUnit(w, r, userID)
+	if !ok {
+		return
+	}
+	user, ok := app.currentUser(w, userID)
+	if !ok {
+		return
+	}
+	routine, err := app.db.GetRoutineById(routineID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineStartHandler: %v", err)
+		return
+	}
+	sessionID, err := app.db.StartRoutine(routineID, database.Session{UserID: userID, TimeZone: user.TimeZone, Title: routine.Name})
+	if err != nil {
+		if strings.Contains(err.Error(), "Workout already exists") {
+			http.Error(w, "Routine lists an exercise more than once", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Could not start routine", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineStartHandler: %v", err)
+		return
+	}
+	session, err := app.db.GetSessionById(int(sessionID))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineStartHandler: %v", err)
+		return
+	}
+	workouts, err := app.db.GetWorkoutsBySessionId(session.Id)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("RoutineStartHandler: %v", err)
+		return
+	}
+	started := RoutineSession{SessionView: displaySession(session, unit), Routine: displayRoutine(routine, unit), Workouts: []Workout{}}
+	for _, workout := range workouts {
+		started.Workouts = append(started.Workouts, Workout{WorkoutRow: workout, Sets: []SetView{}})
+	}
+	w.Header().Set("Location", fmt.Sprintf("/sessions/%d", session.Id))
+	app.writeJSON(w, http.StatusCreated, started)
+}
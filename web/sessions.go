@@ -0,0 +1,167 @@
+package web
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// applySessionUpdate copies the fields update sets onto session, converting
+// Bodyweight from unit to kilograms. With replace, as for PUT, the optional
+// details update leaves out are cleared.
+func applySessionUpdate(session *database.Session, update SessionUpdate, unit string, replace bool) {
+	if replace {
+		session.EndDateTime = ""
+		session.Title = ""
+		session.Notes = ""
+		session.Bodyweight = nil
+	}
+	if update.DateTime != nil {
+		session.DateTime = *update.DateTime
+	}
+	if update.EndDateTime != nil {
+		session.EndDateTime = *update.EndDateTime
+	}
+	if update.TimeZone != nil {
+		session.TimeZone = *update.TimeZone
+	}
+	if update.Title != nil {
+		session.Title = strings.TrimSpace(*update.Title)
+	}
+	if update.Notes != nil {
+		session.Notes = *update.Notes
+	}
+	if update.Bodyweight != nil {
+		bodyweight := database.WeightToKg(*update.Bodyweight, unit)
+		session.Bodyweight = &bodyweight
+	}
+}
+
+// validateSession checks the fields of a session that has been filled in from
+// a request. The error is meant for the client.
+func validateSession(session database.Session) error {
+	start, err := time.Parse(database.DateTimeLayout, session.DateTime)
+	if err != nil {
+		return errors.New("Invalid DateTime")
+	}
+	if session.EndDateTime != "" {
+		end, err := time.Parse(database.DateTimeLayout, session.EndDateTime)
+		if err != nil {
+			return errors.New("Invalid EndDateTime")
+		}
+		if end.Before(start) {
+			return errors.New("EndDateTime must not be before DateTime")
+		}
+	}
+	switch {
+	case !database.ValidTimeZone(session.TimeZone):
+		return errors.New("Invalid time zone")
+	case session.Bodyweight != nil && *session.Bodyweight <= 0:
+		return errors.New("Bodyweight must be positive")
+	}
+	return nil
+}
+
+// sessionDuration is how long a finished session took, in seconds. It is zero
+// while the session is in progress.
+func sessionDuration(session database.Session) int {
+	if session.EndDateTime == "" {
+		return 0
+	}
+	start, err := time.Parse(database.DateTimeLayout, session.DateTime)
+	if err != nil {
+		return 0
+	}
+	end, err := time.Parse(database.DateTimeLayout, session.EndDateTime)
+	if err != nil {
+		return 0
+	}
+	return int(end.Sub(start).Seconds())
+}
+
+// Page sizes of the session list.
+const (
+	defaultSessionLimit = 20
+	maxSessionLimit     = 100
+)
+
+// encodeSessionCursor returns the opaque cursor that continues a session
+// listing after session.
+func encodeSessionCursor(session database.SessionRow) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(session.Id) + " " + session.DateTime))
+}
+
+// decodeSessionCursor reverses encodeSessionCursor.
+func decodeSessionCursor(cursor string) (database.SessionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return database.SessionCursor{}, err
+	}
+	id, dateTime, found := strings.Cut(string(raw), " ")
+	if !found {
+		return database.SessionCursor{}, errors.New("malformed cursor")
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return database.SessionCursor{}, err
+	}
+	return database.SessionCursor{DateTime: dateTime, Id: n}, nil
+}
+
+// sessionFilter reads the session list's query parameters: limit, cursor,
+// from and to (dates in the user's zone) and exercise (a name or alias). It
+// writes the error response and returns false on failure.
+func (app *App) sessionFilter(w http.ResponseWriter, r *http.Request, user database.UserRow) (database.SessionFilter, bool) {
+	filter := database.SessionFilter{Limit: defaultSessionLimit}
+	query := r.URL.Query()
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxSessionLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit, expected 1 to %d", maxSessionLimit), http.StatusBadRequest)
+			return filter, false
+		}
+		filter.Limit = limit
+	}
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := decodeSessionCursor(v)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.After = &cursor
+	}
+	var err error
+	filter.From, filter.To, err = parseDateRange(r, userLocation(user))
+	if err != nil {
+		http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return filter, false
+	}
+	if name := query.Get("exercise"); name != "" {
+		exercise, found, ok := app.lookupExercise(w, user.Id, name)
+		if !ok {
+			return filter, false
+		}
+		// An exercise the user has never heard of matches no session.
+		filter.ExerciseID = -1
+		if found {
+			filter.ExerciseID = exercise.Id
+		}
+	}
+	return filter, true
+}
+
+// userLocation returns the user's time zone, falling back to UTC for a zone
+// this build cannot load.
+func userLocation(user database.UserRow) *time.Location {
+	loc, err := time.LoadLocation(user.TimeZone)
+	if err != nil || user.TimeZone == "" {
+		return time.UTC
+	}
+	return loc
+}
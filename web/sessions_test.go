@@ -0,0 +1,105 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSessionListPages(t *testing.T) {
+	app, handler := testApp(t)
+	_, cookies := newUser(t, app, "alice@example.com")
+	var sessions []int
+	// Three sessions share a start time; pages must neither repeat nor skip
+	// any of them.
+	for _, dateTime := range []string{"2024-03-01 10:00:00", "2024-03-02 10:00:00", "2024-03-02 10:00:00", "2024-03-02 10:00:00", "2024-03-03 10:00:00"} {
+		sessions = append(sessions, create(t, handler, cookies, "/sessions", fmt.Sprintf(`{"DateTime": %q}`, dateTime)))
+	}
+	for i, exercise := range []string{"squat", "bench press", "squat", "deadlift", "squat"} {
+		create(t, handler, cookies, "/workouts", fmt.Sprintf(`{"SessionID": %d, "WorkoutName": %q}`, sessions[i], exercise))
+	}
+
+	// list follows the Link header from path to the last page.
+	list := func(path string) (pages [][]int, total string) {
+		t.Helper()
+		for path != "" {
+			rec := call(handler, cookies, http.MethodGet, path, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d %s", path, rec.Code, rec.Body)
+			}
+			var summaries []SessionSummary
+			decode(t, rec, &summaries)
+			var page []int
+			for _, summary := range summaries {
+				page = append(page, summary.Id)
+			}
+			pages = append(pages, page)
+			total = rec.Header().Get("X-Total-Count")
+			path = ""
+			if link := rec.Header().Get("Link"); link != "" {
+				path = strings.TrimPrefix(strings.TrimSuffix(link, `>; rel="next"`), "<")
+			}
+			if len(pages) > len(sessions) {
+				t.Fatalf("GET /sessions did not stop paging: %v", pages)
+			}
+		}
+		return pages, total
+	}
+
+	pages, total := list("/sessions?limit=2")
+	want := [][]int{{sessions[4], sessions[3]}, {sessions[2], sessions[1]}, {sessions[0]}}
+	if fmt.Sprint(pages) != fmt.Sprint(want) || total != "5" {
+		t.Errorf("pages %v of %s sessions, want %v of 5", pages, total, want)
+	}
+
+	pages, total = list("/sessions?limit=1&exercise=squat")
+	want = [][]int{{sessions[4]}, {sessions[2]}, {sessions[0]}}
+	if fmt.Sprint(pages) != fmt.Sprint(want) || total != "3" {
+		t.Errorf("squat pages %v of %s sessions, want %v of 3", pages, total, want)
+	}
+	pages, total = list("/sessions?exercise=back%20squat")
+	if fmt.Sprint(pages) != fmt.Sprint([][]int{{sessions[4], sessions[2], sessions[0]}}) {
+		t.Errorf("back squat, an alias of squat, lists %v", pages)
+	}
+	pages, total = list("/sessions?exercise=juggling")
+	if len(pages) != 1 || len(pages[0]) != 0 || total != "0" {
+		t.Errorf("an unknown exercise lists %v of %s sessions, want none", pages, total)
+	}
+}
+
+func TestSessionListUnit(t *testing.T) {
+	app, handler := testApp(t)
+	_, cookies := newUser(t, app, "alice@example.com")
+	if rec := call(handler, cookies, http.MethodPatch, "/me", `{"Unit": "lb"}`); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH /me = %d %s", rec.Code, rec.Body)
+	}
+	owned := seedOwnedData(t, handler, cookies, "alice")
+
+	units := func(path string) []string {
+		t.Helper()
+		rec := call(handler, cookies, http.MethodGet, path, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", path, rec.Code, rec.Body)
+		}
+		var summaries []SessionSummary
+		decode(t, rec, &summaries)
+		var units []string
+		for _, summary := range summaries {
+			if summary.Id == owned.session {
+				units = append(units, summary.Unit)
+			}
+		}
+		return units
+	}
+	if got := units("/sessions"); !slices.Equal(got, []string{"lb"}) {
+		t.Errorf("GET /sessions shows units %v, want the preferred lb", got)
+	}
+	if got := units("/sessions?unit=kg"); !slices.Equal(got, []string{"kg"}) {
+		t.Errorf("GET /sessions?unit=kg shows units %v, want kg", got)
+	}
+	if rec := call(handler, cookies, http.MethodGet, "/sessions?unit=stone", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /sessions?unit=stone = %d, want 400", rec.Code)
+	}
+}
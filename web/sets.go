@@ -0,0 +1,84 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// tempoPattern matches tempo notation: four phases in seconds, with X for an
+// explosive phase, written as "31X0" or "3-1-X-0".
+var tempoPattern = regexp.MustCompile(`^[0-9X]{4}$|^([0-9]+|X)(-([0-9]+|X)){3}$`)
+
+// validRPE reports whether rpe is on the 1-10 scale in half steps.
+func validRPE(rpe float64) bool {
+	return rpe >= 1 && rpe <= 10 && rpe*2 == math.Trunc(rpe*2)
+}
+
+// validateSet checks the fields of a set that has been filled in from a
+// request. The error is meant for the client.
+func validateSet(set database.Set) error {
+	switch {
+	case set.Weight < 0 || set.NumberOfReps < 0:
+		return errors.New("Weight and NumberOfReps must not be negative")
+	case !slices.Contains(database.Units, set.Unit):
+		return errors.New("Invalid unit")
+	case !slices.Contains(database.SetTypes, set.Type):
+		return errors.New("Invalid set type")
+	case set.RPE != nil && !validRPE(*set.RPE):
+		return errors.New("RPE must be between 1 and 10 in steps of 0.5")
+	case set.RIR != nil && *set.RIR < 0:
+		return errors.New("RIR must not be negative")
+	case set.Tempo != "" && !tempoPattern.MatchString(set.Tempo):
+		return errors.New("Invalid tempo, expected four phases such as 3-1-X-0")
+	case set.Duration < 0 || set.Distance < 0 || set.Calories < 0:
+		return errors.New("Duration, Distance and Calories must not be negative")
+	}
+	return nil
+}
+
+// checkSetForExercise checks that a completed set records what its exercise
+// is measured by: reps for strength and bodyweight exercises, a duration for
+// timed ones, and a duration or distance for cardio and carries. Sets not yet
+// completed may be left empty.
+func checkSetForExercise(set database.Set, exercise database.ExerciseRow) error {
+	if !set.Completed {
+		return nil
+	}
+	switch exercise.Type {
+	case database.ExerciseTypeTimed:
+		if set.Duration == 0 {
+			return fmt.Errorf("Duration is required for %s", exercise.Name)
+		}
+	case database.ExerciseTypeCardio, database.ExerciseTypeCarry:
+		if set.Duration == 0 && set.Distance == 0 {
+			return fmt.Errorf("Duration or Distance is required for %s", exercise.Name)
+		}
+	default:
+		if set.NumberOfReps <= 0 {
+			return fmt.Errorf("NumberOfReps is required for %s", exercise.Name)
+		}
+	}
+	return nil
+}
+
+// workoutExercise returns the exercise a workout logs. It writes the error
+// response and returns false on failure.
+func (app *App) workoutExercise(w http.ResponseWriter, workoutID int) (database.ExerciseRow, bool) {
+	workout, err := app.db.GetWorkoutById(workoutID)
+	if err == nil {
+		var exercise database.ExerciseRow
+		exercise, err = app.db.GetExerciseById(workout.ExerciseID)
+		if err == nil {
+			return exercise, true
+		}
+	}
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+	app.logger.Error().Msgf("workoutExercise: %v", err)
+	return database.ExerciseRow{}, false
+}
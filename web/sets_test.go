@@ -0,0 +1,43 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestNegativeSetsRejected(t *testing.T) {
+	app, handler := testApp(t)
+	_, cookies := newUser(t, app, "alice@example.com")
+	owned := seedOwnedData(t, handler, cookies, "alice")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"create with negative weight", http.MethodPost, "/sets", fmt.Sprintf(`{"WorkoutID": %d, "Weight": -50, "NumberOfReps": 5}`, owned.workout)},
+		{"create with negative reps", http.MethodPost, "/sets", fmt.Sprintf(`{"WorkoutID": %d, "Weight": 50, "NumberOfReps": -5}`, owned.workout)},
+		{"update with negative weight", http.MethodPatch, fmt.Sprintf("/sets/%d", owned.set), `{"Weight": -50}`},
+		{"update with negative reps", http.MethodPut, fmt.Sprintf("/sets/%d", owned.set), `{"Weight": 50, "NumberOfReps": -5}`},
+		{"restore with negative weight", http.MethodPost, "/account/import", `{"Version": 1, "Sessions": [{"DateTime": "2024-01-02 10:00:00", "Workouts": [{"WorkoutName": "squat", "Sets": [{"Weight": -50, "NumberOfReps": 5}]}]}]}`},
+	}
+	for _, tt := range tests {
+		if rec := call(handler, cookies, tt.method, tt.path, tt.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %s %s = %d %s, want 400", tt.name, tt.method, tt.path, rec.Code, rec.Body)
+		}
+	}
+
+	// The importer reports the row instead of failing the file.
+	csv := "Date,Exercise,Weight (kg),Reps\n2024-01-02,Squat,100,5\n2024-01-02,Squat,100,-5\n"
+	rec := call(handler, cookies, http.MethodPost, "/import?format=fitnotes", csv)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /import = %d %s", rec.Code, rec.Body)
+	}
+	var report ImportReport
+	decode(t, rec, &report)
+	if report.SetsCreated != 1 || len(report.Errors) != 1 || report.Errors[0].Row != 3 {
+		t.Errorf("import report %+v, want one set and an error on row 3", report)
+	}
+}
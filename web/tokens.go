@@ -0,0 +1,106 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// apiTokenPrefix marks API tokens so they are easy to recognise, for example
+// by secret scanners.
+const apiTokenPrefix = "wt_"
+
+// Get the signed-in user's API tokens, without their secrets
+func (app *App) ApiTokenListHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	tokens, err := app.db.GetApiTokensByUserId(userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ApiTokenListHandler: %v", err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, tokens)
+}
+
+// Mint a named API token. Scope defaults to read.
+func (app *App) ApiTokenCreateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var token database.ApiToken
+	if err := json.NewDecoder(r.Body).Decode(&token); err != nil {
+		http.Error(w, "Could not decode token", http.StatusBadRequest)
+		return
+	}
+	token.UserID = userID
+	token.Name = strings.TrimSpace(token.Name)
+	if token.Name == "" {
+		http.Error(w, "Invalid token name", http.StatusBadRequest)
+		return
+	}
+	if token.Scope == "" {
+		token.Scope = database.ApiTokenScopeRead
+	}
+	if !slices.Contains(database.ApiTokenScopes, token.Scope) {
+		http.Error(w, "Invalid scope, expected read or write", http.StatusBadRequest)
+		return
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ApiTokenCreateHandler: %v", err)
+		return
+	}
+	secret = apiTokenPrefix + secret
+	tokenID, err := app.db.CreateApiToken(token, hashToken(secret))
+	if err != nil {
+		if strings.Contains(err.Error(), "Token already exists") {
+			http.Error(w, "Token already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Could not add token", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ApiTokenCreateHandler: %v", err)
+		return
+	}
+	created, err := app.db.GetApiTokenById(int(tokenID))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ApiTokenCreateHandler: %v", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/tokens/%d", created.Id))
+	app.writeJSON(w, http.StatusCreated, ApiTokenCreated{ApiTokenRow: created, Token: secret})
+}
+
+// Revoke an API token
+func (app *App) ApiTokenDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	tokenID, err := urlParamID(r, "tokenID")
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+	if !app.authorizeApiToken(w, tokenID, userID) {
+		return
+	}
+	if err := app.db.DeleteApiToken(tokenID); err != nil {
+		http.Error(w, "Could not delete token", http.StatusInternalServerError)
+		app.logger.Error().Msgf("ApiTokenDeleteHandler: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newApiToken creates an API token with scope from a signed-in session.
+func newApiToken(t *testing.T, handler http.Handler, cookies []*http.Cookie, name string, scope string) string {
+	t.Helper()
+	rec := call(handler, cookies, http.MethodPost, "/tokens", fmt.Sprintf(`{"Name": %q, "Scope": %q}`, name, scope))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /tokens = %d %s", rec.Code, rec.Body)
+	}
+	var created ApiTokenCreated
+	decode(t, rec, &created)
+	return created.Token
+}
+
+// callWithHeader is call authenticated by an Authorization header instead of
+// cookies.
+func callWithHeader(handler http.Handler, authorization string, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", authorization)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestApiTokenScopes(t *testing.T) {
+	app, handler := testApp(t)
+	userID, cookies := newUser(t, app, "alice@example.com")
+	owned := seedOwnedData(t, handler, cookies, "alice")
+	read := newApiToken(t, handler, cookies, "reader", "read")
+	write := newApiToken(t, handler, cookies, "writer", "write")
+
+	tests := []struct {
+		name          string
+		authorization string
+		method        string
+		path          string
+		body          string
+		status        int
+	}{
+		{"read token reads", "Bearer " + read, http.MethodGet, "/sessions", "", http.StatusOK},
+		{"read token creates", "Bearer " + read, http.MethodPost, "/sessions", "", http.StatusForbidden},
+		{"read token updates", "Bearer " + read, http.MethodPatch, fmt.Sprintf("/sets/%d", owned.set), `{"Weight": 60}`, http.StatusForbidden},
+		{"read token deletes", "Bearer " + read, http.MethodDelete, fmt.Sprintf("/sets/%d", owned.set), "", http.StatusForbidden},
+		{"read token changes preferences", "Bearer " + read, http.MethodPatch, "/me", `{"Unit": "lb"}`, http.StatusForbidden},
+		{"write token creates", "Bearer " + write, http.MethodPost, "/sessions", "", http.StatusCreated},
+		{"lower-case scheme", "bearer " + read, http.MethodGet, "/sessions", "", http.StatusOK},
+		{"upper-case scheme", "BEARER " + read, http.MethodGet, "/sessions", "", http.StatusOK},
+		{"another scheme", "Basic " + read, http.MethodGet, "/sessions", "", http.StatusUnauthorized},
+		{"no scheme", read, http.MethodGet, "/sessions", "", http.StatusUnauthorized},
+		{"unknown token", "Bearer wt_unknown", http.MethodGet, "/sessions", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if rec := callWithHeader(handler, tt.authorization, tt.method, tt.path, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: %s %s = %d %s, want %d", tt.name, tt.method, tt.path, rec.Code, strings.TrimSpace(rec.Body.String()), tt.status)
+		}
+	}
+
+	// The rejected writes changed nothing.
+	set, err := app.db.GetSetById(owned.set)
+	if err != nil || set.Weight == 60 {
+		t.Errorf("set after writes with a read token: %+v (%v)", set, err)
+	}
+	if user, err := app.db.GetUserById(userID); err != nil || user.Unit == "lb" {
+		t.Errorf("user after writes with a read token: %+v (%v)", user, err)
+	}
+}
+
+func TestSessionOnlyRejectsApiTokens(t *testing.T) {
+	app, handler := testApp(t)
+	_, cookies := newUser(t, app, "alice@example.com")
+	owned := seedOwnedData(t, handler, cookies, "alice")
+	write := "Bearer " + newApiToken(t, handler, cookies, "writer", "write")
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/tokens", ""},
+		{http.MethodPost, "/tokens", `{"Name": "another", "Scope": "write"}`},
+		{http.MethodDelete, fmt.Sprintf("/tokens/%d", owned.token), ""},
+		{http.MethodPost, "/logout/all", ""},
+		{http.MethodPut, "/me/password", `{"NewPassword": "script's password"}`},
+		{http.MethodPost, "/account/import", `{"Version": 1}`},
+	}
+	for _, tt := range tests {
+		if rec := callWithHeader(handler, write, tt.method, tt.path, tt.body); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s with an API token = %d %s, want 403", tt.method, tt.path, rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+
+	// Nothing was restored over the account or revoked.
+	if _, err := app.db.GetSessionById(owned.session); err != nil {
+		t.Errorf("session after a restore with an API token: %v", err)
+	}
+	if _, err := app.db.GetApiTokenById(owned.token); err != nil {
+		t.Errorf("token after a delete with an API token: %v", err)
+	}
+}
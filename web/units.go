@@ -0,0 +1,105 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/milindtheengineer/workout-tracker-server/database"
+)
+
+// displayUnit returns the unit weights are shown in: the unit query parameter
+// when given, otherwise the user's preferred unit. It writes the error
+// response and returns false on failure.
+func (app *App) displayUnit(w http.ResponseWriter, r *http.Request, userID int) (string, bool) {
+	if r.URL.Query().Get("unit") != "" {
+		return unitOverride(w, r, "")
+	}
+	return app.preferredUnit(w, userID)
+}
+
+// unitOverride is displayUnit for a handler that has loaded the user already:
+// the unit query parameter when given, otherwise unit.
+func unitOverride(w http.ResponseWriter, r *http.Request, unit string) (string, bool) {
+	if override := r.URL.Query().Get("unit"); override != "" {
+		if !slices.Contains(database.Units, override) {
+			http.Error(w, "Invalid unit", http.StatusBadRequest)
+			return "", false
+		}
+		return override, true
+	}
+	return unit, true
+}
+
+// preferredUnit returns the unit the user reads and, by default, enters
+// weights in. It writes the error response and returns false on failure.
+func (app *App) preferredUnit(w http.ResponseWriter, userID int) (string, bool) {
+	user, ok := app.currentUser(w, userID)
+	return user.Unit, ok
+}
+
+// inUnit converts a stored set's weight to unit.
+func inUnit(set database.SetRow, unit string) database.SetRow {
+	set.Weight = database.WeightFromKg(set.Weight, unit)
+	set.Unit = unit
+	return set
+}
+
+// displaySet prepares a stored set for a response: its weight in unit, and
+// pace and speed when it has both a duration and a distance.
+func displaySet(set database.SetRow, unit string) SetView {
+	view := SetView{SetRow: inUnit(set, unit)}
+	if set.Duration > 0 && set.Distance > 0 {
+		km := set.Distance / 1000
+		view.Pace = round2(float64(set.Duration) / km)
+		view.Speed = round2(km / (float64(set.Duration) / 3600))
+	}
+	return view
+}
+
+func displaySets(sets []database.SetRow, unit string) []SetView {
+	views := make([]SetView, len(sets))
+	for i, set := range sets {
+		views[i] = displaySet(set, unit)
+	}
+	return views
+}
+
+// displayHistory converts set history to unit, so statistics computed from it
+// come out in that unit.
+func displayHistory(history []database.ExerciseSet, unit string) []database.ExerciseSet {
+	converted := make([]database.ExerciseSet, len(history))
+	for i, set := range history {
+		set.SetRow = inUnit(set.SetRow, unit)
+		converted[i] = set
+	}
+	return converted
+}
+
+// displayRoutine converts a routine's target weights to unit.
+func displayRoutine(routine database.RoutineRow, unit string) database.RoutineRow {
+	exercises := make([]database.RoutineExercise, len(routine.Exercises))
+	for i, exercise := range routine.Exercises {
+		exercise.TargetWeight = database.WeightFromKg(exercise.TargetWeight, unit)
+		exercises[i] = exercise
+	}
+	routine.Exercises = exercises
+	return routine
+}
+
+// displayUser converts the user's bodyweight to their unit.
+func displayUser(user database.UserRow) database.UserRow {
+	if user.Bodyweight != nil {
+		bodyweight := database.WeightFromKg(*user.Bodyweight, user.Unit)
+		user.Bodyweight = &bodyweight
+	}
+	return user
+}
+
+// displaySession converts a session's bodyweight to unit.
+func displaySession(session database.SessionRow, unit string) SessionView {
+	if session.Bodyweight != nil {
+		bodyweight := database.WeightFromKg(*session.Bodyweight, unit)
+		session.Bodyweight = &bodyweight
+	}
+	return SessionView{SessionRow: session, Unit: unit}
+}